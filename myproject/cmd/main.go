@@ -2,13 +2,32 @@ package main
 
 import (
 	"context"
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"myproject/internal/apikeys"
 	"myproject/internal/bookstore"
+	"myproject/internal/cache"
 	"myproject/internal/config"
 	"myproject/internal/handlers"
+	"myproject/internal/health"
+	"myproject/internal/jobs"
+	"myproject/internal/logging"
+	"myproject/internal/metrics"
+	"myproject/internal/migrations"
+	"myproject/internal/ratelimit"
+	"myproject/internal/seed"
+	"myproject/internal/tracing"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
@@ -21,66 +40,350 @@ func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
 	}
 }
 
+// serviceName ชื่อบริการที่ใช้ใน trace
+const serviceName = "musicstore"
+
+// traced เลือก request ที่จะสร้าง trace ยกเว้น endpoint ที่ถูกเรียกถี่ๆ โดยระบบตรวจสอบ
+func traced(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/health", "/livez", "/readyz":
+		return false
+	}
+	return true
+}
+
 func main() {
-	cfg, err := config.LoadConfig()
+	cfg, args, err := config.LoadConfig(os.Args[1:])
+	if errors.Is(err, config.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		fatal("Failed to load config", err)
+	}
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, level)
+	if err != nil {
+		fatal("Failed to load config", err)
+	}
+	slog.SetDefault(logger)
+
+	// คำสั่งย่อย: ไม่ระบุหรือ serve = รันเซิร์ฟเวอร์, migrate = จัดการ schema ของฐานข้อมูล, seed = โหลดข้อมูลจาก fixture
+	// apikey = ออก ดู และยกเลิก API key ของ partner
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		if err := serve(cfg); err != nil {
+			fatal("Server failed", err)
+		}
+	case "migrate":
+		if err := migrations.Command(context.Background(), cfg.GetConnectionString(), args, os.Stdout); err != nil {
+			fatal("Migration failed", err)
+		}
+	case "seed":
+		db, err := bookstore.NewPostgresDatabase(cfg.GetConnectionString(), poolConfig(cfg))
+		if err != nil {
+			fatal("Failed to connect to database", err)
+		}
+		err = seed.Command(context.Background(), bookstore.NewBookStore(db), args, os.Stdout)
+		db.Close()
+		if err != nil {
+			fatal("Seed failed", err)
+		}
+	case "apikey":
+		db, err := bookstore.NewPostgresDatabase(cfg.GetConnectionString(), poolConfig(cfg))
+		if err != nil {
+			fatal("Failed to connect to database", err)
+		}
+		err = apikeys.Command(context.Background(), bookstore.NewBookStore(db), args, os.Stdout)
+		db.Close()
+		if err != nil {
+			fatal("API key command failed", err)
+		}
+	default:
+		slog.Error("Unknown command (available: serve, migrate, seed, apikey)", slog.String("command", command))
+		os.Exit(2)
+	}
+}
+
+// fatal บันทึก error แล้วจบโปรแกรม defer ที่ค้างอยู่จะไม่ทำงาน จึงต้องปิดทุกอย่างก่อนเรียก
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
+}
+
+// autoMigrate รัน migration ที่ยังไม่ได้รันก่อนเริ่มเซิร์ฟเวอร์ ถ้าเชื่อมต่อไม่ได้จะบันทึก log แล้วเริ่มต่อไป
+func autoMigrate(cfg config.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	db, err := migrations.Open(ctx, cfg.GetConnectionString())
+	if err != nil {
+		slog.Warn("Skipping auto-migrate", slog.Any("error", err))
+		return nil
+	}
+	defer db.Close()
+
+	m, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	done, err := m.Up(ctx)
+	for _, mig := range done {
+		slog.Info("Applied migration", slog.Int("version", mig.Version), slog.String("name", mig.Name))
+	}
+	if err != nil {
+		return fmt.Errorf("auto-migrate failed: %w", err)
+	}
+	return nil
+}
+
+// serve รันเซิร์ฟเวอร์จนได้รับสัญญาณให้ปิด คืน error แทนการเรียก fatal เพื่อให้ defer ปิดฐานข้อมูลและ cache ได้ทุกครั้ง
+func serve(cfg config.Config) error {
+	if cfg.AutoMigrate {
+		if err := autoMigrate(cfg); err != nil {
+			return err
+		}
+	}
+
+	// ctx ถูกยกเลิกเมื่อได้รับ SIGINT หรือ SIGTERM ใช้เริ่มขั้นตอนปิดเซิร์ฟเวอร์
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// workCtx หยุดงานเบื้องหลัง (ดูแลการเชื่อมต่อฐานข้อมูลและ job) หลังจาก request ทั้งหมดจบแล้วเท่านั้น
+	// ถ้าฐานข้อมูลหลุดระหว่างรอ checkout ที่ค้างอยู่ จะยังเชื่อมต่อใหม่ได้
+	workCtx, stopWork := context.WithCancel(context.Background())
+	defer stopWork()
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName:  serviceName,
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		OTLPInsecure: cfg.TracingOTLPInsecure,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", slog.Any("error", err))
+		}
+	}()
+
+	// เริ่มเซิร์ฟเวอร์ได้แม้ฐานข้อมูลยังไม่พร้อม /readyz จะรายงานว่ายังไม่พร้อมจนกว่าจะเชื่อมต่อได้
+	// ปิดฐานข้อมูลหลังจาก request และงานเบื้องหลังทั้งหมดจบแล้วเท่านั้น (defer ทำงานหลัง shutdown ด้านล่าง)
+	db, err := bookstore.OpenPostgresDatabase(cfg.GetConnectionString(), poolConfig(cfg))
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		return fmt.Errorf("failed to open database: %w", err)
 	}
-	db, err := bookstore.NewPostgresDatabase(cfg.GetConnectionString())
+	defer db.Close()
+
+	metrics.RegisterDBStats(db.Stats)
+
+	// cache ของข้อมูลร้านและสินค้า ถ้าอ่านจาก replica ต้องล้าง cache ซ้ำหลังการเขียนเมื่อ replica ตามทันแล้ว
+	var store bookstore.BookDatabase = db
+	cacheStore, err := cache.New(cache.Options{
+		Backend:    cfg.CacheBackend,
+		MaxEntries: cfg.CacheMaxEntries,
+		RedisURL:   cfg.CacheRedisURL,
+		KeyPrefix:  cfg.CacheKeyPrefix,
+	})
 	if err != nil {
-		log.Printf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to set up cache: %w", err)
 	}
-	if db != nil {
-		defer db.Close()
+	var cacheCheck, rateLimitCheck func(context.Context) error
+	if cacheStore != nil {
+		defer cacheStore.Close()
+		var replicaLag time.Duration
+		if len(cfg.DatabaseReplicas) > 0 {
+			replicaLag = cfg.DatabaseReplicaStickiness
+		}
+		store = bookstore.NewCachedDatabase(db, cacheStore, bookstore.CacheOptions{
+			TTLs:       cfg.CacheTTLs,
+			ReplicaLag: replicaLag,
+			OnResult:   metrics.CacheResult,
+		})
+		if redis, ok := cacheStore.(*cache.Redis); ok {
+			cacheCheck = redis.Ping
+		}
 	}
 
-	bs := bookstore.NewBookStore(db)
-	h := handlers.NewBookHandlers(bs)
+	bs := bookstore.NewBookStore(store)
+
+	// จำกัดจำนวน request ของแต่ละ client แยกตามกลุ่มเส้นทาง
+	var rateLimitStore ratelimit.Store
+	if cfg.RateLimitEnabled {
+		rateLimitStore, err = ratelimit.New(ratelimit.Options{
+			Backend:   cfg.RateLimitBackend,
+			RedisURL:  cfg.RateLimitRedisURL,
+			KeyPrefix: cfg.RateLimitKeyPrefix,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set up rate limiting: %w", err)
+		}
+		defer rateLimitStore.Close()
+		if redis, ok := rateLimitStore.(*ratelimit.Redis); ok {
+			rateLimitCheck = redis.Ping
+		}
+	}
+	limiter := handlers.NewRateLimiter(rateLimitStore, cfg.RateLimits)
+	h := handlers.NewBookHandlers(bs, handlers.Options{
+		SecureCookies: cfg.SecureCookies,
+		PublicBaseURL: cfg.PublicBaseURL,
+		ProductURL:    cfg.ProductURL,
+	})
 
+	supervisorDone := make(chan struct{})
 	go func() {
-		for {
-			time.Sleep(10 * time.Second)
-			if err := db.Ping(); err != nil {
-				log.Printf("Database connection lost: %v", err)
-				// พยายามเชื่อมต่อใหม่
-				if reconnErr := db.Reconnect(cfg.GetConnectionString()); reconnErr != nil {
-					log.Printf("Failed to reconnect: %v", reconnErr)
-				} else {
-					log.Printf("Successfully reconnected to the database")
+		defer close(supervisorDone)
+		db.Supervise(workCtx, bookstore.SuperviseOptions{
+			Interval:    cfg.DatabaseHealthInterval,
+			MinBackoff:  cfg.DatabaseReconnectMinBackoff,
+			MaxBackoff:  cfg.DatabaseReconnectMaxBackoff,
+			OnReconnect: metrics.ReconnectAttempt,
+		})
+	}()
+
+	// ระหว่างปิดเซิร์ฟเวอร์ /readyz จะตอบ 503 เพื่อให้ load balancer หยุดส่ง request ใหม่มา
+	var draining atomic.Bool
+	checker := health.NewChecker(2*time.Second,
+		health.Check{Name: "server", Critical: true, Run: func(context.Context) error {
+			if draining.Load() {
+				return errors.New("shutting down")
+			}
+			return nil
+		}},
+		health.Check{Name: "database", Critical: true, Run: db.PingContext},
+		health.Check{Name: "migrations", Critical: true, Run: func(ctx context.Context) error {
+			return db.WithDB(func(sqlDB *sql.DB) error {
+				pending, err := migrations.Pending(ctx, sqlDB)
+				if err != nil {
+					return err
+				}
+				if len(pending) > 0 {
+					return fmt.Errorf("%d pending migrations, next is %04d_%s", len(pending), pending[0].Version, pending[0].Name)
 				}
+				return nil
+			})
+		}},
+		health.Check{Name: "blob_store", Run: health.HTTPCheck(cfg.HealthBlobStoreURL)},
+		health.Check{Name: "payment_provider", Run: health.HTTPCheck(cfg.HealthPaymentURL)},
+		health.Check{Name: "cache", Run: cacheCheck},
+		health.Check{Name: "rate_limiter", Run: rateLimitCheck},
+	)
+
+	// งานเบื้องหลัง
+	runner := jobs.NewRunner()
+	runner.Add(jobs.NewCartExpiry(bs, cfg.CartTTL), cfg.CartExpiryInterval)
+	runner.Start(workCtx)
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	trustedUser, err := handlers.TrustedUser(cfg.AuthProxies)
+	if err != nil {
+		return fmt.Errorf("invalid auth proxies: %w", err)
+	}
+	r.Use(otelgin.Middleware(serviceName, otelgin.WithFilter(traced)))
+	r.Use(handlers.RequestID(), handlers.RequestLogger(), metrics.HTTP(), handlers.Recovery(), handlers.ErrorHandler(), trustedUser)
+	r.Use(TimeoutMiddleware(cfg.RequestTimeout))
+
+	r.GET("/health", h.HealthCheck)
+	r.GET("/livez", health.Livez)
+	r.GET("/readyz", checker.Readyz)
+
+	// เอกสาร API สร้างจาก handlers.OpenAPISpec
+	r.GET("/openapi.json", handlers.OpenAPI)
+	r.GET("/docs", handlers.Docs)
+
+	handlers.RegisterRoutes(r, h, limiter, handlers.Deprecation{At: cfg.V1DeprecatedAt, Sunset: cfg.V1Sunset})
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.AppPort,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// listener ใดหยุดทำงานด้วย error (เช่น พอร์ตถูกใช้อยู่) เซิร์ฟเวอร์จะปิดตัวแล้วคืน error นั้น
+	serverErr := make(chan error, 2)
+
+	// ค่าวัดของ Prometheus อยู่บน listener แยกที่เปิดเฉพาะในเครือข่ายภายใน ไม่ใช่บนพอร์ตของ API
+	var metricsSrv *http.Server
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsSrv = &http.Server{Addr: cfg.MetricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			slog.Info("Metrics server started", slog.String("addr", metricsSrv.Addr))
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- fmt.Errorf("failed to run metrics server: %w", err)
 			}
+		}()
+	}
+
+	go func() {
+		slog.Info("Server started", slog.String("addr", srv.Addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- fmt.Errorf("failed to run server: %w", err)
 		}
 	}()
 
-	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
-	r.Use(TimeoutMiddleware(5 * time.Second))
+	var runErr error
+	select {
+	case runErr = <-serverErr:
+		slog.Error("Shutting down after server error", slog.Any("error", runErr))
+	case <-ctx.Done():
+		// ให้ /readyz ตอบ 503 ไปสักพักก่อนปิด listener เพื่อให้ load balancer เห็นและหยุดส่ง request ใหม่มา
+		// ระหว่างนี้ request ที่ยังเข้ามาได้รับการตอบตามปกติ
+		draining.Store(true)
+		slog.Info("Shutting down", slog.Duration("delay", cfg.ShutdownDelay), slog.Duration("drain_timeout", cfg.ShutdownTimeout))
+		time.Sleep(cfg.ShutdownDelay)
+	}
 
-	r.GET("/health", h.HealthCheck)
+	// รอ request ที่กำลังทำงานอยู่ (เช่น checkout ที่อยู่ใน transaction) ให้เสร็จก่อน
+	// ถ้าเกินเวลาที่กำหนดจะตัดการเชื่อมต่อที่เหลือ
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Drain timeout exceeded, closing remaining connections", slog.Any("error", err))
+		srv.Close()
+	}
+
+	if metricsSrv != nil {
+		metricsSrv.Close()
+	}
+
+	// request จบหมดแล้ว จึงหยุดงานเบื้องหลัง ฐานข้อมูลถูกปิดต่อจากนี้ใน defer
+	stopWork()
+	runner.Wait()
+	<-supervisorDone
+	slog.Info("Server stopped")
+	return runErr
+}
+
+// poolConfig การตั้งค่า connection pool จาก config
+func poolConfig(cfg config.Config) bookstore.PoolConfig {
+	return bookstore.PoolConfig{
+		MaxOpenConns:    cfg.DatabaseMaxOpenConns,
+		MaxIdleConns:    cfg.DatabaseMaxIdleConns,
+		ConnMaxLifetime: cfg.DatabaseConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DatabaseConnMaxIdleTime,
+		PingTimeout:     cfg.DatabasePingTimeout,
 
-	// API v1
-	v1 := r.Group("/api/v1")
-	{
-		v1.GET("/AllStoreInfo", h.GetAllStoreInfo)
-		v1.GET("/store/:id", h.GetStoreInfoByID)
-		v1.GET("/product/:store_id", h.GetProductsByStore)
-		v1.GET("/newproduct/:store_id", h.GetNewProductsByStore)
-		v1.GET("/searchproducts", h.SearchProducts)
-		v1.GET("/products/:id", h.GetProduct)
-		v1.GET("/:store_id/search", h.SearchProductsByStore)
-		v1.GET("/Allproduct/:store_id/sort", h.GetAllProductsByStore)
-		v1.GET("/:store_id/by-category", h.GetProductsByCategoryAndStore)
-		v1.GET("/category", h.GetALLProductsByCategory)
-		v1.POST("/store/:store_id/product/:product_id/add_to_cart", h.AddToCart)
-		v1.GET("/cart/:store_id", h.GetCartItemsByStore) // เพิ่มเส้นทางนี้
-		v1.DELETE("/store/:store_id/product/:product_id/remove_from_cart", h.DeleteProductFromCart)
-		v1.GET("/all-guitars", h.GetAllGuitars)
-
-		// เส้นทางสำหรับ Checkout (ย้ายข้อมูลจาก cart ไป order_history)
-		v1.POST("/checkout/:store_id", h.Checkout)
-	}
-
-	if err := r.Run(":" + cfg.AppPort); err != nil {
-		log.Printf("Failed to run server: %v", err)
+		Replicas:          cfg.DatabaseReplicas,
+		ReplicaStickiness: cfg.DatabaseReplicaStickiness,
 	}
 }
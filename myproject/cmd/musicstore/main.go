@@ -0,0 +1,117 @@
+// main.go
+
+// musicstore เครื่องมือ command line ของผู้ดูแลระบบ ใช้ config และ package bookstore ชุดเดียวกับเซิร์ฟเวอร์ใน cmd
+//
+//	musicstore [flags] stores|products|carts|orders|migrate|apikey ...
+//
+// ใส่ -json ท้ายคำสั่งเพื่อให้พิมพ์ผลลัพธ์เป็น JSON สำหรับ script ถ้าล้มเหลวจะพิมพ์ error ลง stderr และจบด้วย exit code 1
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"myproject/internal/admin"
+	"myproject/internal/apikeys"
+	"myproject/internal/bookstore"
+	"myproject/internal/cache"
+	"myproject/internal/config"
+	"myproject/internal/logging"
+	"myproject/internal/migrations"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// commands กลุ่มคำสั่งทั้งหมดของ musicstore
+var commands = append(slices.Clone(admin.Commands), "migrate", "apikey")
+
+func usage() string {
+	return admin.Usage + "\n  " + strings.TrimPrefix(migrations.Usage, "usage: ") + "\n  " + strings.TrimPrefix(apikeys.Usage, "usage: ")
+}
+
+func main() {
+	config.CommandsUsage = "(" + strings.Join(commands, " | ") + ") [args]"
+	cfg, args, err := config.LoadConfig(os.Args[1:])
+	if errors.Is(err, config.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "\n"+usage())
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(args) == 0 || !slices.Contains(commands, args[0]) {
+		fmt.Fprintln(os.Stderr, usage())
+		os.Exit(2)
+	}
+
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = run(ctx, cfg, args)
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "musicstore:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg config.Config, args []string) error {
+	// migrate เชื่อมต่อฐานข้อมูลเอง และต้องใช้ได้แม้ schema ยังไม่พร้อม
+	if args[0] == "migrate" {
+		return migrations.Command(ctx, cfg.GetConnectionString(), args[1:], os.Stdout)
+	}
+
+	bs, err := openBookStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer bs.Close()
+
+	if args[0] == "apikey" {
+		return apikeys.Command(ctx, bs, args[1:], os.Stdout)
+	}
+	return admin.Command(ctx, bs, args, os.Stdout)
+}
+
+// openBookStore เชื่อมต่อ primary โดยตรงเพื่อให้อ่านได้ข้อมูลล่าสุดเสมอ
+// ถ้าเซิร์ฟเวอร์ใช้ cache ใน Redis การแก้ร้านหรือสินค้าจะล้าง cache นั้นด้วย เซิร์ฟเวอร์จึงเห็นการเปลี่ยนแปลงทันที
+// (cache ในหน่วยความจำของเซิร์ฟเวอร์ล้างจากที่นี่ไม่ได้ จะเห็นการเปลี่ยนแปลงเมื่อ cache หมดอายุ)
+func openBookStore(cfg config.Config) (*bookstore.BookStore, error) {
+	db, err := bookstore.NewPostgresDatabase(cfg.GetConnectionString(), bookstore.PoolConfig{PingTimeout: cfg.DatabasePingTimeout})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CacheBackend != cache.BackendRedis {
+		return bookstore.NewBookStore(db), nil
+	}
+
+	cacheStore, err := cache.New(cache.Options{
+		Backend:   cfg.CacheBackend,
+		RedisURL:  cfg.CacheRedisURL,
+		KeyPrefix: cfg.CacheKeyPrefix,
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	// TTL ว่างทุก method: อ่านจากฐานข้อมูลเสมอ ใช้ cache เพื่อล้างหลังการเขียนเท่านั้น
+	return bookstore.NewBookStore(bookstore.NewCachedDatabase(db, cacheStore, bookstore.CacheOptions{
+		TTLs: map[string]time.Duration{},
+	})), nil
+}
@@ -0,0 +1,232 @@
+// command.go
+
+// Package admin คำสั่ง command line ของผู้ดูแลระบบ: ร้าน สินค้า ตะกร้า และคำสั่งซื้อ
+// ทุกคำสั่งพิมพ์เป็นตารางให้คนอ่าน หรือเป็น JSON สำหรับ script เมื่อใส่ -json
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"myproject/internal/bookstore"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Usage วิธีใช้คำสั่งของผู้ดูแล
+const Usage = `usage:
+  stores list [-json]
+  stores create -name NAME [-description TEXT] [-address TEXT] [-phone PHONE] [-email EMAIL] [-logo PATH] [-json]
+  products list STORE_ID [-json]
+  products adjust PRODUCT_ID [-stock N | -add N] [-price PRICE] [-json]
+  carts list STORE_ID [-json]
+  carts show STORE_ID (-user ID | -token TOKEN) [-json]
+  orders list [-store ID] [-status placed|cancelled|refunded] [-limit N] [-json]
+  orders show ORDER_ID [-json]
+  orders cancel ORDER_ID -reason TEXT [-json]
+  orders refund ORDER_ID -reason TEXT [-json]`
+
+// Commands ชื่อกลุ่มคำสั่งที่ Command รับ
+var Commands = []string{"stores", "products", "carts", "orders"}
+
+// Command รันคำสั่ง stores|products|carts|orders ตาม args แล้วพิมพ์ผลลัพธ์ลง out
+func Command(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return errors.New(Usage)
+	}
+
+	var err error
+	switch args[0] + " " + args[1] {
+	case "stores list":
+		err = listStores(ctx, bs, args[2:], out)
+	case "stores create":
+		err = createStore(ctx, bs, args[2:], out)
+	case "products list":
+		err = listProducts(ctx, bs, args[2:], out)
+	case "products adjust":
+		err = adjustProduct(ctx, bs, args[2:], out)
+	case "carts list":
+		err = listCarts(ctx, bs, args[2:], out)
+	case "carts show":
+		err = showCart(ctx, bs, args[2:], out)
+	case "orders list":
+		err = listOrders(ctx, bs, args[2:], out)
+	case "orders show":
+		err = showOrder(ctx, bs, args[2:], out)
+	case "orders cancel":
+		err = changeOrder(ctx, args[2:], out, "orders cancel", bs.CancelOrder)
+	case "orders refund":
+		err = changeOrder(ctx, args[2:], out, "orders refund", bs.RefundOrder)
+	default:
+		return errors.New(Usage)
+	}
+	return bookstore.DescribeFields(err)
+}
+
+// printer พิมพ์ผลลัพธ์เป็นตารางหรือเป็น JSON ตาม flag -json
+type printer struct {
+	out  io.Writer
+	json bool
+}
+
+// newFlagSet สร้าง flag set ของคำสั่งย่อยที่มี -json อยู่แล้ว
+func newFlagSet(name string, out io.Writer) (*flag.FlagSet, *printer) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	p := &printer{out: out}
+	fs.BoolVar(&p.json, "json", false, "print JSON instead of a table")
+	return fs, p
+}
+
+// print พิมพ์ v เป็น JSON หรือให้ table เขียนตารางที่จัดคอลัมน์ด้วย tab
+func (p *printer) print(v interface{}, table func(w io.Writer)) error {
+	if p.json {
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// parseArgs อ่าน flag ของคำสั่งที่ไม่มี argument อื่น
+func parseArgs(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// parseID อ่านรหัสที่ต้องอยู่หน้าสุดของ args เช่น "orders show 12 -json" แล้วอ่าน flag ที่ตามมา
+func parseID(fs *flag.FlagSet, what string, args []string) (int, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if err := fs.Parse(args); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%s is required", what)
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", what, args[0])
+	}
+	return id, parseArgs(fs, args[1:])
+}
+
+// isSet คืน true ถ้าผู้ใช้ใส่ flag name มาเอง (ไม่ใช่ค่าเริ่มต้น)
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// nonNil ให้รายการว่างออกมาเป็น [] ใน JSON แทน null
+func nonNil[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
+
+const timeFormat = "2006-01-02 15:04"
+
+func listStores(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	fs, p := newFlagSet("stores list", out)
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	stores, err := bs.GetAllStoreInfo(ctx)
+	if err != nil {
+		return err
+	}
+	return p.print(nonNil(stores), func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tPHONE\tEMAIL\tADDRESS")
+		for _, s := range stores {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", s.ID, s.StoreName, s.PhoneNumber, s.Email, s.Address)
+		}
+	})
+}
+
+func createStore(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	fs, p := newFlagSet("stores create", out)
+	var store bookstore.StoreInfo
+	fs.StringVar(&store.StoreName, "name", "", "store name, must be unique")
+	fs.StringVar(&store.Description, "description", "", "store description")
+	fs.StringVar(&store.Address, "address", "", "store address")
+	fs.StringVar(&store.PhoneNumber, "phone", "", "phone number")
+	fs.StringVar(&store.Email, "email", "", "contact email")
+	fs.StringVar(&store.LogoPath, "logo", "", "logo URL or path")
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	store, err := bs.CreateStore(ctx, store)
+	if err != nil {
+		return err
+	}
+	return p.print(store, func(w io.Writer) {
+		fmt.Fprintf(w, "created store %d %s\n", store.ID, store.StoreName)
+	})
+}
+
+func listProducts(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	fs, p := newFlagSet("products list", out)
+	storeID, err := parseID(fs, "store id", args)
+	if err != nil {
+		return err
+	}
+	if _, err := bs.GetStoreInfoByID(ctx, storeID); err != nil {
+		return err
+	}
+	var products []bookstore.Product
+	err = bs.EachProductByStore(ctx, storeID, false, func(product bookstore.Product) error {
+		products = append(products, product)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return p.print(nonNil(products), func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tBRAND\tMODEL\tCATEGORY\tPRICE\tSTOCK\tUPDATED")
+		for _, pr := range products {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%.2f\t%d\t%s\n", pr.ID, pr.ProductName, pr.Brand, pr.Model, pr.Category,
+				pr.Price, pr.Quantity, pr.UpdatedAt.Format(timeFormat))
+		}
+	})
+}
+
+func adjustProduct(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	fs, p := newFlagSet("products adjust", out)
+	stock := fs.Int("stock", 0, "set the stock to N")
+	add := fs.Int("add", 0, "add N to the stock, negative to remove")
+	price := fs.Float64("price", 0, "set the price")
+	productID, err := parseID(fs, "product id", args)
+	if err != nil {
+		return err
+	}
+
+	adj := bookstore.ProductAdjustment{StockDelta: *add}
+	if isSet(fs, "stock") {
+		adj.Stock = stock
+	}
+	if isSet(fs, "price") {
+		adj.Price = price
+	}
+	product, err := bs.AdjustProduct(ctx, productID, adj)
+	if err != nil {
+		return err
+	}
+	return p.print(product, func(w io.Writer) {
+		fmt.Fprintf(w, "product %d %s: price %.2f, stock %d\n", product.ID, product.ProductName, product.Price, product.Quantity)
+	})
+}
@@ -0,0 +1,149 @@
+// orders.go
+package admin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"myproject/internal/bookstore"
+	"strings"
+)
+
+// guestTokenShown จำนวนตัวอักษรต้นของ cart token ที่แสดง พอให้แยกแขกแต่ละคนได้
+// token เต็มใช้เข้าถึงตะกร้าของแขกได้ จึงไม่แสดงทั้งหมด (แบบเดียวกับ prefix ของ API key)
+// ผู้ดูแลที่ต้องใช้ token เต็ม (carts show -token) ดูได้จาก carts list -json
+const guestTokenShown = 8
+
+// ownerName ชื่อเจ้าของตะกร้าหรือคำสั่งซื้อสำหรับแสดงในตาราง
+func ownerName(userID *int, token *string) string {
+	switch {
+	case userID != nil && *userID != 0:
+		return fmt.Sprintf("user %d", *userID)
+	case token != nil && *token != "":
+		return "guest " + (*token)[:min(len(*token), guestTokenShown)] + "…"
+	default:
+		return "-"
+	}
+}
+
+func listCarts(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	fs, p := newFlagSet("carts list", out)
+	storeID, err := parseID(fs, "store id", args)
+	if err != nil {
+		return err
+	}
+	carts, err := bs.ListCarts(ctx, storeID)
+	if err != nil {
+		return err
+	}
+	return p.print(nonNil(carts), func(w io.Writer) {
+		fmt.Fprintln(w, "OWNER\tLINES\tITEMS\tSUBTOTAL\tUPDATED")
+		for _, c := range carts {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.2f\t%s\n", ownerName(&c.Owner.UserID, &c.Owner.Token),
+				c.Lines, c.ItemCount, c.Subtotal, c.UpdatedAt.Format(timeFormat))
+		}
+	})
+}
+
+func showCart(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	fs, p := newFlagSet("carts show", out)
+	var owner bookstore.CartOwner
+	fs.IntVar(&owner.UserID, "user", 0, "user ID of the cart owner")
+	fs.StringVar(&owner.Token, "token", "", "cart token of a guest cart")
+	storeID, err := parseID(fs, "store id", args)
+	if err != nil {
+		return err
+	}
+	if owner.IsZero() || (owner.UserID != 0 && owner.Token != "") {
+		return fmt.Errorf("set either -user or -token (see carts list -json %d)", storeID)
+	}
+
+	cart, err := bs.GetCart(ctx, owner, storeID)
+	if err != nil {
+		return err
+	}
+	return p.print(cart, func(w io.Writer) {
+		fmt.Fprintln(w, "ITEM\tPRODUCT\tNAME\tQUANTITY\tUNIT PRICE\tLINE TOTAL\tIN STOCK")
+		for _, item := range cart.Items {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%.2f\t%.2f\t%d\n", item.ID, item.ProductID, item.ProductName,
+				item.Quantity, item.UnitPrice, item.LineTotal, item.AvailableStock)
+		}
+		fmt.Fprintf(w, "\t\tsubtotal\t%d\t\t%.2f\t\n", cart.ItemCount, cart.Subtotal)
+	})
+}
+
+func listOrders(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	fs, p := newFlagSet("orders list", out)
+	var filter bookstore.OrderFilter
+	fs.IntVar(&filter.StoreID, "store", 0, "only orders of this store")
+	fs.StringVar(&filter.Status, "status", "", "only orders with this status: placed, cancelled or refunded")
+	fs.IntVar(&filter.Limit, "limit", 50, "number of most recent orders to show, at most 1000")
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	orders, err := bs.ListOrders(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return p.print(nonNil(orders), func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tSTORE\tCUSTOMER\tCONTACT\tTOTAL\tSTATUS\tCREATED")
+		for _, o := range orders {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%.2f\t%s\t%s\n", o.ID, o.StoreID, ownerName(o.UserID, o.CartToken),
+				o.Contact.Email, o.TotalAmount, o.Status, o.CreatedAt.Format(timeFormat))
+		}
+	})
+}
+
+func showOrder(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	fs, p := newFlagSet("orders show", out)
+	id, err := parseID(fs, "order id", args)
+	if err != nil {
+		return err
+	}
+	order, err := bs.GetOrder(ctx, id)
+	if err != nil {
+		return err
+	}
+	return p.print(order, func(w io.Writer) { writeOrder(w, order) })
+}
+
+// changeOrder ยกเลิกหรือคืนเงินคำสั่งซื้อด้วย change พร้อมเหตุผลที่ต้องใส่ทุกครั้ง
+func changeOrder(ctx context.Context, args []string, out io.Writer, name string,
+	change func(ctx context.Context, id int, reason string) (bookstore.Order, error)) error {
+	fs, p := newFlagSet(name, out)
+	reason := fs.String("reason", "", "why the order is changed, kept with the order")
+	id, err := parseID(fs, "order id", args)
+	if err != nil {
+		return err
+	}
+	order, err := change(ctx, id, *reason)
+	if err != nil {
+		return err
+	}
+	return p.print(order, func(w io.Writer) { writeOrder(w, order) })
+}
+
+// writeOrder รายละเอียดของคำสั่งซื้อ 1 รายการ บรรทัดละฟิลด์
+func writeOrder(w io.Writer, o bookstore.Order) {
+	fmt.Fprintf(w, "order\t%d\n", o.ID)
+	fmt.Fprintf(w, "store\t%d\n", o.StoreID)
+	fmt.Fprintf(w, "customer\t%s\n", ownerName(o.UserID, o.CartToken))
+	var contact []string
+	for _, part := range []string{o.Contact.Name, o.Contact.Email, o.Contact.Phone} {
+		if part != "" {
+			contact = append(contact, part)
+		}
+	}
+	fmt.Fprintf(w, "contact\t%s\n", strings.Join(contact, ", "))
+	fmt.Fprintf(w, "address\t%s\n", o.Contact.Address)
+	fmt.Fprintf(w, "total\t%.2f\n", o.TotalAmount)
+	fmt.Fprintf(w, "status\t%s\n", o.Status)
+	if o.StatusChangedAt != nil {
+		reason := ""
+		if o.StatusReason != nil {
+			reason = *o.StatusReason
+		}
+		fmt.Fprintf(w, "changed\t%s: %s\n", o.StatusChangedAt.Format(timeFormat), reason)
+	}
+	fmt.Fprintf(w, "created\t%s\n", o.CreatedAt.Format(timeFormat))
+}
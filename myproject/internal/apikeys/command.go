@@ -0,0 +1,101 @@
+// command.go
+
+// Package apikeys คำสั่ง command line สำหรับออก ดู และยกเลิก API key ของ partner
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"myproject/internal/bookstore"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Usage วิธีใช้คำสั่ง apikey
+const Usage = "usage: apikey issue -partner NAME [-store ID] -scopes SCOPE[,SCOPE...] | list | revoke ID"
+
+// Command รันคำสั่ง apikey issue|list|revoke แล้วพิมพ์ผลลัพธ์ลง out
+func Command(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "issue":
+		fs := flag.NewFlagSet("apikey issue", flag.ContinueOnError)
+		fs.SetOutput(out)
+		partner := fs.String("partner", "", "partner name")
+		store := fs.Int("store", 0, "store the key is bound to, required for scopes "+strings.Join(bookstore.StoreScopes, ", "))
+		scopes := fs.String("scopes", bookstore.ScopeCatalogRead, "comma-separated scopes: "+strings.Join(bookstore.Scopes, ", "))
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		var list []string
+		for _, scope := range strings.Split(*scopes, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				list = append(list, scope)
+			}
+		}
+		var storeID *int
+		if *store != 0 {
+			storeID = store
+		}
+		apiKey, key, err := bs.CreateAPIKey(ctx, *partner, storeID, list)
+		if err != nil {
+			return bookstore.DescribeFields(err)
+		}
+		fmt.Fprintf(out, "issued api key %d for %s (%s) with scopes %s\n", apiKey.ID, apiKey.Partner, storeName(apiKey), strings.Join(apiKey.Scopes, ", "))
+		fmt.Fprintf(out, "\n  %s\n\n", key)
+		fmt.Fprintln(out, "store it now, it cannot be shown again")
+
+	case "list":
+		keys, err := bs.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tPARTNER\tPREFIX\tSTORE\tSCOPES\tCREATED\tLAST USED\tSTATUS")
+		for _, k := range keys {
+			lastUsed, status := "never", "active"
+			if k.LastUsedAt != nil {
+				lastUsed = k.LastUsedAt.Format("2006-01-02 15:04")
+			}
+			if k.RevokedAt != nil {
+				status = "revoked " + k.RevokedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s…\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Partner, k.Prefix, storeName(k), strings.Join(k.Scopes, ","),
+				k.CreatedAt.Format("2006-01-02 15:04"), lastUsed, status)
+		}
+		return w.Flush()
+
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New(Usage)
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid api key id %q", args[1])
+		}
+		if err := bs.RevokeAPIKey(ctx, id); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "revoked api key %d\n", id)
+
+	default:
+		return errors.New(Usage)
+	}
+	return nil
+}
+
+// storeName ร้านที่ key ผูกอยู่สำหรับแสดงผล
+func storeName(k bookstore.APIKey) string {
+	if k.StoreID == nil {
+		return "all stores"
+	}
+	return "store " + strconv.Itoa(*k.StoreID)
+}
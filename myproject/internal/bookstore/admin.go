@@ -0,0 +1,369 @@
+// admin.go
+package bookstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// งานของผู้ดูแลระบบที่เรียกจาก command line: สร้างร้าน แก้สต็อกและราคา ดูตะกร้า และเปลี่ยนสถานะคำสั่งซื้อ
+
+// maxPrice ราคาสูงสุดที่คอลัมน์ DECIMAL(10, 2) เก็บได้
+const maxPrice = 99999999.99
+
+// ProductAdjustment การแก้สต็อกหรือราคาของสินค้า ฟิลด์ที่เป็น nil หรือ 0 จะไม่ถูกเปลี่ยน
+type ProductAdjustment struct {
+	// Stock ตั้งจำนวนคงเหลือเป็นค่านี้
+	Stock *int `json:"stock,omitempty"`
+	// StockDelta เพิ่มจำนวนคงเหลือ (ติดลบ = ลด) เช่น รับของเข้าหรือตัดของเสีย ใช้ร่วมกับ Stock ไม่ได้
+	StockDelta int `json:"stock_delta,omitempty"`
+	// Price ราคาใหม่
+	Price *float64 `json:"price,omitempty"`
+}
+
+// CartSummary ตะกร้าที่ยังเปิดอยู่ 1 ใบของร้าน พร้อมจำนวนและยอดรวมตามราคาปัจจุบัน
+type CartSummary struct {
+	StoreID   int       `json:"store_id"`
+	Owner     CartOwner `json:"owner"`
+	Lines     int       `json:"lines"`
+	ItemCount int       `json:"item_count"`
+	Subtotal  float64   `json:"subtotal"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OrderFilter เงื่อนไขของ ListOrders ค่าว่างหมายถึงไม่กรอง
+type OrderFilter struct {
+	StoreID int
+	Status  string
+	// Limit จำนวนคำสั่งซื้อล่าสุดที่ต้องการ
+	Limit int
+}
+
+// ขีดจำกัดจำนวนคำสั่งซื้อของ ListOrders
+const (
+	defaultOrderLimit = 50
+	maxOrderLimit     = 1000
+)
+
+// OrderStatuses สถานะทั้งหมดของคำสั่งซื้อ
+var OrderStatuses = []string{OrderPlaced, OrderCancelled, OrderRefunded}
+
+// CreateStore สร้างร้านใหม่ ชื่อร้านซ้ำกับร้านที่มีอยู่ไม่ได้ (ต่างจาก UpsertStore ที่แก้ร้านเดิม)
+func (pdb *PostgresDatabase) CreateStore(ctx context.Context, store StoreInfo) (_ StoreInfo, err error) {
+	defer pdb.observe(ctx, "CreateStore", slog.String("store_name", store.StoreName))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+
+	query := `
+        INSERT INTO store_info (logo_path, store_name, description, address, phone_number, email)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `
+	err = db.QueryRowContext(ctx, query,
+		store.LogoPath, store.StoreName, store.Description, store.Address, store.PhoneNumber, store.Email,
+	).Scan(&store.ID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return store, ErrStoreNameTaken
+	}
+	if err != nil {
+		return store, fmt.Errorf("failed to create store: %w", err)
+	}
+	return store, nil
+}
+
+// AdjustProduct แก้สต็อกและราคาของสินค้าในคำสั่งเดียว จำนวนคงเหลือหลังแก้ต้องไม่ติดลบ
+func (pdb *PostgresDatabase) AdjustProduct(ctx context.Context, productID int, adj ProductAdjustment) (_ Product, err error) {
+	defer pdb.observe(ctx, "AdjustProduct", slog.Int("product_id", productID), slog.Int("stock_delta", adj.StockDelta))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+
+	var product Product
+	query := `
+        UPDATE product_info
+        SET quantity = COALESCE($2, quantity) + $3,
+            price = COALESCE($4, price),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND COALESCE($2, quantity) + $3 >= 0
+        RETURNING id, product_name, price, quantity, created_at, updated_at, category, brand, model, store_id, is_recommended, image_path
+    `
+	err = db.QueryRowContext(ctx, query, productID, adj.Stock, adj.StockDelta, adj.Price).Scan(
+		&product.ID,
+		&product.ProductName,
+		&product.Price,
+		&product.Quantity,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+		&product.Category,
+		&product.Brand,
+		&product.Model,
+		&product.StoreID,
+		&product.IsRecommended,
+		&product.ImagePath)
+	if err != sql.ErrNoRows {
+		if err != nil {
+			return product, fmt.Errorf("failed to adjust product: %w", err)
+		}
+		return product, nil
+	}
+
+	// ไม่มีแถวที่ถูกแก้ แยกว่าไม่พบสินค้า หรือสต็อกจะติดลบ
+	var stock int
+	err = db.QueryRowContext(ctx, `SELECT quantity FROM product_info WHERE id = $1`, productID).Scan(&stock)
+	if err == sql.ErrNoRows {
+		return product, ErrProductNotFound
+	}
+	if err != nil {
+		return product, fmt.Errorf("failed to get product stock: %w", err)
+	}
+	return product, validationError("invalid stock adjustment", map[string]string{
+		"stock_delta": fmt.Sprintf("would leave %d in stock, stock cannot go below 0", stock+adj.StockDelta),
+	})
+}
+
+// ListCarts ตะกร้าที่ยังเปิดอยู่ทั้งหมดของร้าน เรียงจากที่แก้ไขล่าสุด
+func (pdb *PostgresDatabase) ListCarts(ctx context.Context, storeID int) (_ []CartSummary, err error) {
+	defer pdb.observe(ctx, "ListCarts", slog.Int("store_id", storeID))(&err)
+	db, release := pdb.acquire()
+	defer release()
+
+	query := `
+        SELECT c.user_id, c.cart_token, COUNT(*), SUM(c.quantity), COALESCE(SUM(p.price * c.quantity), 0), MAX(c.updated_at)
+        FROM cart c
+        JOIN product_info p ON p.id = c.product_id
+        WHERE c.store_id = $1 AND c.status = 'in_cart'
+        GROUP BY c.user_id, c.cart_token
+        ORDER BY MAX(c.updated_at) DESC
+    `
+	rows, err := db.QueryContext(ctx, query, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list carts: %w", err)
+	}
+	defer rows.Close()
+
+	var carts []CartSummary
+	for rows.Next() {
+		cart := CartSummary{StoreID: storeID}
+		var userID sql.NullInt64
+		var token sql.NullString
+		if err := rows.Scan(&userID, &token, &cart.Lines, &cart.ItemCount, &cart.Subtotal, &cart.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cart: %w", err)
+		}
+		cart.Owner = CartOwner{UserID: int(userID.Int64), Token: token.String}
+		cart.Subtotal = roundMoney(cart.Subtotal)
+		carts = append(carts, cart)
+	}
+	return carts, rows.Err()
+}
+
+const orderColumns = `id, store_id, user_id, cart_token,
+        COALESCE(contact_name, ''), COALESCE(contact_email, ''), COALESCE(contact_phone, ''), COALESCE(shipping_address, ''),
+        total_amount, status, status_reason, status_changed_at, created_at`
+
+func scanOrder(row interface{ Scan(...interface{}) error }) (Order, error) {
+	var o Order
+	err := row.Scan(&o.ID, &o.StoreID, &o.UserID, &o.CartToken,
+		&o.Contact.Name, &o.Contact.Email, &o.Contact.Phone, &o.Contact.Address,
+		&o.TotalAmount, &o.Status, &o.StatusReason, &o.StatusChangedAt, &o.CreatedAt)
+	return o, err
+}
+
+// GetOrder อ่านคำสั่งซื้อตามรหัส
+func (pdb *PostgresDatabase) GetOrder(ctx context.Context, id int) (_ Order, err error) {
+	defer pdb.observe(ctx, "GetOrder", slog.Int("order_id", id))(&err)
+	db, release := pdb.acquire()
+	defer release()
+
+	order, err := scanOrder(db.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return order, ErrOrderNotFound
+	}
+	if err != nil {
+		return order, fmt.Errorf("failed to get order: %w", err)
+	}
+	return order, nil
+}
+
+// ListOrders คำสั่งซื้อล่าสุดตามเงื่อนไข เรียงจากใหม่ไปเก่า
+func (pdb *PostgresDatabase) ListOrders(ctx context.Context, filter OrderFilter) (_ []Order, err error) {
+	defer pdb.observe(ctx, "ListOrders", slog.Int("store_id", filter.StoreID), slog.String("status", filter.Status))(&err)
+	db, release := pdb.acquire()
+	defer release()
+
+	query := `SELECT ` + orderColumns + ` FROM orders
+        WHERE ($1 = 0 OR store_id = $1) AND ($2 = '' OR status = $2)
+        ORDER BY created_at DESC, id DESC
+        LIMIT $3`
+	rows, err := db.QueryContext(ctx, query, filter.StoreID, filter.Status, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// UpdateOrderStatus เปลี่ยนสถานะคำสั่งซื้อเป็น to เฉพาะเมื่อสถานะปัจจุบันอยู่ใน from
+// ถ้าสถานะปัจจุบันไม่อยู่ใน from คืน ErrConflict ที่บอกสถานะปัจจุบัน
+func (pdb *PostgresDatabase) UpdateOrderStatus(ctx context.Context, id int, from []string, to, reason string) (_ Order, err error) {
+	defer pdb.observe(ctx, "UpdateOrderStatus", slog.Int("order_id", id), slog.String("status", to))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+
+	query := `
+        UPDATE orders SET status = $2, status_reason = $3, status_changed_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND status = ANY($4)
+        RETURNING ` + orderColumns
+	order, err := scanOrder(db.QueryRowContext(ctx, query, id, to, reason, pq.Array(from)))
+	if err != sql.ErrNoRows {
+		if err != nil {
+			return order, fmt.Errorf("failed to update order status: %w", err)
+		}
+		return order, nil
+	}
+
+	var status string
+	err = db.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = $1`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return order, ErrOrderNotFound
+	}
+	if err != nil {
+		return order, fmt.Errorf("failed to get order status: %w", err)
+	}
+	return order, NewError(ErrConflict, fmt.Sprintf("order %d is %s and cannot be changed to %s", id, status, to))
+}
+
+// CreateStore ตรวจสอบข้อมูลร้านตามขนาดคอลัมน์ของ store_info ก่อนสร้าง
+func (bs *BookStore) CreateStore(ctx context.Context, store StoreInfo) (StoreInfo, error) {
+	store.StoreName = strings.TrimSpace(store.StoreName)
+	fields := map[string]string{}
+	if store.StoreName == "" {
+		fields["store_name"] = "is required"
+	}
+	for field, limit := range map[string]struct {
+		value string
+		max   int
+	}{
+		"store_name":   {store.StoreName, 255},
+		"logo_path":    {store.LogoPath, 255},
+		"address":      {store.Address, 255},
+		"phone_number": {store.PhoneNumber, 20},
+		"email":        {store.Email, 100},
+	} {
+		if len(limit.value) > limit.max {
+			fields[field] = fmt.Sprintf("must be at most %d characters", limit.max)
+		}
+	}
+	if err := validationError("invalid store", fields); err != nil {
+		return StoreInfo{}, err
+	}
+	return bs.db.CreateStore(ctx, store)
+}
+
+// AdjustProduct ตรวจว่าการแก้สต็อกและราคาสมเหตุสมผลก่อนบันทึก ราคาถูกปัดเป็นทศนิยม 2 ตำแหน่ง
+func (bs *BookStore) AdjustProduct(ctx context.Context, productID int, adj ProductAdjustment) (Product, error) {
+	if adj.Stock == nil && adj.StockDelta == 0 && adj.Price == nil {
+		return Product{}, NewError(ErrValidation, "nothing to change, set stock, stock_delta or price")
+	}
+	fields := map[string]string{}
+	switch {
+	case adj.Stock != nil && adj.StockDelta != 0:
+		fields["stock"] = "cannot be combined with stock_delta"
+	case adj.Stock != nil && *adj.Stock < 0:
+		fields["stock"] = "must be at least 0"
+	}
+	if adj.Price != nil {
+		price := roundMoney(*adj.Price)
+		switch {
+		case math.IsNaN(price) || price <= 0:
+			fields["price"] = "must be greater than 0"
+		case price > maxPrice:
+			fields["price"] = fmt.Sprintf("must be at most %.2f", maxPrice)
+		}
+		adj.Price = &price
+	}
+	if err := validationError("invalid product adjustment", fields); err != nil {
+		return Product{}, err
+	}
+	return bs.db.AdjustProduct(ctx, productID, adj)
+}
+
+// ListCarts ตะกร้าที่ยังเปิดอยู่ของร้าน คืน ErrStoreNotFound ถ้าไม่มีร้านนี้
+func (bs *BookStore) ListCarts(ctx context.Context, storeID int) ([]CartSummary, error) {
+	if _, err := bs.db.GetStoreInfoByID(ctx, storeID); err != nil {
+		return nil, err
+	}
+	return bs.db.ListCarts(ctx, storeID)
+}
+
+func (bs *BookStore) GetOrder(ctx context.Context, id int) (Order, error) {
+	return bs.db.GetOrder(ctx, id)
+}
+
+// ListOrders ตรวจสถานะที่ใช้กรองและจำกัดจำนวนผลลัพธ์ (ค่าเริ่มต้น 50 สูงสุด 1000)
+func (bs *BookStore) ListOrders(ctx context.Context, filter OrderFilter) ([]Order, error) {
+	fields := map[string]string{}
+	if filter.Status != "" && !slices.Contains(OrderStatuses, filter.Status) {
+		fields["status"] = "must be one of " + strings.Join(OrderStatuses, ", ")
+	}
+	switch {
+	case filter.Limit < 0:
+		fields["limit"] = "must be at least 1"
+	case filter.Limit > maxOrderLimit:
+		fields["limit"] = fmt.Sprintf("must be at most %d", maxOrderLimit)
+	case filter.Limit == 0:
+		filter.Limit = defaultOrderLimit
+	}
+	if err := validationError("invalid order filter", fields); err != nil {
+		return nil, err
+	}
+	return bs.db.ListOrders(ctx, filter)
+}
+
+// CancelOrder ยกเลิกคำสั่งซื้อที่ยังเป็น placed โดยไม่สนใจเงื่อนไขฝั่งลูกค้า
+// checkout ไม่ได้ตัดสต็อก การยกเลิกจึงไม่ต้องคืนสต็อก
+func (bs *BookStore) CancelOrder(ctx context.Context, id int, reason string) (Order, error) {
+	return bs.changeOrderStatus(ctx, id, []string{OrderPlaced}, OrderCancelled, reason)
+}
+
+// RefundOrder บันทึกว่าคืนเงินคำสั่งซื้อแล้ว ทั้งที่ยังเป็น placed และที่ถูกยกเลิกไปแล้ว
+// การชำระเงินยังเป็นแบบจำลอง จึงเปลี่ยนแค่สถานะ ไม่ได้ติดต่อระบบชำระเงิน
+func (bs *BookStore) RefundOrder(ctx context.Context, id int, reason string) (Order, error) {
+	return bs.changeOrderStatus(ctx, id, []string{OrderPlaced, OrderCancelled}, OrderRefunded, reason)
+}
+
+// changeOrderStatus ต้องมีเหตุผลทุกครั้งที่ผู้ดูแลเปลี่ยนสถานะ เพื่อให้ตรวจย้อนหลังได้
+func (bs *BookStore) changeOrderStatus(ctx context.Context, id int, from []string, to, reason string) (Order, error) {
+	reason = strings.TrimSpace(reason)
+	fields := map[string]string{}
+	switch {
+	case reason == "":
+		fields["reason"] = "is required"
+	case len(reason) > 500:
+		fields["reason"] = "must be at most 500 characters"
+	}
+	if err := validationError("invalid order status change", fields); err != nil {
+		return Order{}, err
+	}
+	return bs.db.UpdateOrderStatus(ctx, id, from, to, reason)
+}
@@ -0,0 +1,247 @@
+// apikeys.go
+package bookstore
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// scope ของ API key แต่ละ key ทำได้เฉพาะสิ่งที่ได้รับสิทธิ์
+const (
+	ScopeCatalogRead    = "catalog:read"
+	ScopeOrdersRead     = "orders:read"
+	ScopeInventoryWrite = "inventory:write"
+)
+
+// Scopes ทุก scope ที่ออกให้ได้
+var Scopes = []string{ScopeCatalogRead, ScopeOrdersRead, ScopeInventoryWrite}
+
+// StoreScopes scope ที่เข้าถึงข้อมูลภายในของร้าน (คำสั่งซื้อ สต็อก) key ที่มี scope เหล่านี้ต้องผูกกับร้าน
+var StoreScopes = []string{ScopeOrdersRead, ScopeInventoryWrite}
+
+// APIKeyPrefix นำหน้าทุก key เพื่อให้แยกออกจาก token อื่นได้ และให้เครื่องมือตรวจหาความลับ (secret scanner) จำได้
+const APIKeyPrefix = "msk_"
+
+// apiKeyTouchInterval บันทึก last_used_at ไม่ถี่กว่านี้ เพื่อไม่ให้ทุก request ต้องเขียนฐานข้อมูล
+const apiKeyTouchInterval = time.Minute
+
+// APIKey key ของ partner 1 ตัว ไม่มีตัว key จริงเพราะเก็บไว้แค่ hash
+type APIKey struct {
+	ID      int    `json:"id"`
+	Partner string `json:"partner"`
+	// Prefix ส่วนต้นของ key ใช้บอกว่าเป็น key ไหน
+	Prefix string   `json:"prefix"`
+	Scopes []string `json:"scopes"`
+	// StoreID ร้านที่ key นี้ใช้ได้ nil คือไม่ผูกกับร้าน ซึ่งใช้ได้เฉพาะ scope ที่ไม่อยู่ใน StoreScopes
+	StoreID    *int       `json:"store_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// HasScope คืนค่า true ถ้า key ได้รับสิทธิ์ scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessStore key ใช้ scope นี้กับร้าน storeID ได้หรือไม่ key ที่ผูกกับร้านใช้ได้เฉพาะร้านของตัวเอง
+// key ที่ไม่ผูกกับร้านใช้ scope ใน StoreScopes ไม่ได้เลย
+func (k APIKey) CanAccessStore(storeID int, scope string) bool {
+	if k.StoreID != nil {
+		return *k.StoreID == storeID
+	}
+	return !(APIKey{Scopes: StoreScopes}).HasScope(scope)
+}
+
+// error ของ API key
+var (
+	ErrAPIKeyNotFound = NewError(ErrNotFound, "api key not found")
+	ErrInvalidAPIKey  = NewError(ErrUnauthorized, "invalid or revoked api key")
+)
+
+// newAPIKey สุ่ม key ใหม่ คืน key จริง ส่วนต้นของ key และ hash ที่เก็บลงฐานข้อมูล
+func newAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key = APIKeyPrefix + hex.EncodeToString(b)
+	return key, key[:len(APIKeyPrefix)+8], hashAPIKey(key), nil
+}
+
+// hashAPIKey key สุ่มยาว 192 บิตจึงใช้ SHA-256 ได้โดยไม่ต้องใช้ hash แบบช้าอย่าง bcrypt
+// และค้นหาด้วย hash ได้ตรงๆ ผ่าน unique index
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+const apiKeyColumns = `id, partner, key_prefix, scopes, store_id, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.Partner, &k.Prefix, pq.Array(&k.Scopes), &k.StoreID, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	return k, err
+}
+
+// CreateAPIKey ออก key ใหม่ให้ partner คืนข้อมูล key และตัว key จริงซึ่งดูได้ครั้งเดียวเท่านั้น
+// storeID เป็น nil ถ้า key ไม่ผูกกับร้าน
+func (pdb *PostgresDatabase) CreateAPIKey(ctx context.Context, partner string, storeID *int, scopes []string) (_ APIKey, _ string, err error) {
+	defer pdb.observe(ctx, "CreateAPIKey", slog.String("partner", partner))(&err)
+	db, release := pdb.acquire()
+	defer release()
+
+	key, prefix, hash, err := newAPIKey()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	row := db.QueryRowContext(ctx, `INSERT INTO api_keys (partner, key_prefix, key_hash, scopes, store_id)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING `+apiKeyColumns, partner, prefix, hash, pq.Array(scopes), storeID)
+	apiKey, err := scanAPIKey(row)
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+	return apiKey, key, nil
+}
+
+// ListAPIKeys รายการ key ทั้งหมดรวมที่ถูกยกเลิกแล้ว
+func (pdb *PostgresDatabase) ListAPIKeys(ctx context.Context) (_ []APIKey, err error) {
+	defer pdb.observe(ctx, "ListAPIKeys")(&err)
+	db, release := pdb.acquire()
+	defer release()
+
+	rows, err := db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY partner, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey ยกเลิก key มีผลทันทีกับ request ถัดไป การยกเลิกซ้ำไม่ถือว่าผิด
+func (pdb *PostgresDatabase) RevokeAPIKey(ctx context.Context, id int) (err error) {
+	defer pdb.observe(ctx, "RevokeAPIKey", slog.Int("api_key_id", id))(&err)
+	db, release := pdb.acquire()
+	defer release()
+
+	res, err := db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP) WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey หา key ที่ยังไม่ถูกยกเลิกจากตัว key จริง และบันทึกเวลาที่ใช้ล่าสุด
+// อ่านจาก primary เสมอ เพื่อให้การยกเลิก key มีผลทันทีแม้ replica จะตามไม่ทัน
+func (pdb *PostgresDatabase) AuthenticateAPIKey(ctx context.Context, key string) (_ APIKey, err error) {
+	defer pdb.observe(ctx, "AuthenticateAPIKey")(&err)
+	db, release := pdb.acquire()
+	defer release()
+
+	row := db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, hashAPIKey(key))
+	apiKey, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("failed to look up api key: %w", err)
+	}
+
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		_, err := db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`, apiKey.ID)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to record api key usage", slog.Int("api_key_id", apiKey.ID), slog.Any("error", err))
+		}
+	}
+	return apiKey, nil
+}
+
+// CreateAPIKey ตรวจสอบชื่อ partner, scope และร้านก่อนออก key
+// key ที่มี scope ใน StoreScopes ต้องระบุร้าน ไม่อย่างนั้นจะเข้าถึงข้อมูลของทุกร้านได้
+func (bs *BookStore) CreateAPIKey(ctx context.Context, partner string, storeID *int, scopes []string) (APIKey, string, error) {
+	partner = strings.TrimSpace(partner)
+	fields := map[string]string{}
+	switch {
+	case partner == "":
+		fields["partner"] = "is required"
+	case len(partner) > 100:
+		fields["partner"] = "must be at most 100 characters"
+	}
+
+	var unknown []string
+	for _, scope := range scopes {
+		if !(APIKey{Scopes: Scopes}).HasScope(scope) {
+			unknown = append(unknown, scope)
+		}
+	}
+	switch {
+	case len(scopes) == 0:
+		fields["scopes"] = "at least one scope is required (" + strings.Join(Scopes, ", ") + ")"
+	case len(unknown) > 0:
+		fields["scopes"] = "unknown scopes " + strings.Join(unknown, ", ") + " (expected " + strings.Join(Scopes, ", ") + ")"
+	}
+
+	if storeID == nil {
+		var storeScopes []string
+		for _, scope := range scopes {
+			if (APIKey{Scopes: StoreScopes}).HasScope(scope) {
+				storeScopes = append(storeScopes, scope)
+			}
+		}
+		if len(storeScopes) > 0 {
+			fields["store_id"] = "is required for scopes " + strings.Join(storeScopes, ", ")
+		}
+	} else if _, err := bs.db.GetStoreInfoByID(ctx, *storeID); errors.Is(err, ErrStoreNotFound) {
+		fields["store_id"] = "store not found"
+	} else if err != nil {
+		return APIKey{}, "", err
+	}
+	if err := validationError("invalid api key", fields); err != nil {
+		return APIKey{}, "", err
+	}
+	return bs.db.CreateAPIKey(ctx, partner, storeID, scopes)
+}
+
+func (bs *BookStore) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	return bs.db.ListAPIKeys(ctx)
+}
+
+func (bs *BookStore) RevokeAPIKey(ctx context.Context, id int) error {
+	return bs.db.RevokeAPIKey(ctx, id)
+}
+
+// AuthenticateAPIKey key ที่ไม่ได้ขึ้นต้นด้วย msk_ ไม่ต้องค้นในฐานข้อมูล
+func (bs *BookStore) AuthenticateAPIKey(ctx context.Context, key string) (APIKey, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	return bs.db.AuthenticateAPIKey(ctx, key)
+}
@@ -3,9 +3,14 @@ package bookstore
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
-	"errors"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
+	"math"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -47,93 +52,169 @@ type Product struct {
 	ImagePath     string    `json:"image_path"`
 }
 
-// BookDatabase เป็น Interface ที่กำหนดว่า Book Database ต้องทำอะไรได้บ้าง
-type BookDatabase interface {
-	GetAllStoreInfo(ctx context.Context) ([]StoreInfo, error) // เพิ่มฟังก์ชันนี้
-	Close() error
-	Ping() error
-	Reconnect(connStr string) error
-	GetStoreInfoByID(ctx context.Context, id int) (StoreInfo, error)
-	GetProductsByStore(ctx context.Context, storeID int) ([]Product, error)
-	GetNewProductsByStore(ctx context.Context, storeID int) ([]Product, error)
-	SearchProducts(ctx context.Context, searchQuery string) ([]Product, error)
-	GetProduct(ctx context.Context, id int) (Product, error)
-	SearchProductsByStore(ctx context.Context, searchQuery string, storeID int) ([]Product, error)
-	GetAllProductsByStore(ctx context.Context, storeID int, sortOrder string) ([]Product, error)
-	GetProductsByCategoryAndStore(ctx context.Context, storeID int, category string) ([]Product, error)
-	GetALLProductsByCategory(ctx context.Context, category string) ([]Product, error)
-	AddToCart(ctx context.Context, storeID, productID, quantity int) error
-	GetCartItemsByStore(ctx context.Context, storeID int) ([]Product, error)
-	DeleteProductFromCart(ctx context.Context, storeID, productID int) error
-	Checkout(ctx context.Context, storeID int) error
-	CheckoutCart(ctx context.Context, storeID int) error
+// CartOwner ระบุเจ้าของตะกร้า ถ้ามี UserID แสดงว่าเป็นผู้ใช้ที่ล็อกอินแล้ว
+// ถ้าไม่มีจะใช้ Token ที่เซิร์ฟเวอร์ออกให้แขก (guest) แทน
+type CartOwner struct {
+	UserID int    `json:"user_id,omitempty"`
+	Token  string `json:"cart_token,omitempty"`
 }
 
-// PostgresDatabase เป็น struct ที่เชื่อมต่อกับ PostgreSQL Database จริง
-type PostgresDatabase struct {
-	db *sql.DB
+// IsZero คืนค่า true ถ้ายังไม่รู้ว่าตะกร้าเป็นของใคร
+func (o CartOwner) IsZero() bool {
+	return o.UserID == 0 && o.Token == ""
 }
 
-// NewPostgresDatabase สร้าง PostgresDatabase ใหม่และเชื่อมต่อกับฐานข้อมูล
-func NewPostgresDatabase(connStr string) (*PostgresDatabase, error) {
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %v", err)
+// condition สร้างเงื่อนไข WHERE สำหรับเลือกแถวใน cart ของเจ้าของนี้ โดยใช้ placeholder ลำดับที่ n
+func (o CartOwner) condition(column string, n int) (string, interface{}) {
+	if o.UserID != 0 {
+		return fmt.Sprintf("%suser_id = $%d", column, n), o.UserID
 	}
+	return fmt.Sprintf("%scart_token = $%d", column, n), o.Token
+}
 
-	// ตั้งค่า connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(10)
-	db.SetConnMaxLifetime(5 * time.Minute)
-
-	// ทดสอบการเชื่อมต่อด้วย context
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := db.PingContext(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %v", err)
+// NewCartToken สุ่ม token สำหรับตะกร้าของแขก
+func NewCartToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate cart token: %w", err)
 	}
+	return hex.EncodeToString(b), nil
+}
 
-	return &PostgresDatabase{db: db}, nil
+// MaxCartItemQuantity จำนวนสูงสุดของสินค้า 1 รายการในตะกร้า กันไม่ให้ตะกร้าเดียวกักสต็อกทั้งร้านไว้
+const MaxCartItemQuantity = 99
+
+// CartItem รายการสินค้า 1 บรรทัดในตะกร้า ID คือรหัสบรรทัด (cart.id) ไม่ใช่รหัสสินค้า
+type CartItem struct {
+	ID             int       `json:"id"`
+	ProductID      int       `json:"product_id"`
+	StoreID        int       `json:"store_id"`
+	ProductName    string    `json:"product_name"`
+	Category       string    `json:"category"`
+	Brand          string    `json:"brand"`
+	Model          string    `json:"model"`
+	ImagePath      string    `json:"image_path"`
+	UnitPrice      float64   `json:"unit_price"`
+	Quantity       int       `json:"quantity"`
+	LineTotal      float64   `json:"line_total"`
+	AvailableStock int       `json:"available_stock"`
+	AddedAt        time.Time `json:"added_at"`
 }
 
-func (pdb *PostgresDatabase) Close() error {
-	return pdb.db.Close()
+// Cart ตะกร้าสินค้าของร้านหนึ่งพร้อมยอดรวม
+type Cart struct {
+	StoreID   int        `json:"store_id"`
+	Items     []CartItem `json:"items"`
+	ItemCount int        `json:"item_count"`
+	Subtotal  float64    `json:"subtotal"`
 }
 
-func (pdb *PostgresDatabase) Ping() error {
-	if pdb == nil {
-		return errors.New("database connection is not initialized")
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	return pdb.db.PingContext(ctx)
+// roundMoney ปัดเศษจำนวนเงินให้เหลือทศนิยม 2 ตำแหน่ง
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
 }
 
-func (pdb *PostgresDatabase) Reconnect(connStr string) error {
-	if pdb.db != nil {
-		pdb.db.Close()
+// newCart รวมยอดของแต่ละบรรทัดและยอดรวมทั้งตะกร้า
+func newCart(storeID int, items []CartItem) Cart {
+	cart := Cart{StoreID: storeID, Items: items}
+	if cart.Items == nil {
+		cart.Items = []CartItem{}
 	}
-
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return fmt.Errorf("failed to connect to database: %v", err)
+	for i := range cart.Items {
+		item := &cart.Items[i]
+		item.LineTotal = roundMoney(item.UnitPrice * float64(item.Quantity))
+		cart.ItemCount += item.Quantity
+		cart.Subtotal += item.LineTotal
 	}
+	cart.Subtotal = roundMoney(cart.Subtotal)
+	return cart
+}
 
-	// ตั้งค่า connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(10)
-	db.SetConnMaxLifetime(5 * time.Minute)
+// AbandonedCartEvent สินค้าในตะกร้าที่ถูกทิ้งไว้จนหมดอายุ เก็บไว้ให้เจ้าของร้านนำไปใช้ติดตามลูกค้า
+type AbandonedCartEvent struct {
+	ID         int  `json:"id"`
+	CartItemID int  `json:"cart_item_id"`
+	StoreID    int  `json:"store_id"`
+	UserID     *int `json:"user_id,omitempty"`
+	// GuestID ค่า hash ทางเดียวของ token ตะกร้าแขก ใช้จับกลุ่มรายการของแขกคนเดียวกันได้
+	// ไม่ส่ง token จริงออกไปเพราะ token คือสิทธิ์เข้าถึงตะกร้าที่แขกคนนั้นยังใช้อยู่
+	GuestID        *string   `json:"guest_id,omitempty"`
+	ProductID      int       `json:"product_id"`
+	Quantity       int       `json:"quantity"`
+	UnitPrice      float64   `json:"unit_price"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+	AbandonedAt    time.Time `json:"abandoned_at"`
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// ContactInfo ข้อมูลติดต่อของผู้สั่งซื้อ ใช้ตอน checkout แบบไม่มีบัญชี
+type ContactInfo struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Phone   string `json:"phone"`
+	Address string `json:"address"`
+}
 
-	if err := db.PingContext(ctx); err != nil {
-		return fmt.Errorf("failed to ping database: %v", err)
-	}
+// สถานะของคำสั่งซื้อ คำสั่งซื้อเริ่มที่ placed แล้วผู้ดูแลเปลี่ยนเป็น cancelled หรือ refunded ได้
+const (
+	OrderPlaced    = "placed"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
 
-	pdb.db = db
-	return nil
+// Order คำสั่งซื้อที่สร้างขึ้นตอน checkout
+type Order struct {
+	ID          int         `json:"id"`
+	StoreID     int         `json:"store_id"`
+	UserID      *int        `json:"user_id,omitempty"`
+	CartToken   *string     `json:"cart_token,omitempty"`
+	Contact     ContactInfo `json:"contact"`
+	TotalAmount float64     `json:"total_amount"`
+	Status      string      `json:"status"`
+	// StatusReason เหตุผลที่ผู้ดูแลให้ไว้ตอนยกเลิกหรือคืนเงิน
+	StatusReason    *string    `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// BookDatabase เป็น Interface ที่กำหนดว่า Book Database ต้องทำอะไรได้บ้าง
+type BookDatabase interface {
+	GetAllStoreInfo(ctx context.Context) ([]StoreInfo, error) // เพิ่มฟังก์ชันนี้
+	Close() error
+	Ping() error
+	Reconnect() error
+	GetStoreInfoByID(ctx context.Context, id int) (StoreInfo, error)
+	GetProductsByStore(ctx context.Context, storeID int) ([]Product, error)
+	GetNewProductsByStore(ctx context.Context, storeID int) ([]Product, error)
+	SearchProducts(ctx context.Context, searchQuery string) ([]Product, error)
+	GetProduct(ctx context.Context, id int) (Product, error)
+	SearchProductsByStore(ctx context.Context, searchQuery string, storeID int) ([]Product, error)
+	GetAllProductsByStore(ctx context.Context, storeID int, sortOrder string) ([]Product, error)
+	GetProductsByCategoryAndStore(ctx context.Context, storeID int, category string) ([]Product, error)
+	GetALLProductsByCategory(ctx context.Context, category string) ([]Product, error)
+	AddToCart(ctx context.Context, owner CartOwner, storeID, productID, quantity int) (bool, error)
+	GetCart(ctx context.Context, owner CartOwner, storeID int) (Cart, error)
+	UpdateCartItemQuantity(ctx context.Context, owner CartOwner, storeID, itemID, quantity int) error
+	DeleteProductFromCart(ctx context.Context, owner CartOwner, storeID, productID int) error
+	MergeGuestCart(ctx context.Context, token string, userID int) error
+	Checkout(ctx context.Context, storeID int) error
+	CheckoutCart(ctx context.Context, owner CartOwner, storeID int, contact ContactInfo) (Order, error)
+	ExpireIdleCarts(ctx context.Context, idleSince time.Time) (int, error)
+	GetAbandonedCartEvents(ctx context.Context, storeID int, since time.Time) ([]AbandonedCartEvent, error)
+	UpsertStore(ctx context.Context, store StoreInfo) (StoreInfo, error)
+	UpsertProduct(ctx context.Context, product Product) (Product, error)
+	UpsertCategory(ctx context.Context, category Category) (Category, error)
+	ImportProducts(ctx context.Context, products []Product, dryRun bool) ([]ProductUpsert, error)
+	EachProductByStore(ctx context.Context, storeID int, activeOnly bool, fn func(Product) error) error
+	CreateAPIKey(ctx context.Context, partner string, storeID *int, scopes []string) (APIKey, string, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+	AuthenticateAPIKey(ctx context.Context, key string) (APIKey, error)
+	CreateStore(ctx context.Context, store StoreInfo) (StoreInfo, error)
+	AdjustProduct(ctx context.Context, productID int, adj ProductAdjustment) (Product, error)
+	ListCarts(ctx context.Context, storeID int) ([]CartSummary, error)
+	GetOrder(ctx context.Context, id int) (Order, error)
+	ListOrders(ctx context.Context, filter OrderFilter) ([]Order, error)
+	UpdateOrderStatus(ctx context.Context, id int, from []string, to, reason string) (Order, error)
 }
 
 // BookStore เป็นโครงสร้างหลักของ Application
@@ -158,9 +239,12 @@ func (bs *BookStore) Ping() error {
 	return bs.db.Ping()
 }
 
-func (pdb *PostgresDatabase) GetAllStoreInfo(ctx context.Context) ([]StoreInfo, error) {
+func (pdb *PostgresDatabase) GetAllStoreInfo(ctx context.Context) (_ []StoreInfo, err error) {
+	defer pdb.observe(ctx, "GetAllStoreInfo")(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	query := `SELECT id, logo_path, store_name, description, address, phone_number, email FROM store_info`
-	rows, err := pdb.db.QueryContext(ctx, query) // ใช้ pdb.db ซึ่งเป็น *sql.DB
+	rows, err := db.QueryContext(ctx, query) // db คือ pool ที่ acquireRead จองไว้ (replica หรือ primary) คืนด้วย release
 	if err != nil {
 		return nil, err
 	}
@@ -187,10 +271,13 @@ func (pdb *PostgresDatabase) GetAllStoreInfo(ctx context.Context) ([]StoreInfo,
 	return stores, nil
 }
 
-func (pdb *PostgresDatabase) GetStoreInfoByID(ctx context.Context, id int) (StoreInfo, error) {
+func (pdb *PostgresDatabase) GetStoreInfoByID(ctx context.Context, id int) (_ StoreInfo, err error) {
+	defer pdb.observe(ctx, "GetStoreInfoByID", slog.Int("store_id", id))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	var store StoreInfo
 	query := `SELECT id, logo_path, store_name, description, address, phone_number, email FROM store_info WHERE id = $1`
-	err := pdb.db.QueryRowContext(ctx, query, id).Scan(
+	err = db.QueryRowContext(ctx, query, id).Scan(
 		&store.ID,
 		&store.LogoPath,
 		&store.StoreName,
@@ -201,20 +288,23 @@ func (pdb *PostgresDatabase) GetStoreInfoByID(ctx context.Context, id int) (Stor
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return store, fmt.Errorf("store not found")
+			return store, ErrStoreNotFound
 		}
-		return store, fmt.Errorf("failed to get store: %v", err)
+		return store, fmt.Errorf("failed to get store: %w", err)
 	}
 	return store, nil
 }
 
 // เพิ่มฟังก์ชันใน PostgresDatabase สำหรับการดึงข้อมูลสินค้าจาก store_id
-func (pdb *PostgresDatabase) GetProductsByStore(ctx context.Context, storeID int) ([]Product, error) {
+func (pdb *PostgresDatabase) GetProductsByStore(ctx context.Context, storeID int) (_ []Product, err error) {
+	defer pdb.observe(ctx, "GetProductsByStore", slog.Int("store_id", storeID))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	query := `SELECT id, product_name, price, quantity, created_at, updated_at, category, brand, model, store_id, is_recommended, image_path
                 FROM product_info WHERE store_id = $1 ORDER BY created_at DESC LIMIT 3;;`
-	rows, err := pdb.db.QueryContext(ctx, query, storeID)
+	rows, err := db.QueryContext(ctx, query, storeID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get products: %v", err)
+		return nil, fmt.Errorf("failed to get products: %w", err)
 	}
 	defer rows.Close()
 
@@ -233,24 +323,27 @@ func (pdb *PostgresDatabase) GetProductsByStore(ctx context.Context, storeID int
 			&product.StoreID,
 			&product.IsRecommended,
 			&product.ImagePath); err != nil {
-			return nil, fmt.Errorf("failed to scan product data: %v", err)
+			return nil, fmt.Errorf("failed to scan product data: %w", err)
 		}
 		products = append(products, product)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %v", err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
 	return products, nil
 }
 
-func (pdb *PostgresDatabase) GetNewProductsByStore(ctx context.Context, storeID int) ([]Product, error) {
+func (pdb *PostgresDatabase) GetNewProductsByStore(ctx context.Context, storeID int) (_ []Product, err error) {
+	defer pdb.observe(ctx, "GetNewProductsByStore", slog.Int("store_id", storeID))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	query := `SELECT id, product_name, price, quantity, created_at, updated_at, category, brand, model, store_id, is_recommended, image_path 
 				FROM product_info WHERE store_id = $1 ORDER BY created_at desc LIMIT 1;`
-	rows, err := pdb.db.QueryContext(ctx, query, storeID)
+	rows, err := db.QueryContext(ctx, query, storeID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get products: %v", err)
+		return nil, fmt.Errorf("failed to get products: %w", err)
 	}
 	defer rows.Close()
 
@@ -269,19 +362,22 @@ func (pdb *PostgresDatabase) GetNewProductsByStore(ctx context.Context, storeID
 			&product.StoreID,
 			&product.IsRecommended,
 			&product.ImagePath); err != nil {
-			return nil, fmt.Errorf("failed to scan product data: %v", err)
+			return nil, fmt.Errorf("failed to scan product data: %w", err)
 		}
 		products = append(products, product)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %v", err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
 	return products, nil
 }
 
-func (pdb *PostgresDatabase) SearchProducts(ctx context.Context, searchQuery string) ([]Product, error) {
+func (pdb *PostgresDatabase) SearchProducts(ctx context.Context, searchQuery string) (_ []Product, err error) {
+	defer pdb.observe(ctx, "SearchProducts", slog.String("query", searchQuery))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	// Query ที่จะค้นหาผลิตภัณฑ์ที่ตรงกับคำค้นหาในบางส่วน
 	query := `
         SELECT id, product_name, price, quantity, created_at, updated_at, category, brand, model,  store_id, is_recommended, image_path 
@@ -291,9 +387,9 @@ func (pdb *PostgresDatabase) SearchProducts(ctx context.Context, searchQuery str
     `
 
 	// ใช้ '%' เพื่อให้ค้นหาคำที่มีตัวอักษรตรงส่วนใดส่วนหนึ่ง เช่น 'P' จะเจอ 'phone'
-	rows, err := pdb.db.QueryContext(ctx, query, "%"+searchQuery+"%")
+	rows, err := db.QueryContext(ctx, query, containsPattern(searchQuery))
 	if err != nil {
-		return nil, fmt.Errorf("failed to search products: %v", err)
+		return nil, fmt.Errorf("failed to search products: %w", err)
 	}
 	defer rows.Close()
 
@@ -312,23 +408,35 @@ func (pdb *PostgresDatabase) SearchProducts(ctx context.Context, searchQuery str
 			&product.StoreID,
 			&product.IsRecommended,
 			&product.ImagePath); err != nil {
-			return nil, fmt.Errorf("failed to scan product data: %v", err)
+			return nil, fmt.Errorf("failed to scan product data: %w", err)
 		}
 		products = append(products, product)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %v", err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
 	return products, nil
 }
 
+// likeEscaper ใส่ \ หน้าอักขระพิเศษของ LIKE/ILIKE (\ เป็น escape character เริ่มต้นของ PostgreSQL)
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern pattern ของ ILIKE ที่ตรงกับข้อความที่มี s อยู่ส่วนใดก็ได้
+// % และ _ ในคำค้นถูกค้นตามตัวอักษร ไม่อย่างนั้นคำค้น "%" จะได้สินค้าทุกชิ้น
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
 // แสดงสินค้า 1 อัน
-func (pdb *PostgresDatabase) GetProduct(ctx context.Context, id int) (Product, error) {
+func (pdb *PostgresDatabase) GetProduct(ctx context.Context, id int) (_ Product, err error) {
+	defer pdb.observe(ctx, "GetProduct", slog.Int("product_id", id))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	var product Product
 	// แก้ไข query เพื่อให้ตรงกับตารางและฟิลด์ของ Product
-	err := pdb.db.QueryRowContext(ctx, `
+	err = db.QueryRowContext(ctx, `
         SELECT id, product_name, price, quantity, created_at, updated_at, category, brand, model, store_id, is_recommended, image_path 
         FROM product_info WHERE id = $1`, id).Scan(
 		&product.ID,
@@ -345,14 +453,17 @@ func (pdb *PostgresDatabase) GetProduct(ctx context.Context, id int) (Product, e
 		&product.ImagePath)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return product, fmt.Errorf("product not found")
+			return product, ErrProductNotFound
 		}
-		return product, fmt.Errorf("failed to get product: %v", err)
+		return product, fmt.Errorf("failed to get product: %w", err)
 	}
 	return product, nil
 }
 
-func (pdb *PostgresDatabase) SearchProductsByStore(ctx context.Context, searchQuery string, storeID int) ([]Product, error) {
+func (pdb *PostgresDatabase) SearchProductsByStore(ctx context.Context, searchQuery string, storeID int) (_ []Product, err error) {
+	defer pdb.observe(ctx, "SearchProductsByStore", slog.String("query", searchQuery), slog.Int("store_id", storeID))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	// Query ที่จะค้นหาผลิตภัณฑ์ที่ตรงกับคำค้นหาในบางส่วนและเฉพาะร้านที่กำหนด
 	query := `
         SELECT id, product_name, price, quantity, created_at, updated_at, category, brand, model, store_id, is_recommended, image_path 
@@ -363,9 +474,9 @@ func (pdb *PostgresDatabase) SearchProductsByStore(ctx context.Context, searchQu
     `
 
 	// ใช้ '%' เพื่อให้ค้นหาคำที่มีตัวอักษรตรงส่วนใดส่วนหนึ่ง เช่น 'P' จะเจอ 'phone'
-	rows, err := pdb.db.QueryContext(ctx, query, "%"+searchQuery+"%", storeID)
+	rows, err := db.QueryContext(ctx, query, containsPattern(searchQuery), storeID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search products: %v", err)
+		return nil, fmt.Errorf("failed to search products: %w", err)
 	}
 	defer rows.Close()
 
@@ -384,19 +495,22 @@ func (pdb *PostgresDatabase) SearchProductsByStore(ctx context.Context, searchQu
 			&product.StoreID,
 			&product.IsRecommended,
 			&product.ImagePath); err != nil {
-			return nil, fmt.Errorf("failed to scan product data: %v", err)
+			return nil, fmt.Errorf("failed to scan product data: %w", err)
 		}
 		products = append(products, product)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %v", err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
 	return products, nil
 }
 
-func (pdb *PostgresDatabase) GetAllProductsByStore(ctx context.Context, storeID int, sortOrder string) ([]Product, error) {
+func (pdb *PostgresDatabase) GetAllProductsByStore(ctx context.Context, storeID int, sortOrder string) (_ []Product, err error) {
+	defer pdb.observe(ctx, "GetAllProductsByStore", slog.Int("store_id", storeID), slog.String("sort", sortOrder))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	// เรียงลำดับผลตามราคาตามค่าที่ส่งเข้ามา
 	var orderByClause string
 	if sortOrder == "asc" {
@@ -416,9 +530,9 @@ func (pdb *PostgresDatabase) GetAllProductsByStore(ctx context.Context, storeID
         %s;
     `, orderByClause)
 
-	rows, err := pdb.db.QueryContext(ctx, query, storeID)
+	rows, err := db.QueryContext(ctx, query, storeID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get products: %v", err)
+		return nil, fmt.Errorf("failed to get products: %w", err)
 	}
 	defer rows.Close()
 
@@ -439,28 +553,31 @@ func (pdb *PostgresDatabase) GetAllProductsByStore(ctx context.Context, storeID
 			&product.IsRecommended,
 			&product.ImagePath,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan product data: %v", err)
+			return nil, fmt.Errorf("failed to scan product data: %w", err)
 		}
 		products = append(products, product)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %v", err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
 	return products, nil
 }
 
-func (pdb *PostgresDatabase) GetProductsByCategoryAndStore(ctx context.Context, storeID int, category string) ([]Product, error) {
+func (pdb *PostgresDatabase) GetProductsByCategoryAndStore(ctx context.Context, storeID int, category string) (_ []Product, err error) {
+	defer pdb.observe(ctx, "GetProductsByCategoryAndStore", slog.Int("store_id", storeID), slog.String("category", category))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	query := `
         SELECT id, product_name, price, quantity, created_at, updated_at, category, brand, model, store_id, is_recommended, image_path
         FROM product_info 
         WHERE store_id = $1 AND category = $2
         ORDER BY created_at DESC
     `
-	rows, err := pdb.db.QueryContext(ctx, query, storeID, category)
+	rows, err := db.QueryContext(ctx, query, storeID, category)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get products by category: %v", err)
+		return nil, fmt.Errorf("failed to get products by category: %w", err)
 	}
 	defer rows.Close()
 
@@ -479,19 +596,22 @@ func (pdb *PostgresDatabase) GetProductsByCategoryAndStore(ctx context.Context,
 			&product.StoreID,
 			&product.IsRecommended,
 			&product.ImagePath); err != nil {
-			return nil, fmt.Errorf("failed to scan product data: %v", err)
+			return nil, fmt.Errorf("failed to scan product data: %w", err)
 		}
 		products = append(products, product)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %v", err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
 	return products, nil
 }
 
-func (pdb *PostgresDatabase) GetALLProductsByCategory(ctx context.Context, category string) ([]Product, error) {
+func (pdb *PostgresDatabase) GetALLProductsByCategory(ctx context.Context, category string) (_ []Product, err error) {
+	defer pdb.observe(ctx, "GetALLProductsByCategory", slog.String("category", category))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
 	// ดึงข้อมูลสินค้าทุกตัวที่ตรงกับหมวดหมู่ที่ระบุ
 	query := `
         SELECT id, product_name, price, quantity, created_at, updated_at, category, brand, model, store_id, is_recommended, image_path
@@ -500,9 +620,9 @@ func (pdb *PostgresDatabase) GetALLProductsByCategory(ctx context.Context, categ
         ORDER BY created_at DESC
     `
 
-	rows, err := pdb.db.QueryContext(ctx, query, category)
+	rows, err := db.QueryContext(ctx, query, category)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get products by category: %v", err)
+		return nil, fmt.Errorf("failed to get products by category: %w", err)
 	}
 	defer rows.Close()
 
@@ -521,13 +641,13 @@ func (pdb *PostgresDatabase) GetALLProductsByCategory(ctx context.Context, categ
 			&product.StoreID,
 			&product.IsRecommended,
 			&product.ImagePath); err != nil {
-			return nil, fmt.Errorf("failed to scan product data: %v", err)
+			return nil, fmt.Errorf("failed to scan product data: %w", err)
 		}
 		products = append(products, product)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %v", err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
 	return products, nil
@@ -573,120 +693,340 @@ func (bs *BookStore) GetALLProductsByCategory(ctx context.Context, category stri
 	return bs.db.GetALLProductsByCategory(ctx, category)
 }
 
-func (pdb *PostgresDatabase) AddToCart(ctx context.Context, storeID, productID, quantity int) error {
-	// ตรวจสอบว่ามีสินค้านี้อยู่ในตะกร้าหรือไม่
+// lockProductForCart ตรวจสอบว่าสินค้ามีอยู่จริง เป็นของร้านนี้ และยังเปิดขาย แล้วคืนจำนวนคงเหลือในสต็อก
+// ล็อกแถวสินค้าไว้จนจบ transaction เพื่อไม่ให้คำสั่งอื่นแก้ตะกร้าของสินค้านี้พร้อมกัน
+func lockProductForCart(ctx context.Context, tx *sql.Tx, storeID, productID int) (int, error) {
+	var (
+		productStoreID sql.NullInt64
+		isActive       bool
+		stock          int
+	)
+	query := `SELECT store_id, is_active, quantity FROM product_info WHERE id = $1 FOR UPDATE`
+	err := tx.QueryRowContext(ctx, query, productID).Scan(&productStoreID, &isActive, &stock)
+	if err == sql.ErrNoRows {
+		return 0, ErrProductNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	if !productStoreID.Valid || int(productStoreID.Int64) != storeID {
+		return 0, ErrProductNotInStore
+	}
+	if !isActive {
+		return 0, ErrProductInactive
+	}
+	return stock, nil
+}
+
+// AddToCart เพิ่มสินค้าลงตะกร้า คืน true ถ้าเป็นสินค้าชิ้นแรกของตะกร้าร้านนี้ (เริ่มตะกร้าใหม่)
+func (pdb *PostgresDatabase) AddToCart(ctx context.Context, owner CartOwner, storeID, productID, quantity int) (_ bool, err error) {
+	defer pdb.observe(ctx, "AddToCart", ownerAttr(owner), slog.Int("store_id", storeID), slog.Int("product_id", productID), slog.Int("quantity", quantity))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+	if quantity <= 0 {
+		return false, ErrInvalidQuantity
+	}
+	if quantity > MaxCartItemQuantity {
+		return false, ErrQuantityTooLarge
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stock, err := lockProductForCart(ctx, tx, storeID, productID)
+	if err != nil {
+		return false, err
+	}
+
+	ownerCond, ownerArg := owner.condition("", 3)
+
+	// ตรวจสอบว่ามีสินค้านี้อยู่ในตะกร้าของเจ้าของคนนี้หรือไม่
 	var existingQuantity int
-	query := `SELECT quantity FROM cart WHERE store_id = $1 AND product_id = $2 AND status = 'in_cart'`
-	err := pdb.db.QueryRowContext(ctx, query, storeID, productID).Scan(&existingQuantity)
+	query := `SELECT quantity FROM cart WHERE store_id = $1 AND product_id = $2 AND status = 'in_cart' AND ` + ownerCond
+	err = tx.QueryRowContext(ctx, query, storeID, productID, ownerArg).Scan(&existingQuantity)
 	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("failed to check existing item in cart: %v", err)
+		return false, fmt.Errorf("failed to check existing item in cart: %w", err)
+	}
+	inCart := err == nil
+
+	// จำนวนรวมหลังเพิ่มต้องไม่เกินขีดจำกัดต่อรายการและไม่เกินสต็อก
+	if existingQuantity+quantity > MaxCartItemQuantity {
+		return false, ErrQuantityTooLarge
+	}
+	if existingQuantity+quantity > stock {
+		return false, ErrOutOfStock
 	}
 
 	// ถ้ามีสินค้านี้อยู่แล้ว ให้เพิ่มจำนวน
-	if err == nil {
-		updateQuery := `UPDATE cart SET quantity = quantity + $1 WHERE store_id = $2 AND product_id = $3 AND status = 'in_cart'`
-		_, err = pdb.db.ExecContext(ctx, updateQuery, quantity, storeID, productID)
+	created := false
+	if inCart {
+		ownerCond, ownerArg = owner.condition("", 4)
+		updateQuery := `UPDATE cart SET quantity = quantity + $1, updated_at = CURRENT_TIMESTAMP WHERE store_id = $2 AND product_id = $3 AND status = 'in_cart' AND ` + ownerCond
+		_, err = tx.ExecContext(ctx, updateQuery, quantity, storeID, productID, ownerArg)
 		if err != nil {
-			return fmt.Errorf("failed to update quantity in cart: %v", err)
+			return false, fmt.Errorf("failed to update quantity in cart: %w", err)
 		}
 	} else {
 		// ถ้ายังไม่มีในตะกร้า ให้เพิ่มรายการใหม่
+		var userID interface{}
+		var token interface{}
+		if owner.UserID != 0 {
+			userID = owner.UserID
+		} else {
+			token = owner.Token
+		}
+		ownerCond, ownerArg = owner.condition("", 2)
+		err = tx.QueryRowContext(ctx, `SELECT NOT EXISTS (SELECT 1 FROM cart WHERE store_id = $1 AND status = 'in_cart' AND `+ownerCond+`)`,
+			storeID, ownerArg).Scan(&created)
+		if err != nil {
+			return false, fmt.Errorf("failed to check cart: %w", err)
+		}
+
 		insertQuery := `
-            INSERT INTO cart (store_id, product_id, quantity, added_at, status)
-            VALUES ($1, $2, $3, $4, $5)
+            INSERT INTO cart (store_id, product_id, quantity, added_at, status, user_id, cart_token)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)
         `
-		_, err := pdb.db.ExecContext(ctx, insertQuery, storeID, productID, quantity, time.Now(), "in_cart")
+		_, err := tx.ExecContext(ctx, insertQuery, storeID, productID, quantity, time.Now(), "in_cart", userID, token)
 		if err != nil {
-			return fmt.Errorf("failed to add item to cart: %v", err)
+			return false, fmt.Errorf("failed to add item to cart: %w", err)
 		}
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
 }
 
-func (bs *BookStore) AddToCart(ctx context.Context, storeID, productID, quantity int) error {
-	return bs.db.AddToCart(ctx, storeID, productID, quantity)
+func (bs *BookStore) AddToCart(ctx context.Context, owner CartOwner, storeID, productID, quantity int) (bool, error) {
+	return bs.db.AddToCart(ctx, owner, storeID, productID, quantity)
 }
 
-func (pdb *PostgresDatabase) GetCartItemsByStore(ctx context.Context, storeID int) ([]Product, error) {
-	query := `SELECT p.id, p.product_name, p.price, c.quantity, p.created_at, p.updated_at, p.category, p.brand, p.model, p.store_id, p.is_recommended, p.image_path
+// GetCart ดึงสินค้าในตะกร้าของเจ้าของคนนี้ พร้อมราคาต่อหน่วย ยอดต่อบรรทัด และจำนวนคงเหลือในสต็อก
+func (pdb *PostgresDatabase) GetCart(ctx context.Context, owner CartOwner, storeID int) (_ Cart, err error) {
+	defer pdb.observe(ctx, "GetCart", ownerAttr(owner), slog.Int("store_id", storeID))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
+	ownerCond, ownerArg := owner.condition("c.", 2)
+	query := `SELECT c.id, p.id, p.store_id, p.product_name, p.category, p.brand, p.model, p.image_path, p.price, c.quantity, p.quantity, c.added_at
               FROM cart c
               JOIN product_info p ON c.product_id = p.id
-              WHERE p.store_id = $1 AND c.status = 'in_cart'`
+              WHERE c.store_id = $1 AND c.status = 'in_cart' AND ` + ownerCond + `
+              ORDER BY c.added_at, c.id`
 
-	rows, err := pdb.db.QueryContext(ctx, query, storeID)
+	rows, err := db.QueryContext(ctx, query, storeID, ownerArg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get cart items: %v", err)
+		return Cart{}, fmt.Errorf("failed to get cart items: %w", err)
 	}
 	defer rows.Close()
 
-	var products []Product
+	var items []CartItem
 	for rows.Next() {
-		var product Product
+		var item CartItem
 		if err := rows.Scan(
-			&product.ID,
-			&product.ProductName,
-			&product.Price,
-			&product.Quantity,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-			&product.Category,
-			&product.Brand,
-			&product.Model,
-			&product.StoreID,
-			&product.IsRecommended,
-			&product.ImagePath,
+			&item.ID,
+			&item.ProductID,
+			&item.StoreID,
+			&item.ProductName,
+			&item.Category,
+			&item.Brand,
+			&item.Model,
+			&item.ImagePath,
+			&item.UnitPrice,
+			&item.Quantity,
+			&item.AvailableStock,
+			&item.AddedAt,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan product data: %v", err)
+			return Cart{}, fmt.Errorf("failed to scan cart item: %w", err)
 		}
-		products = append(products, product)
+		items = append(items, item)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %v", err)
+		return Cart{}, fmt.Errorf("rows iteration error: %w", err)
 	}
 
-	return products, nil
+	return newCart(storeID, items), nil
+}
+
+func (bs *BookStore) GetCart(ctx context.Context, owner CartOwner, storeID int) (Cart, error) {
+	return bs.db.GetCart(ctx, owner, storeID)
+}
+
+// UpdateCartItemQuantity ตั้งจำนวนของรายการในตะกร้าตามรหัสบรรทัด ถ้าจำนวนเป็น 0 จะลบรายการนั้นออก
+func (pdb *PostgresDatabase) UpdateCartItemQuantity(ctx context.Context, owner CartOwner, storeID, itemID, quantity int) (err error) {
+	defer pdb.observe(ctx, "UpdateCartItemQuantity", ownerAttr(owner), slog.Int("store_id", storeID), slog.Int("item_id", itemID), slog.Int("quantity", quantity))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+	if quantity < 0 {
+		return ErrInvalidQuantity
+	}
+	if quantity > MaxCartItemQuantity {
+		return ErrQuantityTooLarge
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// หารายการในตะกร้าของเจ้าของคนนี้ก่อน
+	var productID int
+	ownerCond, ownerArg := owner.condition("", 3)
+	query := `SELECT product_id FROM cart WHERE id = $1 AND store_id = $2 AND status = 'in_cart' AND ` + ownerCond
+	err = tx.QueryRowContext(ctx, query, itemID, storeID, ownerArg).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return ErrCartItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get cart item: %w", err)
+	}
+
+	if quantity == 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM cart WHERE id = $1`, itemID); err != nil {
+			return fmt.Errorf("failed to delete cart item: %w", err)
+		}
+	} else {
+		stock, err := lockProductForCart(ctx, tx, storeID, productID)
+		if err != nil {
+			return err
+		}
+		if quantity > stock {
+			return ErrOutOfStock
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE cart SET quantity = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, quantity, itemID); err != nil {
+			return fmt.Errorf("failed to update cart item: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
 
-func (bs *BookStore) GetCartItemsByStore(ctx context.Context, storeID int) ([]Product, error) {
-	return bs.db.GetCartItemsByStore(ctx, storeID)
+func (bs *BookStore) UpdateCartItemQuantity(ctx context.Context, owner CartOwner, storeID, itemID, quantity int) error {
+	return bs.db.UpdateCartItemQuantity(ctx, owner, storeID, itemID, quantity)
 }
 
 // DeleteProductFromCart ลบสินค้าจากตะกร้าสินค้าตาม productID
-func (pdb *PostgresDatabase) DeleteProductFromCart(ctx context.Context, storeID, productID int) error {
-	// Query สำหรับลบสินค้าจากตะกร้า
-	query := `DELETE FROM cart WHERE store_id = $1 AND product_id = $2 AND status = 'in_cart'`
+func (pdb *PostgresDatabase) DeleteProductFromCart(ctx context.Context, owner CartOwner, storeID, productID int) (err error) {
+	defer pdb.observe(ctx, "DeleteProductFromCart", ownerAttr(owner), slog.Int("store_id", storeID), slog.Int("product_id", productID))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+	// Query สำหรับลบสินค้าจากตะกร้าของเจ้าของคนนี้
+	ownerCond, ownerArg := owner.condition("", 3)
+	query := `DELETE FROM cart WHERE store_id = $1 AND product_id = $2 AND status = 'in_cart' AND ` + ownerCond
 	// เรียกใช้คำสั่งลบจากฐานข้อมูล
-	result, err := pdb.db.ExecContext(ctx, query, storeID, productID)
+	result, err := db.ExecContext(ctx, query, storeID, productID, ownerArg)
 	if err != nil {
-		return fmt.Errorf("failed to delete product from cart: %v", err)
+		return fmt.Errorf("failed to delete product from cart: %w", err)
 	}
 
 	// ตรวจสอบว่ามีการลบข้อมูลหรือไม่
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get affected rows: %v", err)
+		return fmt.Errorf("failed to get affected rows: %w", err)
 	}
 
 	// ถ้าไม่มีแถวที่ถูกลบแสดงว่าไม่พบสินค้าที่จะลบ
 	if rowsAffected == 0 {
-		return fmt.Errorf("product not found in cart")
+		return ErrCartItemNotFound
 	}
 
 	return nil
 }
 
-func (bs *BookStore) DeleteProductFromCart(ctx context.Context, storeID, productID int) error {
-	return bs.db.DeleteProductFromCart(ctx, storeID, productID)
+func (bs *BookStore) DeleteProductFromCart(ctx context.Context, owner CartOwner, storeID, productID int) error {
+	return bs.db.DeleteProductFromCart(ctx, owner, storeID, productID)
+}
+
+// MergeGuestCart ย้ายสินค้าในตะกร้าของแขก (token) ไปรวมกับตะกร้าของผู้ใช้ที่เพิ่งล็อกอิน
+// ถ้าสินค้าซ้ำกันจะรวมจำนวนเข้าด้วยกัน แต่ไม่เกินขีดจำกัดต่อรายการและสต็อกที่เหลือ
+// ส่วนที่เกินจะถูกตัดทิ้งแทนที่จะทำให้การล็อกอินล้มเหลว
+func (pdb *PostgresDatabase) MergeGuestCart(ctx context.Context, token string, userID int) (err error) {
+	defer pdb.observe(ctx, "MergeGuestCart", slog.Int("user_id", userID))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// ล็อกสินค้าในตะกร้าแขกแบบเดียวกับ lockProductForCart เพื่อไม่ให้สต็อกเปลี่ยนระหว่างรวม
+	lockQuery := `
+        SELECT id FROM product_info
+        WHERE id IN (SELECT product_id FROM cart WHERE cart_token = $1 AND status = 'in_cart')
+        ORDER BY id
+        FOR UPDATE
+    `
+	if _, err := tx.ExecContext(ctx, lockQuery, token); err != nil {
+		return fmt.Errorf("failed to lock guest cart products: %w", err)
+	}
+
+	// รวมจำนวนของสินค้าที่มีอยู่แล้วในตะกร้าของผู้ใช้ ไม่เกิน MaxCartItemQuantity และสต็อก
+	// แต่ไม่ลดจำนวนที่ผู้ใช้มีอยู่เดิม
+	mergeQuery := `
+        UPDATE cart u SET quantity = GREATEST(u.quantity, LEAST(u.quantity + g.quantity, $3, p.quantity)),
+            updated_at = CURRENT_TIMESTAMP
+        FROM cart g, product_info p
+        WHERE g.cart_token = $1 AND g.status = 'in_cart'
+          AND u.user_id = $2 AND u.status = 'in_cart'
+          AND u.store_id = g.store_id AND u.product_id = g.product_id
+          AND p.id = u.product_id
+    `
+	if _, err := tx.ExecContext(ctx, mergeQuery, token, userID, MaxCartItemQuantity); err != nil {
+		return fmt.Errorf("failed to merge cart quantities: %w", err)
+	}
+
+	// ลบรายการของแขกที่ถูกรวมไปแล้ว
+	deleteQuery := `
+        DELETE FROM cart g
+        USING cart u
+        WHERE g.cart_token = $1 AND g.status = 'in_cart'
+          AND u.user_id = $2 AND u.status = 'in_cart'
+          AND u.store_id = g.store_id AND u.product_id = g.product_id
+    `
+	if _, err := tx.ExecContext(ctx, deleteQuery, token, userID); err != nil {
+		return fmt.Errorf("failed to remove merged guest items: %w", err)
+	}
+
+	// รายการที่เหลือย้ายไปเป็นของผู้ใช้ได้เลย
+	moveQuery := `UPDATE cart SET user_id = $2, cart_token = NULL, updated_at = CURRENT_TIMESTAMP WHERE cart_token = $1 AND status = 'in_cart'`
+	if _, err := tx.ExecContext(ctx, moveQuery, token, userID); err != nil {
+		return fmt.Errorf("failed to move guest items to user cart: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (bs *BookStore) MergeGuestCart(ctx context.Context, token string, userID int) error {
+	return bs.db.MergeGuestCart(ctx, token, userID)
 }
 
 // ฟังก์ชันการชำระเงินและย้ายข้อมูลจากตะกร้าไปยัง order_history
-func (pdb *PostgresDatabase) Checkout(ctx context.Context, storeID int) error {
+func (pdb *PostgresDatabase) Checkout(ctx context.Context, storeID int) (err error) {
+	defer pdb.observe(ctx, "Checkout", slog.Int("store_id", storeID))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
 	// เริ่มต้นการทำ transaction
-	tx, err := pdb.db.BeginTx(ctx, nil)
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
 	// ขอโต๊ะข้อมูลจากตะกร้าของ store ที่ระบุ
@@ -694,7 +1034,7 @@ func (pdb *PostgresDatabase) Checkout(ctx context.Context, storeID int) error {
 	rows, err := tx.QueryContext(ctx, query, storeID)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to fetch cart items: %v", err)
+		return fmt.Errorf("failed to fetch cart items: %w", err)
 	}
 	defer rows.Close()
 
@@ -703,7 +1043,7 @@ func (pdb *PostgresDatabase) Checkout(ctx context.Context, storeID int) error {
 		var cartID, productID, quantity int
 		if err := rows.Scan(&cartID, &productID, &quantity); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to scan cart item: %v", err)
+			return fmt.Errorf("failed to scan cart item: %w", err)
 		}
 
 		// Insert ข้อมูลลงใน order_history
@@ -714,7 +1054,7 @@ func (pdb *PostgresDatabase) Checkout(ctx context.Context, storeID int) error {
 		_, err := tx.ExecContext(ctx, insertOrderQuery, storeID, productID, quantity, "ordered")
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to insert order into order_history: %v", err)
+			return fmt.Errorf("failed to insert order into order_history: %w", err)
 		}
 
 		// ลบสินค้านั้นออกจากตะกร้า
@@ -722,19 +1062,19 @@ func (pdb *PostgresDatabase) Checkout(ctx context.Context, storeID int) error {
 		_, err = tx.ExecContext(ctx, deleteQuery, cartID)
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to delete cart item: %v", err)
+			return fmt.Errorf("failed to delete cart item: %w", err)
 		}
 	}
 
 	// เช็คว่าไม่มีข้อผิดพลาดใด ๆ ก่อนที่จะทำการ commit
 	if err := rows.Err(); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error occurred while iterating over cart rows: %v", err)
+		return fmt.Errorf("error occurred while iterating over cart rows: %w", err)
 	}
 
 	// commit transaction
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
@@ -744,19 +1084,171 @@ func (bs *BookStore) Checkout(ctx context.Context, storeID int) error {
 	return bs.db.Checkout(ctx, storeID)
 }
 
-func (pdb *PostgresDatabase) CheckoutCart(ctx context.Context, storeID int) error {
+// CheckoutCart สร้างคำสั่งซื้อจากสินค้าในตะกร้าของเจ้าของคนนี้ แล้วเปลี่ยนสถานะสินค้าเป็น 'checked_out'
+func (pdb *PostgresDatabase) CheckoutCart(ctx context.Context, owner CartOwner, storeID int, contact ContactInfo) (_ Order, err error) {
+	defer pdb.observe(ctx, "CheckoutCart", ownerAttr(owner), slog.Int("store_id", storeID))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+	order := Order{StoreID: storeID, Contact: contact, Status: OrderPlaced}
+	if owner.UserID != 0 {
+		order.UserID = &owner.UserID
+	} else {
+		order.CartToken = &owner.Token
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return order, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// คำนวณยอดรวมจากราคาปัจจุบันของสินค้า
+	ownerCond, ownerArg := owner.condition("c.", 2)
+	totalQuery := `
+        SELECT COALESCE(SUM(p.price * c.quantity), 0)
+        FROM cart c
+        JOIN product_info p ON c.product_id = p.id
+        WHERE c.store_id = $1 AND c.status = 'in_cart' AND ` + ownerCond
+	if err := tx.QueryRowContext(ctx, totalQuery, storeID, ownerArg).Scan(&order.TotalAmount); err != nil {
+		return order, fmt.Errorf("failed to calculate cart total: %w", err)
+	}
+
+	insertOrderQuery := `
+        INSERT INTO orders (store_id, user_id, cart_token, contact_name, contact_email, contact_phone, shipping_address, total_amount, status)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at
+    `
+	err = tx.QueryRowContext(ctx, insertOrderQuery,
+		storeID, order.UserID, order.CartToken,
+		contact.Name, contact.Email, contact.Phone, contact.Address,
+		order.TotalAmount, order.Status,
+	).Scan(&order.ID, &order.CreatedAt)
+	if err != nil {
+		return order, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	ownerCond, ownerArg = owner.condition("", 4)
 	query := `
         UPDATE cart
-        SET status = 'checked_out', checked_out_at = $1
-        WHERE store_id = $2 AND status = 'in_cart'
+        SET status = 'checked_out', checked_out_at = $1, order_id = $2
+        WHERE store_id = $3 AND status = 'in_cart' AND ` + ownerCond
+	if _, err := tx.ExecContext(ctx, query, time.Now(), order.ID, storeID, ownerArg); err != nil {
+		return order, fmt.Errorf("failed to checkout cart: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return order, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return order, nil
+}
+
+func (bs *BookStore) CheckoutCart(ctx context.Context, owner CartOwner, storeID int, contact ContactInfo) (Order, error) {
+	return bs.db.CheckoutCart(ctx, owner, storeID, contact)
+}
+
+// ExpireIdleCarts เปลี่ยนสถานะตะกร้าที่ไม่มีความเคลื่อนไหวตั้งแต่ idleSince เป็น 'expired'
+// และบันทึกทุกรายการที่หมดอายุลง abandoned_cart_events ใน statement เดียว
+// สินค้าในตะกร้าที่หมดอายุจะไม่ถูกนับว่าอยู่ในตะกร้าอีก คืนค่าจำนวนรายการที่หมดอายุ
+func (pdb *PostgresDatabase) ExpireIdleCarts(ctx context.Context, idleSince time.Time) (_ int, err error) {
+	defer pdb.observe(ctx, "ExpireIdleCarts", slog.Time("idle_since", idleSince))(&err)
+	db, release := pdb.acquire()
+	defer release()
+	// ตะกร้า 1 ใบคือรายการของเจ้าของเดียวกันในร้านเดียวกัน ใช้เวลาแก้ไขล่าสุดของทั้งตะกร้าตัดสิน
+	query := `
+        WITH idle AS (
+            SELECT store_id, user_id, cart_token
+            FROM cart
+            WHERE status = 'in_cart'
+            GROUP BY store_id, user_id, cart_token
+            HAVING MAX(updated_at) < $1
+        ), expired AS (
+            UPDATE cart c SET status = 'expired'
+            FROM idle i
+            WHERE c.status = 'in_cart'
+              AND c.store_id = i.store_id
+              AND c.user_id IS NOT DISTINCT FROM i.user_id
+              AND c.cart_token IS NOT DISTINCT FROM i.cart_token
+            RETURNING c.id, c.store_id, c.user_id, c.cart_token, c.product_id, c.quantity, c.updated_at
+        )
+        INSERT INTO abandoned_cart_events (cart_item_id, store_id, user_id, cart_token, product_id, quantity, unit_price, last_activity_at)
+        SELECT e.id, e.store_id, e.user_id, e.cart_token, e.product_id, e.quantity, p.price, e.updated_at
+        FROM expired e
+        JOIN product_info p ON p.id = e.product_id
     `
-	_, err := pdb.db.ExecContext(ctx, query, time.Now(), storeID)
+	result, err := db.ExecContext(ctx, query, idleSince)
 	if err != nil {
-		return fmt.Errorf("failed to checkout cart: %v", err)
+		return 0, fmt.Errorf("failed to expire idle carts: %w", err)
 	}
-	return nil
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return int(rowsAffected), nil
+}
+
+func (bs *BookStore) ExpireIdleCarts(ctx context.Context, idleSince time.Time) (int, error) {
+	return bs.db.ExpireIdleCarts(ctx, idleSince)
+}
+
+// GetAbandonedCartEvents ดึงรายการตะกร้าที่ถูกทิ้งของร้าน ตั้งแต่เวลา since เป็นต้นไป
+func (pdb *PostgresDatabase) GetAbandonedCartEvents(ctx context.Context, storeID int, since time.Time) (_ []AbandonedCartEvent, err error) {
+	defer pdb.observe(ctx, "GetAbandonedCartEvents", slog.Int("store_id", storeID), slog.Time("since", since))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
+	query := `
+        SELECT id, cart_item_id, store_id, user_id, cart_token, product_id, quantity, unit_price, last_activity_at, abandoned_at
+        FROM abandoned_cart_events
+        WHERE store_id = $1 AND abandoned_at >= $2
+        ORDER BY abandoned_at DESC, id DESC
+    `
+	rows, err := db.QueryContext(ctx, query, storeID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get abandoned cart events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []AbandonedCartEvent{}
+	for rows.Next() {
+		var (
+			event AbandonedCartEvent
+			token sql.NullString
+		)
+		if err := rows.Scan(
+			&event.ID,
+			&event.CartItemID,
+			&event.StoreID,
+			&event.UserID,
+			&token,
+			&event.ProductID,
+			&event.Quantity,
+			&event.UnitPrice,
+			&event.LastActivityAt,
+			&event.AbandonedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan abandoned cart event: %w", err)
+		}
+		if token.Valid {
+			guestID := hashCartToken(token.String)
+			event.GuestID = &guestID
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return events, nil
+}
+
+// hashCartToken แปลง token ตะกร้าแขกเป็นค่าที่ย้อนกลับเป็น token ไม่ได้ สำหรับส่งให้ระบบภายนอก
+func hashCartToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
 }
 
-func (bs *BookStore) CheckoutCart(ctx context.Context, storeID int) error {
-	return bs.db.CheckoutCart(ctx, storeID)
+func (bs *BookStore) GetAbandonedCartEvents(ctx context.Context, storeID int, since time.Time) ([]AbandonedCartEvent, error) {
+	return bs.db.GetAbandonedCartEvents(ctx, storeID, since)
 }
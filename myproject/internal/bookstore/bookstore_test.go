@@ -0,0 +1,22 @@
+// bookstore_test.go
+package bookstore
+
+import "testing"
+
+func TestContainsPattern(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"guitar", "%guitar%"},
+		{"", "%%"},
+		{"100%", `%100\%%`},
+		{"a_b", `%a\_b%`},
+		{`C:\path`, `%C:\\path%`},
+		{`\%_`, `%\\\%\_%`},
+	}
+	for _, tt := range tests {
+		if got := containsPattern(tt.in); got != tt.want {
+			t.Errorf("containsPattern(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
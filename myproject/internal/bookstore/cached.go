@@ -0,0 +1,233 @@
+// cached.go
+package bookstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"myproject/internal/cache"
+	"time"
+)
+
+// namespace ของ cache แต่ละกลุ่ม การเขียนข้อมูลจะล้างทั้งกลุ่มที่เกี่ยวข้อง
+const (
+	cacheStores   = "stores"
+	cacheProducts = "products"
+)
+
+// DefaultCacheTTLs อายุของ cache แต่ละ method ข้อมูลร้านแทบไม่เปลี่ยนจึงเก็บได้นาน
+// ส่วนรายการสินค้ามีจำนวนคงเหลือที่เปลี่ยนบ่อยกว่า method ที่ไม่อยู่ในนี้หรือมีค่า 0 จะไม่ถูกเก็บ
+var DefaultCacheTTLs = map[string]time.Duration{
+	"GetAllStoreInfo":               10 * time.Minute,
+	"GetStoreInfoByID":              10 * time.Minute,
+	"GetProduct":                    time.Minute,
+	"GetProductsByStore":            30 * time.Second,
+	"GetNewProductsByStore":         30 * time.Second,
+	"GetAllProductsByStore":         30 * time.Second,
+	"GetProductsByCategoryAndStore": 30 * time.Second,
+	"GetALLProductsByCategory":      30 * time.Second,
+	"SearchProducts":                30 * time.Second,
+	"SearchProductsByStore":         30 * time.Second,
+}
+
+// CacheOptions การตั้งค่าของ NewCachedDatabase
+type CacheOptions struct {
+	// TTLs อายุของ cache ตาม method ถ้าเป็น nil ใช้ DefaultCacheTTLs
+	TTLs map[string]time.Duration
+	// ReplicaLag ถ้าอ่านจาก read replica ให้กำหนดเป็นเวลาที่ replica อาจตามไม่ทัน
+	// cache จะถูกล้างซ้ำอีกครั้งหลังการเขียนเมื่อครบเวลานี้ เพื่อทิ้งข้อมูลเก่าที่อาจถูกอ่านจาก replica ระหว่างนั้น
+	ReplicaLag time.Duration
+	// OnResult ถูกเรียกทุกครั้งที่อ่าน cache พร้อมผลว่าพบหรือไม่ (ไม่บังคับ) ใช้เก็บค่าวัด
+	OnResult func(method string, hit bool)
+}
+
+// CachedDatabase ห่อ BookDatabase ด้วย cache สำหรับข้อมูลร้านและสินค้า
+// method ที่ไม่ได้ห่อไว้ (ตะกร้า คำสั่งซื้อ) ส่งต่อไปยังฐานข้อมูลตรงๆ
+// ถ้า cache ใช้งานไม่ได้จะอ่านจากฐานข้อมูลแทนโดยไม่ทำให้ request ล้มเหลว
+type CachedDatabase struct {
+	BookDatabase
+	store cache.Store
+	opts  CacheOptions
+}
+
+// NewCachedDatabase สร้าง CachedDatabase ที่เก็บผลลัพธ์ไว้ใน store
+func NewCachedDatabase(db BookDatabase, store cache.Store, opts CacheOptions) *CachedDatabase {
+	if opts.TTLs == nil {
+		opts.TTLs = DefaultCacheTTLs
+	}
+	return &CachedDatabase{BookDatabase: db, store: store, opts: opts}
+}
+
+// Close ปิดฐานข้อมูลและที่เก็บ cache
+func (cdb *CachedDatabase) Close() error {
+	err := cdb.BookDatabase.Close()
+	if cerr := cdb.store.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// cached อ่านผลลัพธ์ของ method จาก cache ถ้าไม่พบจะเรียก load แล้วเก็บผลไว้ error ไม่ถูกเก็บ
+func cached[T any](ctx context.Context, cdb *CachedDatabase, namespace, method string, load func() (T, error), args ...interface{}) (T, error) {
+	ttl := cdb.opts.TTLs[method]
+	if ttl <= 0 {
+		return load()
+	}
+
+	gen, err := cdb.store.Generation(ctx, namespace)
+	if err != nil {
+		slog.WarnContext(ctx, "cache unavailable", slog.String("op", method), slog.Any("error", err))
+		return load()
+	}
+	argsJSON, _ := json.Marshal(args)
+	key := fmt.Sprintf("%s:%d:%s:%s", namespace, gen, method, argsJSON)
+
+	var result T
+	data, ok, err := cdb.store.Get(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "cache unavailable", slog.String("op", method), slog.Any("error", err))
+	}
+	if ok && json.Unmarshal(data, &result) == nil {
+		cdb.report(method, true)
+		return result, nil
+	}
+	cdb.report(method, false)
+
+	result, err = load()
+	if err != nil {
+		return result, err
+	}
+	if data, err := json.Marshal(result); err == nil {
+		if err := cdb.store.Set(ctx, key, data, ttl); err != nil {
+			slog.WarnContext(ctx, "failed to store cache entry", slog.String("op", method), slog.Any("error", err))
+		}
+	}
+	return result, nil
+}
+
+func (cdb *CachedDatabase) report(method string, hit bool) {
+	if cdb.opts.OnResult != nil {
+		cdb.opts.OnResult(method, hit)
+	}
+}
+
+// invalidate ล้าง cache ของ namespace หลังการเขียนที่สำเร็จ ถ้าล้างไม่ได้ข้อมูลเก่าจะอยู่จนหมดอายุตาม TTL
+func (cdb *CachedDatabase) invalidate(ctx context.Context, namespace string) {
+	ctx = context.WithoutCancel(ctx)
+	if err := cdb.store.Invalidate(ctx, namespace); err != nil {
+		slog.ErrorContext(ctx, "failed to invalidate cache", slog.String("namespace", namespace), slog.Any("error", err))
+	}
+	if cdb.opts.ReplicaLag > 0 {
+		time.AfterFunc(cdb.opts.ReplicaLag, func() {
+			if err := cdb.store.Invalidate(ctx, namespace); err != nil {
+				slog.ErrorContext(ctx, "failed to invalidate cache", slog.String("namespace", namespace), slog.Any("error", err))
+			}
+		})
+	}
+}
+
+func (cdb *CachedDatabase) GetAllStoreInfo(ctx context.Context) ([]StoreInfo, error) {
+	return cached(ctx, cdb, cacheStores, "GetAllStoreInfo", func() ([]StoreInfo, error) {
+		return cdb.BookDatabase.GetAllStoreInfo(ctx)
+	})
+}
+
+func (cdb *CachedDatabase) GetStoreInfoByID(ctx context.Context, id int) (StoreInfo, error) {
+	return cached(ctx, cdb, cacheStores, "GetStoreInfoByID", func() (StoreInfo, error) {
+		return cdb.BookDatabase.GetStoreInfoByID(ctx, id)
+	}, id)
+}
+
+func (cdb *CachedDatabase) GetProductsByStore(ctx context.Context, storeID int) ([]Product, error) {
+	return cached(ctx, cdb, cacheProducts, "GetProductsByStore", func() ([]Product, error) {
+		return cdb.BookDatabase.GetProductsByStore(ctx, storeID)
+	}, storeID)
+}
+
+func (cdb *CachedDatabase) GetNewProductsByStore(ctx context.Context, storeID int) ([]Product, error) {
+	return cached(ctx, cdb, cacheProducts, "GetNewProductsByStore", func() ([]Product, error) {
+		return cdb.BookDatabase.GetNewProductsByStore(ctx, storeID)
+	}, storeID)
+}
+
+func (cdb *CachedDatabase) SearchProducts(ctx context.Context, searchQuery string) ([]Product, error) {
+	return cached(ctx, cdb, cacheProducts, "SearchProducts", func() ([]Product, error) {
+		return cdb.BookDatabase.SearchProducts(ctx, searchQuery)
+	}, searchQuery)
+}
+
+func (cdb *CachedDatabase) GetProduct(ctx context.Context, id int) (Product, error) {
+	return cached(ctx, cdb, cacheProducts, "GetProduct", func() (Product, error) {
+		return cdb.BookDatabase.GetProduct(ctx, id)
+	}, id)
+}
+
+func (cdb *CachedDatabase) SearchProductsByStore(ctx context.Context, searchQuery string, storeID int) ([]Product, error) {
+	return cached(ctx, cdb, cacheProducts, "SearchProductsByStore", func() ([]Product, error) {
+		return cdb.BookDatabase.SearchProductsByStore(ctx, searchQuery, storeID)
+	}, searchQuery, storeID)
+}
+
+func (cdb *CachedDatabase) GetAllProductsByStore(ctx context.Context, storeID int, sortOrder string) ([]Product, error) {
+	return cached(ctx, cdb, cacheProducts, "GetAllProductsByStore", func() ([]Product, error) {
+		return cdb.BookDatabase.GetAllProductsByStore(ctx, storeID, sortOrder)
+	}, storeID, sortOrder)
+}
+
+func (cdb *CachedDatabase) GetProductsByCategoryAndStore(ctx context.Context, storeID int, category string) ([]Product, error) {
+	return cached(ctx, cdb, cacheProducts, "GetProductsByCategoryAndStore", func() ([]Product, error) {
+		return cdb.BookDatabase.GetProductsByCategoryAndStore(ctx, storeID, category)
+	}, storeID, category)
+}
+
+func (cdb *CachedDatabase) GetALLProductsByCategory(ctx context.Context, category string) ([]Product, error) {
+	return cached(ctx, cdb, cacheProducts, "GetALLProductsByCategory", func() ([]Product, error) {
+		return cdb.BookDatabase.GetALLProductsByCategory(ctx, category)
+	}, category)
+}
+
+// UpsertStore ล้าง cache ของข้อมูลร้านหลังบันทึกสำเร็จ
+func (cdb *CachedDatabase) UpsertStore(ctx context.Context, store StoreInfo) (StoreInfo, error) {
+	saved, err := cdb.BookDatabase.UpsertStore(ctx, store)
+	if err == nil {
+		cdb.invalidate(ctx, cacheStores)
+	}
+	return saved, err
+}
+
+// UpsertProduct ล้าง cache ของสินค้าหลังบันทึกสำเร็จ
+func (cdb *CachedDatabase) UpsertProduct(ctx context.Context, product Product) (Product, error) {
+	saved, err := cdb.BookDatabase.UpsertProduct(ctx, product)
+	if err == nil {
+		cdb.invalidate(ctx, cacheProducts)
+	}
+	return saved, err
+}
+
+// ImportProducts ล้าง cache ของสินค้าหลังนำเข้าสำเร็จ (dry run ไม่ได้เปลี่ยนข้อมูล)
+func (cdb *CachedDatabase) ImportProducts(ctx context.Context, products []Product, dryRun bool) ([]ProductUpsert, error) {
+	results, err := cdb.BookDatabase.ImportProducts(ctx, products, dryRun)
+	if err == nil && !dryRun {
+		cdb.invalidate(ctx, cacheProducts)
+	}
+	return results, err
+}
+
+// CreateStore ล้าง cache ของข้อมูลร้านหลังสร้างร้านสำเร็จ
+func (cdb *CachedDatabase) CreateStore(ctx context.Context, store StoreInfo) (StoreInfo, error) {
+	created, err := cdb.BookDatabase.CreateStore(ctx, store)
+	if err == nil {
+		cdb.invalidate(ctx, cacheStores)
+	}
+	return created, err
+}
+
+// AdjustProduct ล้าง cache ของสินค้าหลังแก้สต็อกหรือราคาสำเร็จ
+func (cdb *CachedDatabase) AdjustProduct(ctx context.Context, productID int, adj ProductAdjustment) (Product, error) {
+	product, err := cdb.BookDatabase.AdjustProduct(ctx, productID, adj)
+	if err == nil {
+		cdb.invalidate(ctx, cacheProducts)
+	}
+	return product, err
+}
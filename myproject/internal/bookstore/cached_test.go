@@ -0,0 +1,197 @@
+// cached_test.go
+package bookstore
+
+import (
+	"context"
+	"errors"
+	"myproject/internal/cache"
+	"testing"
+	"time"
+)
+
+// countingDB BookDatabase ปลอมที่นับจำนวนครั้งที่ถูกอ่านแต่ละ method
+// method ที่ไม่ได้ override จะ panic เพราะ BookDatabase ที่ฝังไว้เป็น nil
+type countingDB struct {
+	BookDatabase
+	reads    map[string]int
+	readErr  error
+	writeErr error
+}
+
+func (db *countingDB) read(method string) error {
+	db.reads[method]++
+	return db.readErr
+}
+
+func (db *countingDB) GetAllStoreInfo(context.Context) ([]StoreInfo, error) {
+	return []StoreInfo{{ID: 1}}, db.read("GetAllStoreInfo")
+}
+
+func (db *countingDB) GetProduct(_ context.Context, id int) (Product, error) {
+	return Product{ID: id}, db.read("GetProduct")
+}
+
+func (db *countingDB) GetProductsByStore(_ context.Context, storeID int) ([]Product, error) {
+	return []Product{{StoreID: storeID}}, db.read("GetProductsByStore")
+}
+
+func (db *countingDB) UpsertStore(_ context.Context, store StoreInfo) (StoreInfo, error) {
+	return store, db.writeErr
+}
+
+func (db *countingDB) UpsertProduct(_ context.Context, product Product) (Product, error) {
+	return product, db.writeErr
+}
+
+func (db *countingDB) ImportProducts(context.Context, []Product, bool) ([]ProductUpsert, error) {
+	return nil, db.writeErr
+}
+
+func (db *countingDB) AdjustProduct(_ context.Context, productID int, _ ProductAdjustment) (Product, error) {
+	return Product{ID: productID}, db.writeErr
+}
+
+// readAll อ่านทุก method ที่ cache ไว้หนึ่งรอบ
+func readAll(t *testing.T, cdb *CachedDatabase) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := cdb.GetAllStoreInfo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cdb.GetProduct(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cdb.GetProductsByStore(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCachedDatabaseInvalidation(t *testing.T) {
+	errWrite := errors.New("write failed")
+
+	tests := []struct {
+		name     string
+		writeErr error
+		write    func(ctx context.Context, cdb *CachedDatabase) error
+		// wantReloaded method ที่ต้องอ่านจากฐานข้อมูลอีกครั้งหลังการเขียน
+		wantReloaded []string
+	}{
+		{
+			name: "store upsert clears stores",
+			write: func(ctx context.Context, cdb *CachedDatabase) error {
+				_, err := cdb.UpsertStore(ctx, StoreInfo{ID: 1})
+				return err
+			},
+			wantReloaded: []string{"GetAllStoreInfo"},
+		},
+		{
+			name: "product upsert clears products",
+			write: func(ctx context.Context, cdb *CachedDatabase) error {
+				_, err := cdb.UpsertProduct(ctx, Product{ID: 1})
+				return err
+			},
+			wantReloaded: []string{"GetProduct", "GetProductsByStore"},
+		},
+		{
+			name: "stock adjustment clears products",
+			write: func(ctx context.Context, cdb *CachedDatabase) error {
+				_, err := cdb.AdjustProduct(ctx, 1, ProductAdjustment{})
+				return err
+			},
+			wantReloaded: []string{"GetProduct", "GetProductsByStore"},
+		},
+		{
+			name: "import clears products",
+			write: func(ctx context.Context, cdb *CachedDatabase) error {
+				_, err := cdb.ImportProducts(ctx, nil, false)
+				return err
+			},
+			wantReloaded: []string{"GetProduct", "GetProductsByStore"},
+		},
+		{
+			name: "dry run import keeps the cache",
+			write: func(ctx context.Context, cdb *CachedDatabase) error {
+				_, err := cdb.ImportProducts(ctx, nil, true)
+				return err
+			},
+		},
+		{
+			name:     "failed write keeps the cache",
+			writeErr: errWrite,
+			write: func(ctx context.Context, cdb *CachedDatabase) error {
+				if _, err := cdb.UpsertProduct(ctx, Product{ID: 1}); !errors.Is(err, errWrite) {
+					return errors.New("write error was not returned")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &countingDB{reads: map[string]int{}, writeErr: tt.writeErr}
+			cdb := NewCachedDatabase(db, cache.NewLRU(0), CacheOptions{})
+
+			readAll(t, cdb)
+			readAll(t, cdb)
+			for method, n := range db.reads {
+				if n != 1 {
+					t.Fatalf("%s read %d times before the write, want 1 (cached)", method, n)
+				}
+			}
+
+			if err := tt.write(context.Background(), cdb); err != nil {
+				t.Fatal(err)
+			}
+			readAll(t, cdb)
+
+			reloaded := map[string]bool{}
+			for _, method := range tt.wantReloaded {
+				reloaded[method] = true
+			}
+			for method, n := range db.reads {
+				want := 1
+				if reloaded[method] {
+					want = 2
+				}
+				if n != want {
+					t.Errorf("%s read %d times, want %d", method, n, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedDatabaseDoesNotCacheErrors(t *testing.T) {
+	db := &countingDB{reads: map[string]int{}, readErr: ErrProductNotFound}
+	var hits, misses int
+	cdb := NewCachedDatabase(db, cache.NewLRU(0), CacheOptions{OnResult: func(_ string, hit bool) {
+		if hit {
+			hits++
+		} else {
+			misses++
+		}
+	}})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cdb.GetProduct(context.Background(), 1); !errors.Is(err, ErrProductNotFound) {
+			t.Fatalf("GetProduct() error = %v, want ErrProductNotFound", err)
+		}
+	}
+	if db.reads["GetProduct"] != 2 || hits != 0 || misses != 2 {
+		t.Errorf("reads, hits, misses = %d, %d, %d, want 2, 0, 2", db.reads["GetProduct"], hits, misses)
+	}
+}
+
+func TestCachedDatabaseSkipsMethodsWithoutTTL(t *testing.T) {
+	db := &countingDB{reads: map[string]int{}}
+	cdb := NewCachedDatabase(db, cache.NewLRU(0), CacheOptions{TTLs: map[string]time.Duration{"GetProduct": 0}})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cdb.GetProduct(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if db.reads["GetProduct"] != 2 {
+		t.Errorf("GetProduct read %d times, want 2 (not cached)", db.reads["GetProduct"])
+	}
+}
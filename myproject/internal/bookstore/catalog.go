@@ -0,0 +1,504 @@
+// catalog.go
+package bookstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// Category หมวดหมู่สินค้า สินค้าอ้างอิงหมวดหมู่ด้วยชื่อ (product_info.category)
+type Category struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ImagePath   string `json:"image_path"`
+}
+
+// UpsertStore เพิ่มร้านใหม่ หรืออัปเดตร้านที่มีชื่อเดียวกันอยู่แล้ว คืนร้านพร้อม ID
+func (pdb *PostgresDatabase) UpsertStore(ctx context.Context, store StoreInfo) (_ StoreInfo, err error) {
+	defer pdb.observe(ctx, "UpsertStore", slog.String("store_name", store.StoreName))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+	// อัปเดตเฉพาะเมื่อข้อมูลเปลี่ยน ถ้าไม่เปลี่ยนจะไม่มีแถวคืนมา ต้องอ่าน ID เอง
+	query := `
+        INSERT INTO store_info (logo_path, store_name, description, address, phone_number, email)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (store_name) DO UPDATE SET
+            logo_path = EXCLUDED.logo_path,
+            description = EXCLUDED.description,
+            address = EXCLUDED.address,
+            phone_number = EXCLUDED.phone_number,
+            email = EXCLUDED.email
+        WHERE (store_info.logo_path, store_info.description, store_info.address, store_info.phone_number, store_info.email)
+            IS DISTINCT FROM (EXCLUDED.logo_path, EXCLUDED.description, EXCLUDED.address, EXCLUDED.phone_number, EXCLUDED.email)
+        RETURNING id
+    `
+	err = db.QueryRowContext(ctx, query,
+		store.LogoPath, store.StoreName, store.Description, store.Address, store.PhoneNumber, store.Email,
+	).Scan(&store.ID)
+	if err == sql.ErrNoRows {
+		err = db.QueryRowContext(ctx, `SELECT id FROM store_info WHERE store_name = $1`, store.StoreName).Scan(&store.ID)
+	}
+	if err != nil {
+		return store, fmt.Errorf("failed to upsert store: %w", err)
+	}
+	return store, nil
+}
+
+// ผลของการ upsert สินค้า 1 รายการ
+const (
+	UpsertCreated   = "created"
+	UpsertUpdated   = "updated"
+	UpsertUnchanged = "unchanged"
+)
+
+// rowQuerier ส่วนที่ *sql.DB และ *sql.Tx ใช้ร่วมกัน เพื่อให้ upsert ทำได้ทั้งในและนอก transaction
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// upsertProduct เพิ่มหรืออัปเดตสินค้าตาม store_id, brand และ model แล้วบอกว่าเป็นการสร้าง อัปเดต หรือไม่เปลี่ยน
+func upsertProduct(ctx context.Context, q rowQuerier, product Product) (Product, string, error) {
+	// อัปเดตเฉพาะเมื่อข้อมูลเปลี่ยน ถ้าไม่เปลี่ยนจะไม่มีแถวคืนมา ต้องอ่าน ID เอง
+	// xmax = 0 แปลว่าแถวนี้เพิ่งถูก INSERT
+	query := `
+        INSERT INTO product_info (product_name, price, quantity, category, brand, model, store_id, is_recommended, image_path)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        ON CONFLICT (store_id, brand, model) DO UPDATE SET
+            product_name = EXCLUDED.product_name,
+            price = EXCLUDED.price,
+            quantity = EXCLUDED.quantity,
+            category = EXCLUDED.category,
+            is_recommended = EXCLUDED.is_recommended,
+            image_path = EXCLUDED.image_path
+        WHERE (product_info.product_name, product_info.price, product_info.quantity, product_info.category, product_info.is_recommended, product_info.image_path)
+            IS DISTINCT FROM (EXCLUDED.product_name, EXCLUDED.price, EXCLUDED.quantity, EXCLUDED.category, EXCLUDED.is_recommended, EXCLUDED.image_path)
+        RETURNING id, created_at, updated_at, (xmax = 0)
+    `
+	var inserted bool
+	err := q.QueryRowContext(ctx, query,
+		product.ProductName, product.Price, product.Quantity, product.Category, product.Brand,
+		product.Model, product.StoreID, product.IsRecommended, product.ImagePath,
+	).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt, &inserted)
+
+	outcome := UpsertUpdated
+	if inserted {
+		outcome = UpsertCreated
+	}
+	if err == sql.ErrNoRows {
+		outcome = UpsertUnchanged
+		err = q.QueryRowContext(ctx,
+			`SELECT id, created_at, updated_at FROM product_info WHERE store_id = $1 AND brand = $2 AND model = $3`,
+			product.StoreID, product.Brand, product.Model,
+		).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+	}
+	if err != nil {
+		return product, "", fmt.Errorf("failed to upsert product: %w", err)
+	}
+	return product, outcome, nil
+}
+
+// UpsertProduct เพิ่มสินค้าใหม่ หรืออัปเดตสินค้าที่มี store_id, brand และ model เดียวกันอยู่แล้ว
+func (pdb *PostgresDatabase) UpsertProduct(ctx context.Context, product Product) (_ Product, err error) {
+	defer pdb.observe(ctx, "UpsertProduct", slog.Int("store_id", product.StoreID), slog.String("model", product.Model))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+	product, _, err = upsertProduct(ctx, db, product)
+	return product, err
+}
+
+// importBatchSize จำนวนสินค้าที่ upsert ต่อคำสั่งตอน import
+const importBatchSize = 500
+
+// productKey natural key ของสินค้า ตรงกับ unique index (store_id, brand, model)
+type productKey struct {
+	storeID      int
+	brand, model string
+}
+
+// upsertProducts upsert สินค้าหลายรายการด้วยคำสั่งเดียว ผลลัพธ์เหมือนเรียก upsertProduct ทีละรายการ
+// products ต้องไม่มี store_id, brand และ model ซ้ำกันเอง ไม่อย่างนั้น Postgres จะไม่ยอมให้อัปเดตแถวเดียวกันสองครั้ง
+func upsertProducts(ctx context.Context, tx *sql.Tx, products []Product) ([]ProductUpsert, error) {
+	n := len(products)
+	names, categories, brands, models, images := make([]string, n), make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	prices := make([]float64, n)
+	quantities, storeIDs := make([]int64, n), make([]int64, n)
+	recommended := make([]bool, n)
+	for i, p := range products {
+		names[i], categories[i], brands[i], models[i], images[i] = p.ProductName, p.Category, p.Brand, p.Model, p.ImagePath
+		prices[i] = p.Price
+		quantities[i], storeIDs[i] = int64(p.Quantity), int64(p.StoreID)
+		recommended[i] = p.IsRecommended
+	}
+
+	// เงื่อนไขเหมือน upsertProduct: แถวที่ไม่เปลี่ยนจะไม่ถูกอัปเดตและไม่มีใน RETURNING
+	query := `
+        INSERT INTO product_info (product_name, price, quantity, category, brand, model, store_id, is_recommended, image_path)
+        SELECT * FROM unnest($1::text[], $2::numeric[], $3::int[], $4::text[], $5::text[], $6::text[], $7::int[], $8::boolean[], $9::text[])
+        ON CONFLICT (store_id, brand, model) DO UPDATE SET
+            product_name = EXCLUDED.product_name,
+            price = EXCLUDED.price,
+            quantity = EXCLUDED.quantity,
+            category = EXCLUDED.category,
+            is_recommended = EXCLUDED.is_recommended,
+            image_path = EXCLUDED.image_path
+        WHERE (product_info.product_name, product_info.price, product_info.quantity, product_info.category, product_info.is_recommended, product_info.image_path)
+            IS DISTINCT FROM (EXCLUDED.product_name, EXCLUDED.price, EXCLUDED.quantity, EXCLUDED.category, EXCLUDED.is_recommended, EXCLUDED.image_path)
+        RETURNING id, store_id, brand, model, created_at, updated_at, (xmax = 0)
+    `
+	saved := make(map[productKey]ProductUpsert, n)
+	rows, err := tx.QueryContext(ctx, query,
+		pq.Array(names), pq.Array(prices), pq.Array(quantities), pq.Array(categories), pq.Array(brands),
+		pq.Array(models), pq.Array(storeIDs), pq.Array(recommended), pq.Array(images))
+	if err == nil {
+		err = scanUpserts(rows, saved, UpsertUpdated)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert products: %w", err)
+	}
+
+	if len(saved) < n {
+		rows, err := tx.QueryContext(ctx, `
+            SELECT id, store_id, brand, model, created_at, updated_at, false
+            FROM product_info
+            WHERE (store_id, brand, model) IN (SELECT * FROM unnest($1::int[], $2::text[], $3::text[]))
+        `, pq.Array(storeIDs), pq.Array(brands), pq.Array(models))
+		if err == nil {
+			err = scanUpserts(rows, saved, UpsertUnchanged)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read unchanged products: %w", err)
+		}
+	}
+
+	results := make([]ProductUpsert, n)
+	for i, p := range products {
+		result, ok := saved[productKey{p.StoreID, p.Brand, p.Model}]
+		if !ok {
+			return nil, fmt.Errorf("product %s %s was not saved", p.Brand, p.Model)
+		}
+		p.ID, p.CreatedAt, p.UpdatedAt = result.Product.ID, result.Product.CreatedAt, result.Product.UpdatedAt
+		results[i] = ProductUpsert{Product: p, Outcome: result.Outcome}
+	}
+	return results, nil
+}
+
+// scanUpserts อ่านแถว (id, store_id, brand, model, created_at, updated_at, inserted) ลงใน saved แล้วปิด rows
+// แถวที่ inserted เป็น true ได้ผล UpsertCreated นอกนั้นได้ outcome
+func scanUpserts(rows *sql.Rows, saved map[productKey]ProductUpsert, outcome string) error {
+	defer rows.Close()
+	for rows.Next() {
+		var p Product
+		var inserted bool
+		if err := rows.Scan(&p.ID, &p.StoreID, &p.Brand, &p.Model, &p.CreatedAt, &p.UpdatedAt, &inserted); err != nil {
+			return err
+		}
+		result := ProductUpsert{Product: p, Outcome: outcome}
+		if inserted {
+			result.Outcome = UpsertCreated
+		}
+		saved[productKey{p.StoreID, p.Brand, p.Model}] = result
+	}
+	return rows.Err()
+}
+
+// ImportProducts upsert สินค้าทุกรายการใน transaction เดียว ครั้งละ importBatchSize รายการ
+// ถ้ารายการไหนล้มเหลวจะไม่บันทึกเลยสักรายการ
+// ถ้า dryRun เป็น true จะ rollback ทุกครั้ง ใช้ดูผลลัพธ์ได้โดยไม่แก้ข้อมูลจริง คืนผลของแต่ละรายการตามลำดับ
+func (pdb *PostgresDatabase) ImportProducts(ctx context.Context, products []Product, dryRun bool) (_ []ProductUpsert, err error) {
+	defer pdb.observe(ctx, "ImportProducts", slog.Int("rows", len(products)), slog.Bool("dry_run", dryRun))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	results := make([]ProductUpsert, 0, len(products))
+	for start := 0; start < len(products); start += importBatchSize {
+		end := min(start+importBatchSize, len(products))
+		batch, err := upsertProducts(ctx, tx, products[start:end])
+		if err != nil {
+			return results, fmt.Errorf("import of items %d to %d failed: %w", start+1, end, err)
+		}
+		results = append(results, batch...)
+	}
+
+	if dryRun {
+		return results, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return results, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return results, nil
+}
+
+// UpsertCategory เพิ่มหมวดหมู่ใหม่ หรืออัปเดตหมวดหมู่ที่มีชื่อเดียวกันอยู่แล้ว
+func (pdb *PostgresDatabase) UpsertCategory(ctx context.Context, category Category) (_ Category, err error) {
+	defer pdb.observe(ctx, "UpsertCategory", slog.String("category", category.Name))(&err)
+	defer pdb.noteWrite(ctx, &err)
+	db, release := pdb.acquire()
+	defer release()
+	query := `
+        INSERT INTO categories (name, description, image_path)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (name) DO UPDATE SET
+            description = EXCLUDED.description,
+            image_path = EXCLUDED.image_path
+        RETURNING id
+    `
+	err = db.QueryRowContext(ctx, query, category.Name, category.Description, category.ImagePath).Scan(&category.ID)
+	if err != nil {
+		return category, fmt.Errorf("failed to upsert category: %w", err)
+	}
+	return category, nil
+}
+
+// validateStore ตรวจสอบข้อมูลร้านก่อนบันทึก
+func validateStore(store StoreInfo) error {
+	fields := map[string]string{}
+	if strings.TrimSpace(store.StoreName) == "" {
+		fields["store_name"] = "is required"
+	}
+	return validationError("invalid store", fields)
+}
+
+// ValidateProduct ตรวจสอบข้อมูลสินค้าก่อนบันทึก brand และ model จำเป็นเพราะใช้เป็นคีย์ของสินค้าในร้าน
+func ValidateProduct(product Product) error {
+	fields := map[string]string{}
+	if strings.TrimSpace(product.ProductName) == "" {
+		fields["product_name"] = "is required"
+	}
+	if product.Price < 0 {
+		fields["price"] = "must not be negative"
+	}
+	if product.Quantity < 0 {
+		fields["quantity"] = "must not be negative"
+	}
+	if strings.TrimSpace(product.Brand) == "" {
+		fields["brand"] = "is required"
+	}
+	if strings.TrimSpace(product.Model) == "" {
+		fields["model"] = "is required"
+	}
+	if product.StoreID <= 0 {
+		fields["store_id"] = "is required"
+	}
+	if strings.TrimSpace(product.ImagePath) == "" {
+		fields["image_path"] = "is required"
+	}
+
+	// ความยาวต้องไม่เกินขนาดคอลัมน์ใน product_info
+	for field, value := range map[string]string{
+		"product_name": product.ProductName,
+		"image_path":   product.ImagePath,
+	} {
+		if utf8.RuneCountInString(value) > 255 {
+			fields[field] = "must be at most 255 characters"
+		}
+	}
+	for field, value := range map[string]string{
+		"category": product.Category,
+		"brand":    product.Brand,
+		"model":    product.Model,
+	} {
+		if utf8.RuneCountInString(value) > 100 {
+			fields[field] = "must be at most 100 characters"
+		}
+	}
+	if product.Price >= 1e8 {
+		fields["price"] = "must be less than 100000000"
+	}
+
+	return validationError("invalid product", fields)
+}
+
+func (bs *BookStore) UpsertStore(ctx context.Context, store StoreInfo) (StoreInfo, error) {
+	if err := validateStore(store); err != nil {
+		return store, err
+	}
+	return bs.db.UpsertStore(ctx, store)
+}
+
+func (bs *BookStore) UpsertProduct(ctx context.Context, product Product) (Product, error) {
+	if err := ValidateProduct(product); err != nil {
+		return product, err
+	}
+	return bs.db.UpsertProduct(ctx, product)
+}
+
+func (bs *BookStore) UpsertCategory(ctx context.Context, category Category) (Category, error) {
+	if strings.TrimSpace(category.Name) == "" {
+		return category, validationError("invalid category", map[string]string{"name": "is required"})
+	}
+	return bs.db.UpsertCategory(ctx, category)
+}
+
+// EachProductByStore อ่านสินค้าทั้งหมดของร้านทีละแถวแล้วส่งให้ fn โดยไม่โหลดทั้งหมดไว้ในหน่วยความจำ
+// activeOnly เลือกเฉพาะสินค้าที่ยังขายอยู่ ถ้า fn คืน error จะหยุดอ่านและคืน error นั้น
+func (pdb *PostgresDatabase) EachProductByStore(ctx context.Context, storeID int, activeOnly bool, fn func(Product) error) (err error) {
+	defer pdb.observe(ctx, "EachProductByStore", slog.Int("store_id", storeID), slog.Bool("active_only", activeOnly))(&err)
+	db, release := pdb.acquireRead(ctx)
+	defer release(&err)
+	query := `
+        SELECT id, product_name, price, quantity, created_at, updated_at, category, brand, model, store_id, is_recommended, image_path
+        FROM product_info
+        WHERE store_id = $1 AND (is_active OR NOT $2)
+        ORDER BY id
+    `
+	rows, err := db.QueryContext(ctx, query, storeID, activeOnly)
+	if err != nil {
+		return fmt.Errorf("failed to get products: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product Product
+		if err := rows.Scan(
+			&product.ID,
+			&product.ProductName,
+			&product.Price,
+			&product.Quantity,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&product.Category,
+			&product.Brand,
+			&product.Model,
+			&product.StoreID,
+			&product.IsRecommended,
+			&product.ImagePath,
+		); err != nil {
+			return fmt.Errorf("failed to scan product: %w", err)
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read products: %w", err)
+	}
+	return nil
+}
+
+// ProductUpsert สินค้าที่บันทึกแล้วพร้อมผลว่าเป็นการสร้าง อัปเดต หรือไม่เปลี่ยน
+type ProductUpsert struct {
+	Product Product
+	Outcome string
+}
+
+// ProductImportRow สินค้า 1 แถวจากไฟล์ import พร้อม error ที่พบตอนอ่านไฟล์ (เช่น ราคาไม่ใช่ตัวเลข)
+type ProductImportRow struct {
+	Row     int
+	Product Product
+	Errors  map[string]string
+}
+
+// ImportRowResult ผลของแต่ละแถวในรายงาน import
+type ImportRowResult struct {
+	Row       int               `json:"row"`
+	Status    string            `json:"status"`
+	ProductID int               `json:"product_id,omitempty"`
+	Brand     string            `json:"brand"`
+	Model     string            `json:"model"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// ImportReport รายงานผลการ import ทั้งไฟล์
+type ImportReport struct {
+	StoreID     int               `json:"store_id"`
+	DryRun      bool              `json:"dry_run"`
+	Committed   bool              `json:"committed"`
+	TotalRows   int               `json:"total_rows"`
+	InvalidRows int               `json:"invalid_rows"`
+	Created     int               `json:"created"`
+	Updated     int               `json:"updated"`
+	Unchanged   int               `json:"unchanged"`
+	Rows        []ImportRowResult `json:"rows"`
+}
+
+// EachProductByStore ส่งสินค้าของร้านให้ fn ทีละรายการ ใช้สำหรับ export ข้อมูลขนาดใหญ่
+func (bs *BookStore) EachProductByStore(ctx context.Context, storeID int, activeOnly bool, fn func(Product) error) error {
+	return bs.db.EachProductByStore(ctx, storeID, activeOnly, fn)
+}
+
+// ErrImportRejected ไฟล์ import มีแถวที่ไม่ผ่านการตรวจสอบ จึงไม่ได้บันทึกเลยสักแถว
+var ErrImportRejected = NewError(ErrValidation, "import contains invalid rows, nothing was saved")
+
+// ImportProducts ตรวจสอบทุกแถวแล้ว upsert สินค้าของร้านในครั้งเดียว ถ้ามีแถวผิดแม้แถวเดียวจะไม่บันทึกอะไรเลย
+// และคืนรายงานพร้อม ErrImportRejected
+func (bs *BookStore) ImportProducts(ctx context.Context, storeID int, rows []ProductImportRow, dryRun bool) (ImportReport, error) {
+	report := ImportReport{StoreID: storeID, DryRun: dryRun, TotalRows: len(rows), Rows: make([]ImportRowResult, len(rows))}
+
+	if _, err := bs.db.GetStoreInfoByID(ctx, storeID); err != nil {
+		return report, err
+	}
+
+	// ตรวจสอบทุกแถวก่อน รวมถึงสินค้าที่ซ้ำกันเองในไฟล์
+	seen := map[string]int{}
+	products := make([]Product, len(rows))
+	for i, row := range rows {
+		row.Product.StoreID = storeID
+		products[i] = row.Product
+
+		errs := map[string]string{}
+		for field, msg := range row.Errors {
+			errs[field] = msg
+		}
+		var verr *Error
+		if err := ValidateProduct(row.Product); errors.As(err, &verr) {
+			for field, msg := range verr.Details["fields"].(map[string]string) {
+				if _, ok := errs[field]; !ok {
+					errs[field] = msg
+				}
+			}
+		}
+
+		key := row.Product.Brand + "\x00" + row.Product.Model
+		if first, ok := seen[key]; ok && row.Product.Brand != "" {
+			errs["model"] = fmt.Sprintf("duplicate of row %d (same brand and model)", first)
+		} else {
+			seen[key] = row.Row
+		}
+
+		report.Rows[i] = ImportRowResult{Row: row.Row, Status: "valid", Brand: row.Product.Brand, Model: row.Product.Model}
+		if len(errs) > 0 {
+			report.Rows[i].Status = "invalid"
+			report.Rows[i].Errors = errs
+			report.InvalidRows++
+		}
+	}
+	if report.InvalidRows > 0 {
+		return report, ErrImportRejected.WithDetails(map[string]interface{}{"report": report})
+	}
+
+	results, err := bs.db.ImportProducts(ctx, products, dryRun)
+	if err != nil {
+		return report, err
+	}
+
+	for i, result := range results {
+		report.Rows[i].Status = result.Outcome
+		report.Rows[i].ProductID = result.Product.ID
+		switch result.Outcome {
+		case UpsertCreated:
+			report.Created++
+		case UpsertUpdated:
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+	}
+	report.Committed = !dryRun
+	return report, nil
+}
@@ -0,0 +1,277 @@
+// connection.go
+package bookstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolConfig การตั้งค่า connection pool ใช้ทั้งตอนเชื่อมต่อครั้งแรกและตอนเชื่อมต่อใหม่
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime connection ที่ว่างนานกว่านี้จะถูกปิด (0 = ไม่จำกัด)
+	ConnMaxIdleTime time.Duration
+	// PingTimeout เวลาสูงสุดของการ ping ตอนเชื่อมต่อและตรวจสอบการเชื่อมต่อ
+	PingTimeout time.Duration
+
+	// Replicas connection string ของ read replica (ไม่บังคับ) คำสั่งที่อ่านอย่างเดียวจะกระจายไปที่ replica
+	Replicas []string
+	// ReplicaStickiness หลังจาก session เขียนข้อมูล การอ่านของ session นั้นจะไปที่ primary นานเท่านี้
+	ReplicaStickiness time.Duration
+}
+
+// defaultPingTimeout ใช้เมื่อไม่ได้กำหนด PingTimeout
+const defaultPingTimeout = 5 * time.Second
+
+// pool connection pool 1 ชุด พร้อมตัวนับจำนวน method ที่กำลังใช้งานอยู่
+type pool struct {
+	db    *sql.DB
+	users sync.WaitGroup
+}
+
+// PostgresDatabase เป็น struct ที่เชื่อมต่อกับ PostgreSQL Database จริง
+//
+// ทุก method ยืม pool ปัจจุบันผ่าน acquire และคืนเมื่อทำงานเสร็จ Reconnect จึงเปลี่ยน pool ได้ระหว่างที่มี request ทำงานอยู่
+// โดย pool เดิมจะถูกปิดหลังจาก method ที่ยืมไปคืนครบแล้วเท่านั้น
+type PostgresDatabase struct {
+	connStr string
+	cfg     PoolConfig
+
+	mu      sync.RWMutex
+	current *pool
+	closed  bool
+
+	replicas    []*replica
+	nextReplica atomic.Uint64
+	sessions    sessions
+}
+
+// NewPostgresDatabase สร้าง PostgresDatabase ใหม่และเชื่อมต่อกับฐานข้อมูล
+func NewPostgresDatabase(connStr string, cfg PoolConfig) (*PostgresDatabase, error) {
+	cfg = cfg.withDefaults()
+	db, err := openPool(connStr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newPostgresDatabase(connStr, cfg, db)
+}
+
+// OpenPostgresDatabase สร้าง PostgresDatabase โดยยังไม่เชื่อมต่อ connection จะถูกสร้างเมื่อมีการใช้งานครั้งแรก
+// ใช้กับเซิร์ฟเวอร์ที่ต้องเริ่มทำงานได้แม้ฐานข้อมูลยังไม่พร้อม
+func OpenPostgresDatabase(connStr string, cfg PoolConfig) (*PostgresDatabase, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	cfg = cfg.withDefaults()
+	cfg.apply(db)
+	return newPostgresDatabase(connStr, cfg, db)
+}
+
+func newPostgresDatabase(connStr string, cfg PoolConfig, db *sql.DB) (*PostgresDatabase, error) {
+	replicas, err := openReplicas(cfg)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database replica: %w", err)
+	}
+	return &PostgresDatabase{connStr: connStr, cfg: cfg, current: &pool{db: db}, replicas: replicas}, nil
+}
+
+func (cfg PoolConfig) withDefaults() PoolConfig {
+	if cfg.PingTimeout <= 0 {
+		cfg.PingTimeout = defaultPingTimeout
+	}
+	if cfg.ReplicaStickiness <= 0 {
+		cfg.ReplicaStickiness = defaultReplicaStickiness
+	}
+	return cfg
+}
+
+func (cfg PoolConfig) apply(db *sql.DB) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// openPool สร้าง pool ใหม่และทดสอบการเชื่อมต่อ ถ้าเชื่อมต่อไม่ได้จะปิด pool นั้นทิ้ง
+func openPool(connStr string, cfg PoolConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	cfg.apply(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.PingTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return db, nil
+}
+
+// acquire ยืม pool ปัจจุบัน ต้องเรียก release เมื่อใช้เสร็จ (รวมถึงหลังปิด rows และ transaction แล้ว)
+// หลังจาก Close แล้วจะได้ pool ที่ปิดไปแล้ว ซึ่งทุกคำสั่งจะคืน error "sql: database is closed"
+func (pdb *PostgresDatabase) acquire() (db *sql.DB, release func()) {
+	pdb.mu.RLock()
+	defer pdb.mu.RUnlock()
+
+	p := pdb.current
+	if pdb.closed {
+		return p.db, func() {}
+	}
+	p.users.Add(1)
+	return p.db, p.users.Done
+}
+
+// WithDB เรียก fn ด้วย connection pool ปัจจุบัน สำหรับงานที่อยู่นอกขอบเขตของ BookDatabase เช่น ตรวจสอบ migration
+// fn ต้องไม่เก็บ db ไว้ใช้หลังจากคืนค่าแล้ว
+func (pdb *PostgresDatabase) WithDB(fn func(db *sql.DB) error) error {
+	db, release := pdb.acquire()
+	defer release()
+	return fn(db)
+}
+
+// Stats สถิติของ connection pool ปัจจุบัน
+func (pdb *PostgresDatabase) Stats() sql.DBStats {
+	pdb.mu.RLock()
+	defer pdb.mu.RUnlock()
+	return pdb.current.db.Stats()
+}
+
+// Close ปิดฐานข้อมูลและ replica ทั้งหมด โดยรอให้ method ที่กำลังใช้งาน pool อยู่ทำงานเสร็จก่อน
+func (pdb *PostgresDatabase) Close() error {
+	pdb.mu.Lock()
+	if pdb.closed {
+		pdb.mu.Unlock()
+		return nil
+	}
+	pdb.closed = true
+	p := pdb.current
+	pdb.mu.Unlock()
+
+	for _, r := range pdb.replicas {
+		r.pool.users.Wait()
+		r.pool.db.Close()
+	}
+	p.users.Wait()
+	return p.db.Close()
+}
+
+func (pdb *PostgresDatabase) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pdb.cfg.PingTimeout)
+	defer cancel()
+	return pdb.PingContext(ctx)
+}
+
+// PingContext ตรวจสอบการเชื่อมต่อภายในเวลาที่ ctx กำหนด
+func (pdb *PostgresDatabase) PingContext(ctx context.Context) error {
+	db, release := pdb.acquire()
+	defer release()
+	return db.PingContext(ctx)
+}
+
+// ErrDatabaseClosed เรียก Reconnect หลังจากปิดฐานข้อมูลไปแล้ว
+var ErrDatabaseClosed = errors.New("database is closed")
+
+// Reconnect สร้าง pool ใหม่ด้วยการตั้งค่าเดิม แล้วเปลี่ยนมาใช้ pool ใหม่เมื่อเชื่อมต่อได้แล้วเท่านั้น
+// ถ้าเชื่อมต่อไม่ได้ pool เดิมจะยังถูกใช้ต่อไป ส่วน pool เดิมที่ถูกแทนที่จะปิดเมื่อ method ที่ยืมไปคืนครบแล้ว
+func (pdb *PostgresDatabase) Reconnect() error {
+	db, err := openPool(pdb.connStr, pdb.cfg)
+	if err != nil {
+		return err
+	}
+
+	pdb.mu.Lock()
+	if pdb.closed {
+		pdb.mu.Unlock()
+		db.Close()
+		return ErrDatabaseClosed
+	}
+	old := pdb.current
+	pdb.current = &pool{db: db}
+	pdb.mu.Unlock()
+
+	go func() {
+		old.users.Wait()
+		old.db.Close()
+	}()
+	return nil
+}
+
+// SuperviseOptions การตั้งค่าของ Supervise
+type SuperviseOptions struct {
+	// Interval รอบเวลาตรวจสอบการเชื่อมต่อเมื่อทุกอย่างปกติ
+	Interval time.Duration
+	// MinBackoff และ MaxBackoff ช่วงเวลารอระหว่างการลองเชื่อมต่อใหม่ เพิ่มขึ้นเป็นเท่าตัวจาก MinBackoff จนถึง MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnReconnect ถูกเรียกหลังการสร้าง pool ใหม่แต่ละครั้งพร้อมผลลัพธ์ (ไม่บังคับ)
+	OnReconnect func(err error)
+}
+
+// Supervise ตรวจสอบการเชื่อมต่อทันทีที่เริ่มและทุก ๆ Interval จนกว่า ctx จะถูกยกเลิก รวมถึงสถานะของ replica ด้วย
+// ถ้ายังไม่เคยเชื่อมต่อได้ จะ ping ซ้ำไปเรื่อย ๆ (pool จะเชื่อมต่อเอง) ถ้าการเชื่อมต่อที่เคยใช้ได้หลุดไปจะสร้าง pool ใหม่
+// การลองแต่ละครั้งเว้นระยะแบบ exponential backoff พร้อม jitter เพื่อไม่ให้หลาย instance เชื่อมต่อพร้อมกัน
+func (pdb *PostgresDatabase) Supervise(ctx context.Context, opts SuperviseOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+	opts.MaxBackoff = max(opts.MaxBackoff, opts.MinBackoff)
+
+	connected, everConnected := false, false
+	backoff := opts.MinBackoff
+	wait := time.Duration(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		pdb.checkReplicas(ctx)
+		pdb.pruneSessions()
+
+		err := pdb.Ping()
+		if err != nil && connected {
+			slog.Warn("Database connection lost", slog.Any("error", err))
+			connected = false
+		}
+		if err != nil && everConnected {
+			// พยายามเชื่อมต่อใหม่
+			err = pdb.Reconnect()
+			if opts.OnReconnect != nil {
+				opts.OnReconnect(err)
+			}
+		}
+		if errors.Is(err, ErrDatabaseClosed) {
+			return
+		}
+		if err != nil {
+			wait = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+			slog.Error("Failed to connect to database", slog.Any("error", err), slog.Duration("retry_in", wait))
+			backoff = min(backoff*2, opts.MaxBackoff)
+			continue
+		}
+
+		if !connected {
+			slog.Info("Connected to the database")
+			connected, everConnected = true, true
+		}
+		wait, backoff = opts.Interval, opts.MinBackoff
+	}
+}
@@ -0,0 +1,87 @@
+// errors.go
+package bookstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ประเภทของ error ที่ handler ใช้ตัดสินว่าจะตอบ status code อะไร
+// error ที่ไม่ได้อยู่ในประเภทเหล่านี้ถือเป็น error ภายใน และจะไม่ส่งข้อความจริงกลับไปให้ client
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrConflict      = errors.New("conflict")
+	ErrValidation    = errors.New("validation failed")
+	ErrOutOfStock    = errors.New("not enough stock for the requested quantity")
+	ErrPaymentFailed = errors.New("payment failed")
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrForbidden     = errors.New("forbidden")
+)
+
+// Error error ของโดเมนที่มีข้อความซึ่งส่งให้ client ได้ และบอกประเภทผ่าน Kind
+type Error struct {
+	Kind    error
+	Message string
+	Details map[string]interface{}
+}
+
+// NewError สร้าง Error ประเภท kind พร้อมข้อความสำหรับ client
+func NewError(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap ทำให้ errors.Is(err, ErrNotFound) ใช้ได้กับ error ที่สร้างจาก NewError
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// WithDetails คืน Error ตัวใหม่ที่มีรายละเอียดเพิ่มเติม เช่น ฟิลด์ที่ไม่ผ่านการตรวจสอบ
+func (e *Error) WithDetails(details map[string]interface{}) *Error {
+	return &Error{Kind: e.Kind, Message: e.Message, Details: details}
+}
+
+// error เฉพาะของร้าน สินค้า และตะกร้า
+var (
+	ErrStoreNotFound     = NewError(ErrNotFound, "store not found")
+	ErrProductNotFound   = NewError(ErrNotFound, "product not found")
+	ErrProductNotInStore = NewError(ErrNotFound, "product does not belong to this store")
+	ErrCartItemNotFound  = NewError(ErrNotFound, "cart item not found")
+	ErrProductInactive   = NewError(ErrValidation, "product is not available for sale")
+	ErrInvalidQuantity   = NewError(ErrValidation, "quantity must be greater than zero")
+	ErrQuantityTooLarge  = NewError(ErrValidation, fmt.Sprintf("quantity of one cart item must not exceed %d", MaxCartItemQuantity))
+	ErrStoreNameTaken    = NewError(ErrConflict, "a store with this name already exists")
+	ErrOrderNotFound     = NewError(ErrNotFound, "order not found")
+)
+
+// validationError สร้าง error ประเภท ErrValidation ที่บอกว่าฟิลด์ไหนผิด คืน nil ถ้าไม่มีฟิลด์ที่ผิด
+func validationError(message string, fields map[string]string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return NewError(ErrValidation, message).WithDetails(map[string]interface{}{"fields": fields})
+}
+
+// DescribeFields รวมรายละเอียดของฟิลด์ที่ไม่ผ่านการตรวจสอบไว้ในข้อความ error สำหรับแสดงใน command line
+// error อื่นคืนค่าเดิม
+func DescribeFields(err error) error {
+	var domainErr *Error
+	if !errors.As(err, &domainErr) {
+		return err
+	}
+	fields, _ := domainErr.Details["fields"].(map[string]string)
+	if len(fields) == 0 {
+		return err
+	}
+	parts := make([]string, 0, len(fields))
+	for field, problem := range fields {
+		parts = append(parts, field+" "+problem)
+	}
+	sort.Strings(parts)
+	return fmt.Errorf("%s: %s", err, strings.Join(parts, "; "))
+}
@@ -0,0 +1,92 @@
+// observe.go
+package bookstore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// slowQueryThreshold คำสั่งที่ใช้เวลานานกว่านี้จะถูก log ที่ระดับ warn
+const slowQueryThreshold = time.Second
+
+var tracer = otel.Tracer("myproject/internal/bookstore")
+
+// observe บันทึกเวลาที่ใช้และ error ของ method ในฐานข้อมูลลง log และสร้าง span ของ OpenTelemetry ใช้แบบ
+//
+//	defer pdb.observe(ctx, "GetProduct", slog.Int("product_id", id))(&err)
+//
+// attrs ถูกบันทึกทั้งใน log และ span จึงต้องเป็นค่าที่บันทึกได้อย่างปลอดภัย (ห้ามใส่ token หรือข้อมูลติดต่อลูกค้า)
+// error ของโดเมน (เช่น ไม่พบสินค้า) เป็นผลปกติ จึง log ที่ระดับ debug ส่วน error อื่นๆ log ที่ระดับ error
+func (pdb *PostgresDatabase) observe(ctx context.Context, op string, attrs ...slog.Attr) func(*error) {
+	start := time.Now()
+	_, span := tracer.Start(ctx, "bookstore."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(spanAttrs(op, attrs)...),
+	)
+	return func(errp *error) {
+		defer span.End()
+		if err := *errp; err != nil {
+			span.RecordError(err)
+			var domainErr *Error
+			if !errors.As(err, &domainErr) {
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}
+
+		elapsed := time.Since(start)
+		attrs = append(attrs,
+			slog.String("op", op),
+			slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+		)
+
+		level, msg := slog.LevelDebug, "database query"
+		var domainErr *Error
+		switch err := *errp; {
+		case err != nil && !errors.As(err, &domainErr):
+			level, msg = slog.LevelError, "database query failed"
+			attrs = append(attrs, slog.Any("error", err))
+		case err != nil:
+			attrs = append(attrs, slog.String("error", err.Error()))
+		case elapsed > slowQueryThreshold:
+			level, msg = slog.LevelWarn, "slow database query"
+		}
+		slog.LogAttrs(ctx, level, msg, attrs...)
+	}
+}
+
+// ownerAttr ระบุเจ้าของตะกร้าใน log โดยไม่บันทึก token ของแขก
+func ownerAttr(owner CartOwner) slog.Attr {
+	if owner.UserID != 0 {
+		return slog.Int("user_id", owner.UserID)
+	}
+	return slog.Bool("guest", owner.Token != "")
+}
+
+// spanAttrs แปลง attrs ของ log เป็น attribute ของ span ตามชื่อที่ OpenTelemetry แนะนำสำหรับฐานข้อมูล
+func spanAttrs(op string, attrs []slog.Attr) []attribute.KeyValue {
+	kvs := []attribute.KeyValue{
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation.name", op),
+	}
+	for _, a := range attrs {
+		key := "app." + a.Key
+		switch a.Value.Kind() {
+		case slog.KindInt64:
+			kvs = append(kvs, attribute.Int64(key, a.Value.Int64()))
+		case slog.KindBool:
+			kvs = append(kvs, attribute.Bool(key, a.Value.Bool()))
+		case slog.KindFloat64:
+			kvs = append(kvs, attribute.Float64(key, a.Value.Float64()))
+		default:
+			kvs = append(kvs, attribute.String(key, a.Value.String()))
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,252 @@
+// replicas.go
+package bookstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// defaultReplicaStickiness ใช้เมื่อไม่ได้กำหนด ReplicaStickiness
+const defaultReplicaStickiness = 5 * time.Second
+
+// replica read replica 1 ตัว pool ของ replica ไม่ถูกเปลี่ยนระหว่างทำงาน จึงไม่ต้องผ่าน Reconnect
+// healthy เริ่มต้นเป็น false จนกว่า Supervise จะ ping ผ่าน ก่อนหน้านั้นการอ่านทั้งหมดไปที่ primary
+type replica struct {
+	host    string
+	pool    *pool
+	healthy atomic.Bool
+}
+
+// sessions เวลาที่แต่ละ session เขียนข้อมูลครั้งล่าสุด ใช้บังคับให้การอ่านหลังการเขียนไปที่ primary
+// เพื่อไม่ให้ผู้ใช้เห็นข้อมูลเก่าจาก replica ที่ยังตามไม่ทัน (read-your-writes)
+type sessions struct {
+	mu        sync.Mutex
+	lastWrite map[string]time.Time
+}
+
+type sessionKey struct{}
+
+// WithSession ผูก ctx กับเจ้าของตะกร้า การอ่านที่ตามหลังการเขียนของเจ้าของคนเดียวกันภายในช่วง ReplicaStickiness
+// จะอ่านจาก primary เสมอ เช่น ดูตะกร้าทันทีหลัง add_to_cart
+func WithSession(ctx context.Context, owner CartOwner) context.Context {
+	if owner.IsZero() {
+		return ctx
+	}
+	key := "guest:" + owner.Token
+	if owner.UserID != 0 {
+		key = "user:" + strconv.Itoa(owner.UserID)
+	}
+	return context.WithValue(ctx, sessionKey{}, key)
+}
+
+func sessionFrom(ctx context.Context) string {
+	key, _ := ctx.Value(sessionKey{}).(string)
+	return key
+}
+
+// openReplicas เปิด pool ของ replica แบบยังไม่เชื่อมต่อ ด้วยการตั้งค่า pool เดียวกับ primary
+func openReplicas(cfg PoolConfig) ([]*replica, error) {
+	var replicas []*replica
+	for _, connStr := range cfg.Replicas {
+		db, err := sql.Open("postgres", connStr)
+		if err != nil {
+			for _, r := range replicas {
+				r.pool.db.Close()
+			}
+			return nil, err
+		}
+		cfg.apply(db)
+		replicas = append(replicas, &replica{host: connHost(connStr), pool: &pool{db: db}})
+	}
+	return replicas, nil
+}
+
+// connHost ดึงชื่อ host จาก connection string (แบบ key=value หรือ URL) ไว้ใช้ใน log โดยไม่ให้รหัสผ่านหลุดออกไป
+func connHost(connStr string) string {
+	if u, err := url.Parse(connStr); err == nil && u.Host != "" {
+		return u.Host
+	}
+	for _, field := range strings.Fields(connStr) {
+		if host, ok := strings.CutPrefix(field, "host="); ok {
+			return strings.Trim(host, "'")
+		}
+	}
+	return "unknown"
+}
+
+// noteWrite บันทึกว่า session ใน ctx เพิ่งเขียนข้อมูลสำเร็จ ใช้แบบ defer pdb.noteWrite(ctx, &err)
+func (pdb *PostgresDatabase) noteWrite(ctx context.Context, errp *error) {
+	key := sessionFrom(ctx)
+	if key == "" || len(pdb.replicas) == 0 || *errp != nil {
+		return
+	}
+	pdb.sessions.mu.Lock()
+	defer pdb.sessions.mu.Unlock()
+	if pdb.sessions.lastWrite == nil {
+		pdb.sessions.lastWrite = map[string]time.Time{}
+	}
+	pdb.sessions.lastWrite[key] = time.Now()
+}
+
+// wroteRecently session ใน ctx เขียนข้อมูลภายในช่วง ReplicaStickiness หรือไม่
+func (pdb *PostgresDatabase) wroteRecently(ctx context.Context) bool {
+	key := sessionFrom(ctx)
+	if key == "" {
+		return false
+	}
+	pdb.sessions.mu.Lock()
+	defer pdb.sessions.mu.Unlock()
+	last, ok := pdb.sessions.lastWrite[key]
+	return ok && time.Since(last) < pdb.cfg.ReplicaStickiness
+}
+
+// pruneSessions ลบ session ที่พ้นช่วง ReplicaStickiness แล้ว ถูกเรียกจาก Supervise ทุกรอบ
+func (pdb *PostgresDatabase) pruneSessions() {
+	pdb.sessions.mu.Lock()
+	defer pdb.sessions.mu.Unlock()
+	for key, last := range pdb.sessions.lastWrite {
+		if time.Since(last) >= pdb.cfg.ReplicaStickiness {
+			delete(pdb.sessions.lastWrite, key)
+		}
+	}
+}
+
+// acquireRead ยืม pool สำหรับคำสั่งที่อ่านอย่างเดียว เลือก replica ที่ใช้งานได้แบบวนรอบ
+// และใช้ primary เมื่อไม่มี replica ที่ใช้งานได้หรือ session เพิ่งเขียนข้อมูล ใช้แบบ
+//
+//	db, release := pdb.acquireRead(ctx)
+//	defer release(&err)
+//
+// ถ้าคำสั่งบน replica ล้มเหลวเพราะการเชื่อมต่อ replica นั้นจะถูกพักไว้จนกว่า Supervise จะ ping ผ่านอีกครั้ง
+// และคำสั่งนั้นจะถูกลองซ้ำบน primary หนึ่งครั้ง (ดู readPool)
+func (pdb *PostgresDatabase) acquireRead(ctx context.Context) (*readPool, func(*error)) {
+	p := &readPool{pdb: pdb}
+	if r := pdb.pickReplica(ctx); r != nil {
+		pdb.mu.RLock()
+		if !pdb.closed {
+			r.pool.users.Add(1)
+			p.db, p.replica, p.release = r.pool.db, r, r.pool.users.Done
+		}
+		pdb.mu.RUnlock()
+	}
+	if p.db == nil {
+		p.db, p.release = pdb.acquire()
+	}
+	return p, func(errp *error) {
+		defer p.release()
+		if p.replica != nil && isConnError(*errp) {
+			p.replica.markDown(ctx, *errp)
+		}
+	}
+}
+
+// readPool pool ที่ acquireRead ยืมมาให้ ถ้าคำสั่งบน replica ล้มเหลวเพราะการเชื่อมต่อ
+// จะพัก replica นั้น เปลี่ยนไปใช้ primary แล้วลองคำสั่งเดิมซ้ำหนึ่งครั้ง
+// error ระหว่างอ่านแถว (rows.Next) ไม่ถูกลองซ้ำ เพราะผู้เรียกอาจใช้แถวที่อ่านไปแล้ว
+type readPool struct {
+	pdb     *PostgresDatabase
+	db      *sql.DB
+	replica *replica // nil เมื่อใช้ primary
+	release func()
+}
+
+func (p *readPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if p.failover(ctx, err) {
+		return p.db.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (p *readPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := p.db.QueryRowContext(ctx, query, args...)
+	if p.failover(ctx, row.Err()) {
+		return p.db.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
+
+// failover ถ้า err เป็น error การเชื่อมต่อของ replica จะพัก replica นั้นแล้วเปลี่ยนไปใช้ primary
+// คืน true ถ้าผู้เรียกควรลองคำสั่งซ้ำ
+func (p *readPool) failover(ctx context.Context, err error) bool {
+	if p.replica == nil || !isConnError(err) {
+		return false
+	}
+	p.replica.markDown(ctx, err)
+	db, release := p.pdb.acquire()
+	p.release()
+	p.db, p.replica, p.release = db, nil, release
+	return true
+}
+
+// markDown พัก replica ไว้จนกว่า Supervise จะ ping ผ่านอีกครั้ง
+func (r *replica) markDown(ctx context.Context, err error) {
+	if r.healthy.CompareAndSwap(true, false) {
+		slog.WarnContext(ctx, "Database replica unavailable, reading from primary", slog.String("replica", r.host), slog.Any("error", err))
+	}
+}
+
+func (pdb *PostgresDatabase) pickReplica(ctx context.Context) *replica {
+	n := len(pdb.replicas)
+	if n == 0 || pdb.wroteRecently(ctx) {
+		return nil
+	}
+	start := int(pdb.nextReplica.Add(1) % uint64(n))
+	for i := 0; i < n; i++ {
+		if r := pdb.replicas[(start+i)%n]; r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// isConnError error เกิดจากการเชื่อมต่อกับฐานข้อมูล ไม่ใช่จากคำสั่งหรือข้อมูล
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// 08xxx = connection exception, 57P0x = เซิร์ฟเวอร์กำลังปิดหรือยังไม่พร้อมรับการเชื่อมต่อ
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Class() == "08" || strings.HasPrefix(string(pqErr.Code), "57P")
+	}
+	return false
+}
+
+// checkReplicas ping replica ทุกตัวแล้วปรับสถานะว่าใช้งานได้หรือไม่
+func (pdb *PostgresDatabase) checkReplicas(ctx context.Context) {
+	for _, r := range pdb.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, pdb.cfg.PingTimeout)
+		err := r.pool.db.PingContext(pingCtx)
+		cancel()
+
+		switch {
+		case err == nil && !r.healthy.Swap(true):
+			slog.Info("Connected to database replica", slog.String("replica", r.host))
+		case err != nil && r.healthy.Swap(false):
+			slog.Warn("Database replica unavailable, reading from primary", slog.String("replica", r.host), slog.Any("error", err))
+		case err != nil:
+			slog.Debug("Database replica still unavailable", slog.String("replica", r.host), slog.Any("error", err))
+		}
+	}
+}
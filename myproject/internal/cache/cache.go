@@ -0,0 +1,58 @@
+// cache.go
+
+// Package cache ที่เก็บข้อมูลชั่วคราวสำหรับผลลัพธ์ของการอ่านฐานข้อมูล มีแบบ LRU ในหน่วยความจำและแบบ Redis
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Store ที่เก็บข้อมูลชั่วคราวแบบ key/value
+//
+// การล้างข้อมูลทำเป็นกลุ่มด้วย generation ของ namespace: key ของข้อมูลจะมี generation ปัจจุบันของ namespace ต่อท้าย
+// Invalidate เพิ่ม generation ทำให้ key เดิมทั้งหมดของ namespace นั้นไม่ถูกอ่านอีกและหมดอายุไปเอง
+// จึงไม่ต้องไล่ลบ key ทีละตัว (ซึ่งทำไม่ได้กับผลการค้นหาที่ key มีได้ไม่จำกัด)
+type Store interface {
+	// Get คืนค่าและ true ถ้าพบ key ที่ยังไม่หมดอายุ
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Generation คืน generation ปัจจุบันของ namespace
+	Generation(ctx context.Context, namespace string) (int64, error)
+	// Invalidate ล้างข้อมูลทั้งหมดของ namespace
+	Invalidate(ctx context.Context, namespace string) error
+	Close() error
+}
+
+// สำหรับการตั้งค่า Backend
+const (
+	BackendNone   = "none"
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Options การตั้งค่าของ New
+type Options struct {
+	Backend string
+	// MaxEntries จำนวนรายการสูงสุดของ LRU ในหน่วยความจำ
+	MaxEntries int
+	// RedisURL เช่น redis://localhost:6379/0 ใช้เมื่อ Backend เป็น redis
+	RedisURL string
+	// KeyPrefix นำหน้าทุก key ใน Redis เพื่อใช้ Redis ร่วมกับระบบอื่นได้
+	KeyPrefix string
+}
+
+// New สร้าง Store ตาม Backend คืน nil ถ้า Backend เป็น none หรือว่าง (ไม่ใช้ cache)
+func New(opts Options) (Store, error) {
+	switch opts.Backend {
+	case "", BackendNone:
+		return nil, nil
+	case BackendMemory:
+		return NewLRU(opts.MaxEntries), nil
+	case BackendRedis:
+		return NewRedis(opts.RedisURL, opts.KeyPrefix)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q (expected none, memory or redis)", opts.Backend)
+	}
+}
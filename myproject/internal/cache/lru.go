@@ -0,0 +1,106 @@
+// lru.go
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultMaxEntries ใช้เมื่อไม่ได้กำหนด MaxEntries
+const defaultMaxEntries = 10000
+
+// LRU ที่เก็บข้อมูลในหน่วยความจำ เมื่อเต็มจะทิ้งรายการที่ไม่ได้ใช้นานที่สุด
+// ข้อมูลไม่ถูกแบ่งปันระหว่าง instance ถ้ารันหลาย instance ควรใช้ Redis
+type LRU struct {
+	mu          sync.Mutex
+	maxEntries  int
+	order       *list.List // หน้าสุดคือรายการที่ใช้ล่าสุด
+	items       map[string]*list.Element
+	generations map[string]int64
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRU สร้าง LRU ที่เก็บได้ไม่เกิน maxEntries รายการ
+func NewLRU(maxEntries int) *LRU {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	return &LRU{
+		maxEntries:  maxEntries,
+		order:       list.New(),
+		items:       map[string]*list.Element{},
+		generations: map[string]int64{},
+	}
+}
+
+func (l *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		l.remove(el)
+		return nil, false, nil
+	}
+	l.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+func (l *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if el, ok := l.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		l.order.MoveToFront(el)
+		return nil
+	}
+
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for l.order.Len() > l.maxEntries {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+func (l *LRU) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.items, el.Value.(*lruEntry).key)
+}
+
+func (l *LRU) Generation(_ context.Context, namespace string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.generations[namespace], nil
+}
+
+func (l *LRU) Invalidate(_ context.Context, namespace string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.generations[namespace]++
+	return nil
+}
+
+// Len จำนวนรายการที่เก็บอยู่ (รวมรายการที่หมดอายุแล้วแต่ยังไม่ถูกทิ้ง)
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) Close() error {
+	return nil
+}
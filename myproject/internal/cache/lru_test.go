@@ -0,0 +1,93 @@
+// lru_test.go
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// run ใช้งาน LRU ที่เก็บได้ 2 รายการ
+		run      func(l *LRU)
+		wantKeys map[string]bool
+	}{
+		{
+			name: "keeps entries until full",
+			run: func(l *LRU) {
+				_ = l.Set(ctx, "a", []byte("1"), time.Minute)
+				_ = l.Set(ctx, "b", []byte("2"), time.Minute)
+			},
+			wantKeys: map[string]bool{"a": true, "b": true},
+		},
+		{
+			name: "evicts the least recently used",
+			run: func(l *LRU) {
+				_ = l.Set(ctx, "a", []byte("1"), time.Minute)
+				_ = l.Set(ctx, "b", []byte("2"), time.Minute)
+				_, _, _ = l.Get(ctx, "a")
+				_ = l.Set(ctx, "c", []byte("3"), time.Minute)
+			},
+			wantKeys: map[string]bool{"a": true, "b": false, "c": true},
+		},
+		{
+			name: "overwrite does not grow",
+			run: func(l *LRU) {
+				_ = l.Set(ctx, "a", []byte("1"), time.Minute)
+				_ = l.Set(ctx, "b", []byte("2"), time.Minute)
+				_ = l.Set(ctx, "a", []byte("3"), time.Minute)
+			},
+			wantKeys: map[string]bool{"a": true, "b": true},
+		},
+		{
+			name: "expired entries are not returned",
+			run: func(l *LRU) {
+				_ = l.Set(ctx, "a", []byte("1"), -time.Second)
+				_ = l.Set(ctx, "b", []byte("2"), time.Minute)
+			},
+			wantKeys: map[string]bool{"a": false, "b": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLRU(2)
+			tt.run(l)
+			for key, want := range tt.wantKeys {
+				if _, ok, err := l.Get(ctx, key); err != nil || ok != want {
+					t.Errorf("Get(%q) found = %v, err = %v, want found = %v", key, ok, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLRUGenerations(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(0)
+
+	for _, step := range []struct {
+		invalidate string
+		wantStores int64
+		wantOther  int64
+	}{
+		{"", 0, 0},
+		{"stores", 1, 0},
+		{"stores", 2, 0},
+		{"products", 2, 0},
+	} {
+		if step.invalidate != "" {
+			if err := l.Invalidate(ctx, step.invalidate); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stores, _ := l.Generation(ctx, "stores")
+		other, _ := l.Generation(ctx, "other")
+		if stores != step.wantStores || other != step.wantOther {
+			t.Errorf("after invalidating %q: generations = %d, %d, want %d, %d", step.invalidate, stores, other, step.wantStores, step.wantOther)
+		}
+	}
+}
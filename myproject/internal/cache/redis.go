@@ -0,0 +1,67 @@
+// redis.go
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis ที่เก็บข้อมูลใน Redis ใช้ร่วมกันได้ระหว่างหลาย instance การล้างข้อมูลจึงมีผลกับทุก instance
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis เชื่อมต่อ Redis ตาม URL เช่น redis://:password@localhost:6379/0
+// การเชื่อมต่อจริงเกิดขึ้นเมื่อใช้งานครั้งแรก Redis ที่ยังไม่พร้อมจึงไม่ทำให้เซิร์ฟเวอร์เริ่มไม่ได้
+func NewRedis(url, prefix string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	return &Redis{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+func (r *Redis) Generation(ctx context.Context, namespace string) (int64, error) {
+	gen, err := r.client.Get(ctx, r.generationKey(namespace)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return gen, err
+}
+
+func (r *Redis) Invalidate(ctx context.Context, namespace string) error {
+	return r.client.Incr(ctx, r.generationKey(namespace)).Err()
+}
+
+func (r *Redis) generationKey(namespace string) string {
+	return r.prefix + "gen:" + namespace
+}
+
+// Ping ตรวจสอบการเชื่อมต่อกับ Redis ใช้กับ /readyz
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
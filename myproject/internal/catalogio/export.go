@@ -0,0 +1,207 @@
+// export.go
+package catalogio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"myproject/internal/bookstore"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// รูปแบบไฟล์ export เพิ่มเติม (CSV ใช้ FormatCSV ร่วมกับ import)
+const (
+	FormatJSONL    = "jsonl"
+	FormatMerchant = "merchant-xml"
+)
+
+// Writer เขียนสินค้าทีละรายการลงไฟล์ export ต้องเรียก Close เมื่อเขียนครบเพื่อปิดท้ายไฟล์
+type Writer interface {
+	Write(product bookstore.Product) error
+	Close() error
+}
+
+// FeedInfo ข้อมูลของ feed ที่ใช้ในรูปแบบ merchant-xml
+type FeedInfo struct {
+	Title       string
+	Description string
+	// BaseURL ใช้สร้างลิงก์สินค้าและลิงก์รูปที่เป็น path แบบ relative
+	BaseURL string
+	// ProductURL ลิงก์ของหน้าสินค้า ใช้ {id} แทนรหัสสินค้า เช่น https://shop.example.com/p/{id}
+	// ถ้าเป็น path แบบ relative จะต่อท้าย BaseURL ค่าว่างใช้ DefaultProductURL
+	ProductURL string
+	Currency   string
+}
+
+// DefaultProductURL ลิงก์สินค้าเมื่อไม่ได้กำหนด FeedInfo.ProductURL คือสินค้าใน API v2 ของเซิร์ฟเวอร์นี้
+const DefaultProductURL = "/api/v2/products/{id}"
+
+// ContentType คืน Content-Type ของรูปแบบ export คืนค่าว่างถ้าไม่รองรับ
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatMerchant:
+		return "application/rss+xml; charset=utf-8"
+	}
+	return ""
+}
+
+// FileExtension นามสกุลไฟล์สำหรับ Content-Disposition
+func FileExtension(format string) string {
+	if format == FormatMerchant {
+		return "xml"
+	}
+	return format
+}
+
+// NewWriter สร้าง Writer ตามรูปแบบที่กำหนด
+func NewWriter(w io.Writer, format string, feed FeedInfo) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	case FormatMerchant:
+		return newMerchantWriter(w, feed)
+	}
+	return nil, bookstore.NewError(bookstore.ErrValidation, fmt.Sprintf("unsupported export format %q (use csv, jsonl or merchant-xml)", format))
+}
+
+// exportColumns คอลัมน์ของ CSV ใช้ชื่อเดียวกับ import เพื่อให้นำไฟล์ที่ export กลับไป import ได้
+var exportColumns = []string{"id", "product_name", "price", "quantity", "category", "brand", "model", "is_recommended", "image_path", "store_id", "created_at", "updated_at"}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	if err := cw.w.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(p bookstore.Product) error {
+	return cw.w.Write([]string{
+		strconv.Itoa(p.ID),
+		p.ProductName,
+		strconv.FormatFloat(p.Price, 'f', 2, 64),
+		strconv.Itoa(p.Quantity),
+		p.Category,
+		p.Brand,
+		p.Model,
+		strconv.FormatBool(p.IsRecommended),
+		p.ImagePath,
+		strconv.Itoa(p.StoreID),
+		p.CreatedAt.Format(time.RFC3339),
+		p.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (jw *jsonlWriter) Write(p bookstore.Product) error {
+	return jw.enc.Encode(p)
+}
+
+func (jw *jsonlWriter) Close() error {
+	return nil
+}
+
+// merchantItem สินค้า 1 รายการใน feed ของ Google Merchant Center (RSS 2.0 กับ namespace g:)
+type merchantItem struct {
+	XMLName      xml.Name `xml:"item"`
+	ID           string   `xml:"g:id"`
+	Title        string   `xml:"title"`
+	Description  string   `xml:"description"`
+	Link         string   `xml:"link"`
+	ImageLink    string   `xml:"g:image_link"`
+	Price        string   `xml:"g:price"`
+	Availability string   `xml:"g:availability"`
+	Condition    string   `xml:"g:condition"`
+	Brand        string   `xml:"g:brand"`
+	MPN          string   `xml:"g:mpn"`
+	ProductType  string   `xml:"g:product_type,omitempty"`
+}
+
+type merchantWriter struct {
+	w    io.Writer
+	enc  *xml.Encoder
+	feed FeedInfo
+}
+
+func newMerchantWriter(w io.Writer, feed FeedInfo) (*merchantWriter, error) {
+	if feed.Currency == "" {
+		feed.Currency = "THB"
+	}
+	feed.BaseURL = strings.TrimRight(feed.BaseURL, "/")
+	if feed.ProductURL == "" {
+		feed.ProductURL = DefaultProductURL
+	}
+
+	if _, err := io.WriteString(w, xml.Header+`<rss version="2.0" xmlns:g="http://base.google.com/ns/1.0">`+"\n<channel>\n"); err != nil {
+		return nil, err
+	}
+	enc := xml.NewEncoder(w)
+	for _, el := range []struct{ name, value string }{
+		{"title", feed.Title},
+		{"link", feed.BaseURL},
+		{"description", feed.Description},
+	} {
+		if err := enc.EncodeElement(el.value, xml.StartElement{Name: xml.Name{Local: el.name}}); err != nil {
+			return nil, err
+		}
+	}
+	return &merchantWriter{w: w, enc: enc, feed: feed}, nil
+}
+
+func (mw *merchantWriter) Write(p bookstore.Product) error {
+	availability := "in_stock"
+	if p.Quantity <= 0 {
+		availability = "out_of_stock"
+	}
+	return mw.enc.Encode(merchantItem{
+		ID:           strconv.Itoa(p.ID),
+		Title:        p.ProductName,
+		Description:  strings.TrimSpace(fmt.Sprintf("%s %s %s", p.ProductName, p.Brand, p.Model)),
+		Link:         mw.absolute(strings.ReplaceAll(mw.feed.ProductURL, "{id}", strconv.Itoa(p.ID))),
+		ImageLink:    mw.absolute(p.ImagePath),
+		Price:        fmt.Sprintf("%.2f %s", p.Price, mw.feed.Currency),
+		Availability: availability,
+		Condition:    "new",
+		Brand:        p.Brand,
+		MPN:          p.Model,
+		ProductType:  p.Category,
+	})
+}
+
+func (mw *merchantWriter) Close() error {
+	if err := mw.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(mw.w, "\n</channel>\n</rss>\n")
+	return err
+}
+
+// absolute แปลง path แบบ relative ให้เป็น URL เต็ม
+func (mw *merchantWriter) absolute(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return mw.feed.BaseURL + "/" + strings.TrimLeft(path, "/")
+}
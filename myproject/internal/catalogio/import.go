@@ -0,0 +1,231 @@
+// Package catalogio อ่านและเขียนรายการสินค้าในรูปแบบไฟล์ (CSV, XLSX) สำหรับ import/export
+package catalogio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"myproject/internal/bookstore"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// รูปแบบไฟล์ที่รองรับ
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// MaxImportRows จำนวนแถวสินค้าสูงสุดต่อไฟล์ เพื่อไม่ให้ transaction ใหญ่เกินไป
+const MaxImportRows = 5000
+
+// columns คอลัมน์ของ product_info ที่อ่านจากไฟล์ คอลัมน์อื่นจะถูกข้าม (เช่น id จากไฟล์ที่ export ออกไป)
+var columns = []string{"product_name", "price", "quantity", "category", "brand", "model", "is_recommended", "image_path"}
+
+// requiredColumns คอลัมน์ที่ต้องมีในหัวตาราง
+var requiredColumns = []string{"product_name", "price", "quantity", "brand", "model", "image_path"}
+
+// columnAliases ชื่อหัวคอลัมน์อื่นที่ยอมรับ
+var columnAliases = map[string]string{
+	"name":        "product_name",
+	"recommended": "is_recommended",
+	"image":       "image_path",
+}
+
+// DetectFormat เดารูปแบบไฟล์จากนามสกุลหรือ Content-Type คืนค่าว่างถ้าไม่รู้จัก
+func DetectFormat(filename, contentType string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV
+	case ".xlsx":
+		return FormatXLSX
+	}
+	switch strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]) {
+	case "text/csv", "application/csv":
+		return FormatCSV
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return FormatXLSX
+	}
+	return ""
+}
+
+// ReadProducts อ่านสินค้าจากไฟล์ตามรูปแบบที่กำหนด แถวแรกต้องเป็นหัวคอลัมน์
+// ค่าที่แปลงไม่ได้ (เช่น ราคาไม่ใช่ตัวเลข) จะถูกเก็บไว้ใน Errors ของแถวนั้น ไม่ทำให้ทั้งไฟล์ล้มเหลว
+func ReadProducts(r io.Reader, format string) ([]bookstore.ProductImportRow, error) {
+	var (
+		records [][]string
+		err     error
+	)
+	switch format {
+	case FormatCSV:
+		records, err = readCSV(r)
+	case FormatXLSX:
+		records, err = readXLSX(r)
+	default:
+		return nil, bookstore.NewError(bookstore.ErrValidation, fmt.Sprintf("unsupported import format %q (use csv or xlsx)", format))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseRecords(records)
+}
+
+func readCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, bookstore.NewError(bookstore.ErrValidation, fmt.Sprintf("malformed CSV at line %d: %v", parseErr.Line, parseErr.Err))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	return records, nil
+}
+
+// readXLSX อ่านแถวจาก sheet แรกของไฟล์
+func readXLSX(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, bookstore.NewError(bookstore.ErrValidation, "file is not a valid XLSX workbook")
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, bookstore.NewError(bookstore.ErrValidation, "workbook has no sheets")
+	}
+	records, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sheet %q: %w", sheets[0], err)
+	}
+	return records, nil
+}
+
+// parseRecords แปลงแถวของไฟล์เป็นสินค้า หมายเลขแถวนับแบบเดียวกับโปรแกรม spreadsheet (หัวตารางคือแถว 1)
+func parseRecords(records [][]string) ([]bookstore.ProductImportRow, error) {
+	if len(records) == 0 {
+		return nil, bookstore.NewError(bookstore.ErrValidation, "file is empty")
+	}
+
+	index, err := headerIndex(records[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []bookstore.ProductImportRow
+	for i, record := range records[1:] {
+		if blank(record) {
+			continue
+		}
+		if len(rows) == MaxImportRows {
+			return nil, bookstore.NewError(bookstore.ErrValidation, fmt.Sprintf("file has more than %d product rows", MaxImportRows))
+		}
+		rows = append(rows, parseRow(i+2, record, index))
+	}
+	if len(rows) == 0 {
+		return nil, bookstore.NewError(bookstore.ErrValidation, "file has no product rows")
+	}
+	return rows, nil
+}
+
+// headerIndex หาตำแหน่งของแต่ละคอลัมน์จากหัวตาราง
+func headerIndex(header []string) (map[string]int, error) {
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if alias, ok := columnAliases[name]; ok {
+			name = alias
+		}
+		if _, dup := index[name]; dup {
+			return nil, bookstore.NewError(bookstore.ErrValidation, fmt.Sprintf("column %q appears more than once", name))
+		}
+		index[name] = i
+	}
+
+	fields := map[string]string{}
+	for _, name := range requiredColumns {
+		if _, ok := index[name]; !ok {
+			fields[name] = "column is missing"
+		}
+	}
+	if len(fields) > 0 {
+		return nil, bookstore.NewError(bookstore.ErrValidation, "file is missing required columns").
+			WithDetails(map[string]interface{}{"fields": fields, "columns": columns})
+	}
+	return index, nil
+}
+
+func parseRow(line int, record []string, index map[string]int) bookstore.ProductImportRow {
+	value := func(column string) string {
+		i, ok := index[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := bookstore.ProductImportRow{
+		Row: line,
+		Product: bookstore.Product{
+			ProductName: value("product_name"),
+			Category:    value("category"),
+			Brand:       value("brand"),
+			Model:       value("model"),
+			ImagePath:   value("image_path"),
+		},
+		Errors: map[string]string{},
+	}
+
+	if v := value("price"); v == "" {
+		row.Errors["price"] = "is required"
+	} else if price, err := strconv.ParseFloat(strings.ReplaceAll(v, ",", ""), 64); err != nil {
+		row.Errors["price"] = "must be a number"
+	} else {
+		row.Product.Price = price
+	}
+
+	if v := value("quantity"); v == "" {
+		row.Errors["quantity"] = "is required"
+	} else if quantity, err := strconv.Atoi(v); err != nil {
+		row.Errors["quantity"] = "must be a whole number"
+	} else {
+		row.Product.Quantity = quantity
+	}
+
+	if v := value("is_recommended"); v != "" {
+		recommended, ok := parseBool(v)
+		if !ok {
+			row.Errors["is_recommended"] = "must be true or false"
+		}
+		row.Product.IsRecommended = recommended
+	}
+
+	return row
+}
+
+func parseBool(v string) (bool, bool) {
+	switch strings.ToLower(v) {
+	case "1", "t", "true", "y", "yes":
+		return true, true
+	case "0", "f", "false", "n", "no":
+		return false, true
+	}
+	return false, false
+}
+
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
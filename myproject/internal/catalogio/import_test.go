@@ -0,0 +1,183 @@
+// import_test.go
+package catalogio
+
+import (
+	"bytes"
+	"errors"
+	"myproject/internal/bookstore"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename, contentType, want string
+	}{
+		{"products.csv", "", FormatCSV},
+		{"Products.XLSX", "application/octet-stream", FormatXLSX},
+		{"upload", "text/csv; charset=utf-8", FormatCSV},
+		{"upload", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", FormatXLSX},
+		{"products.xls", "application/vnd.ms-excel", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.filename, tt.contentType); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, want %q", tt.filename, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestReadProductsCSV(t *testing.T) {
+	const header = "product_name,price,quantity,category,brand,model,is_recommended,image_path\n"
+
+	tests := []struct {
+		name string
+		in   string
+		want []bookstore.ProductImportRow
+		// wantErr ข้อความที่ต้องอยู่ใน error ของทั้งไฟล์
+		wantErr string
+	}{
+		{
+			name: "valid rows",
+			in:   header + "Strat,\"1,299.50\",3,guitar,Fender,Strat,yes,/img/strat.jpg\nPick,5,100,,Dunlop,Tortex,,/img/pick.jpg\n",
+			want: []bookstore.ProductImportRow{
+				{Row: 2, Product: bookstore.Product{ProductName: "Strat", Price: 1299.5, Quantity: 3, Category: "guitar", Brand: "Fender", Model: "Strat", IsRecommended: true, ImagePath: "/img/strat.jpg"}, Errors: map[string]string{}},
+				{Row: 3, Product: bookstore.Product{ProductName: "Pick", Price: 5, Quantity: 100, Brand: "Dunlop", Model: "Tortex", ImagePath: "/img/pick.jpg"}, Errors: map[string]string{}},
+			},
+		},
+		{
+			name: "BOM, aliases, extra columns and blank rows",
+			in:   "\ufeffid, Name ,price,quantity,brand,model,image,recommended\n7,Strat,10,1,Fender,Strat,/a.jpg,no\n,,,,,,,\n8,Tele,20,2,Fender,Tele,/b.jpg,1\n",
+			want: []bookstore.ProductImportRow{
+				{Row: 2, Product: bookstore.Product{ProductName: "Strat", Price: 10, Quantity: 1, Brand: "Fender", Model: "Strat", ImagePath: "/a.jpg"}, Errors: map[string]string{}},
+				{Row: 4, Product: bookstore.Product{ProductName: "Tele", Price: 20, Quantity: 2, Brand: "Fender", Model: "Tele", IsRecommended: true, ImagePath: "/b.jpg"}, Errors: map[string]string{}},
+			},
+		},
+		{
+			name: "bad values are reported per row",
+			in:   header + "Strat,cheap,1.5,guitar,Fender,Strat,maybe,/a.jpg\nTele,,,guitar,Fender,Tele,,/b.jpg\n",
+			want: []bookstore.ProductImportRow{
+				{Row: 2, Product: bookstore.Product{ProductName: "Strat", Category: "guitar", Brand: "Fender", Model: "Strat", ImagePath: "/a.jpg"},
+					Errors: map[string]string{"price": "must be a number", "quantity": "must be a whole number", "is_recommended": "must be true or false"}},
+				{Row: 3, Product: bookstore.Product{ProductName: "Tele", Category: "guitar", Brand: "Fender", Model: "Tele", ImagePath: "/b.jpg"},
+					Errors: map[string]string{"price": "is required", "quantity": "is required"}},
+			},
+		},
+		{
+			name: "short row",
+			in:   header + "Strat,10,1\n",
+			want: []bookstore.ProductImportRow{
+				{Row: 2, Product: bookstore.Product{ProductName: "Strat", Price: 10, Quantity: 1}, Errors: map[string]string{}},
+			},
+		},
+		{name: "empty file", in: "", wantErr: "file is empty"},
+		{name: "header only", in: header, wantErr: "file has no product rows"},
+		{name: "missing columns", in: "product_name,price\nStrat,10\n", wantErr: "file is missing required columns"},
+		{name: "duplicate column", in: "name,product_name,price,quantity,brand,model,image_path\n", wantErr: `column "product_name" appears more than once`},
+		{name: "malformed CSV", in: header + "\"Strat,10\n", wantErr: "malformed CSV at line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ReadProducts(strings.NewReader(tt.in), FormatCSV)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadProducts() error = %v, want %q", err, tt.wantErr)
+				}
+				if !errors.Is(err, bookstore.ErrValidation) {
+					t.Errorf("ReadProducts() error = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadProducts() error = %v", err)
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("ReadProducts() =\n%+v\nwant\n%+v", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadProductsRowLimit(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("product_name,price,quantity,brand,model,image_path\n")
+	for i := 0; i <= MaxImportRows; i++ {
+		b.WriteString("Pick,5,1,Dunlop,Tortex,/p.jpg\n")
+	}
+	if _, err := ReadProducts(strings.NewReader(b.String()), FormatCSV); err == nil || !strings.Contains(err.Error(), "more than") {
+		t.Errorf("ReadProducts() error = %v, want the row limit", err)
+	}
+}
+
+func TestReadProductsXLSX(t *testing.T) {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	for i, row := range [][]interface{}{
+		{"product_name", "price", "quantity", "brand", "model", "image_path", "is_recommended"},
+		{"Strat", 1299.5, 3, "Fender", "Strat", "/img/strat.jpg", true},
+		{},
+		{"Tele", "n/a", 2, "Fender", "Tele", "/img/tele.jpg", "no"},
+	} {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadProducts(&buf, FormatXLSX)
+	if err != nil {
+		t.Fatalf("ReadProducts() error = %v", err)
+	}
+	want := []bookstore.ProductImportRow{
+		{Row: 2, Product: bookstore.Product{ProductName: "Strat", Price: 1299.5, Quantity: 3, Brand: "Fender", Model: "Strat", IsRecommended: true, ImagePath: "/img/strat.jpg"}, Errors: map[string]string{}},
+		{Row: 4, Product: bookstore.Product{ProductName: "Tele", Quantity: 2, Brand: "Fender", Model: "Tele", ImagePath: "/img/tele.jpg"}, Errors: map[string]string{"price": "must be a number"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadProducts() =\n%+v\nwant\n%+v", rows, want)
+	}
+
+	if _, err := ReadProducts(strings.NewReader("not a workbook"), FormatXLSX); err == nil || !strings.Contains(err.Error(), "not a valid XLSX") {
+		t.Errorf("ReadProducts(garbage) error = %v, want invalid workbook", err)
+	}
+	if _, err := ReadProducts(strings.NewReader(""), "ods"); err == nil || !strings.Contains(err.Error(), "unsupported import format") {
+		t.Errorf("ReadProducts(ods) error = %v, want unsupported format", err)
+	}
+}
+
+// ไฟล์ที่ export เป็น CSV ต้องนำกลับมา import ได้โดยได้สินค้าเดิม
+func TestExportedCSVCanBeImported(t *testing.T) {
+	product := bookstore.Product{ID: 7, ProductName: "Strat, sunburst", Price: 1299.5, Quantity: 3, Category: "guitar",
+		Brand: "Fender", Model: "Strat", IsRecommended: true, ImagePath: "/img/strat.jpg", StoreID: 1}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV, FeedInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(product); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadProducts(&buf, FormatCSV)
+	if err != nil {
+		t.Fatalf("ReadProducts() error = %v", err)
+	}
+	// id, store_id และเวลาไม่ถูกอ่านกลับ ร้านมาจาก URL ของการ import
+	want := product
+	want.ID, want.StoreID = 0, 0
+	if len(rows) != 1 || len(rows[0].Errors) != 0 || rows[0].Product != want {
+		t.Errorf("ReadProducts() = %+v, want one row with %+v", rows, want)
+	}
+}
@@ -3,55 +3,675 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"myproject/internal/ratelimit"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	AppPort          string
+	// Profile ชื่อสภาพแวดล้อม (เช่น development, production) ใช้เลือกไฟล์ config.<profile>.yaml เพิ่มเติม
+	Profile string
+
+	AppPort string
+	// RequestTimeout เวลาสูงสุดของแต่ละ request
+	RequestTimeout time.Duration
+	// TrustedProxies IP หรือ CIDR ของ proxy/load balancer ที่เชื่อ X-Forwarded-For ได้ ใช้หา IP จริงของ client
+	TrustedProxies []string
+	// AuthProxies IP หรือ CIDR ของระบบยืนยันตัวตนด้านหน้าที่ส่ง X-User-ID ของผู้ใช้ที่ล็อกอินแล้วมาได้
+	// X-User-ID จากที่อื่นจะไม่ถูกเชื่อ
+	AuthProxies []string
+	// SecureCookies ส่ง cookie ผ่าน HTTPS เท่านั้น ปิดเฉพาะตอนทดสอบบนเครื่องผ่าน http
+	SecureCookies bool
+	// PublicBaseURL URL ที่ client ภายนอกใช้เรียกเซิร์ฟเวอร์ ใช้สร้างลิงก์ใน feed สินค้า ค่าว่างใช้ host ของ request
+	PublicBaseURL string
+	// ProductURL ลิงก์หน้าสินค้าใน feed ใช้ {id} แทนรหัสสินค้า ค่าว่างใช้สินค้าใน API v2
+	ProductURL string
+	// ShutdownDelay เวลาที่ /readyz ตอบ 503 ก่อนเริ่มปิดเซิร์ฟเวอร์ ให้ load balancer มีเวลาเห็นและหยุดส่ง request มา
+	ShutdownDelay time.Duration
+	// ShutdownTimeout เวลาที่รอ request ที่ค้างอยู่ให้เสร็จเมื่อได้รับสัญญาณให้ปิดเซิร์ฟเวอร์
+	ShutdownTimeout time.Duration
+	// V1DeprecatedAt และ V1Sunset วันที่ประกาศเลิกใช้และวันที่จะปิด API v1 ส่งให้ client ใน header Deprecation และ Sunset
+	// ค่าว่างหมายถึงยังไม่ได้กำหนด
+	V1DeprecatedAt time.Time
+	V1Sunset       time.Time
+
 	DatabaseHost     string
 	DatabasePort     int
 	DatabaseUser     string
 	DatabasePassword string
 	DatabaseName     string
 	DatabaseSSLMode  string
+	// AutoMigrate รัน migration ที่ยังไม่ได้รันตอนเริ่มเซิร์ฟเวอร์
+	AutoMigrate bool
+
+	// การตั้งค่า connection pool
+	DatabaseMaxOpenConns    int
+	DatabaseMaxIdleConns    int
+	DatabaseConnMaxLifetime time.Duration
+	DatabaseConnMaxIdleTime time.Duration
+	// DatabasePingTimeout เวลาสูงสุดของการตรวจสอบการเชื่อมต่อ
+	DatabasePingTimeout time.Duration
+	// DatabaseHealthInterval รอบเวลาตรวจสอบการเชื่อมต่อฐานข้อมูลเมื่อทุกอย่างปกติ
+	DatabaseHealthInterval time.Duration
+	// DatabaseReconnectMinBackoff และ DatabaseReconnectMaxBackoff ช่วงเวลารอระหว่างการลองเชื่อมต่อใหม่
+	DatabaseReconnectMinBackoff time.Duration
+	DatabaseReconnectMaxBackoff time.Duration
+	// DatabaseReplicas connection string ของ read replica ที่ใช้กับคำสั่งที่อ่านอย่างเดียว (ไม่บังคับ)
+	DatabaseReplicas []string
+	// DatabaseReplicaStickiness หลังจากผู้ใช้เขียนข้อมูล การอ่านของผู้ใช้คนนั้นจะไปที่ primary นานเท่านี้
+	DatabaseReplicaStickiness time.Duration
+
+	// MetricsAddr host:port ของ listener ภายในที่เปิด /metrics ให้ Prometheus ดึง (ค่าว่าง = ปิด)
+	MetricsAddr string
+
+	// LogLevel ระดับ log ต่ำสุดที่บันทึก (debug, info, warn, error)
+	LogLevel string
+	// LogFormat รูปแบบ log (json หรือ text)
+	LogFormat string
+
+	// TracingExporter ปลายทางของ trace (none, stdout หรือ otlp)
+	TracingExporter string
+	// TracingOTLPEndpoint host:port ของ OpenTelemetry collector (OTLP/HTTP)
+	TracingOTLPEndpoint string
+	// TracingOTLPInsecure ส่ง trace ไปยัง collector โดยไม่ใช้ TLS
+	TracingOTLPInsecure bool
+	// TracingSampleRatio สัดส่วนของ trace ที่เก็บ (0 ถึง 1)
+	TracingSampleRatio float64
+
+	// HealthBlobStoreURL URL ที่ /readyz ใช้ตรวจสอบที่เก็บไฟล์รูปภาพ ถ้าว่างจะไม่ตรวจสอบ
+	HealthBlobStoreURL string
+	// HealthPaymentURL URL ที่ /readyz ใช้ตรวจสอบผู้ให้บริการชำระเงิน ถ้าว่างจะไม่ตรวจสอบ
+	HealthPaymentURL string
+
+	// CartTTL ตะกร้าที่ไม่มีความเคลื่อนไหวนานกว่านี้จะหมดอายุ
+	CartTTL time.Duration
+	// CartExpiryInterval รอบเวลาที่งานเบื้องหลังตรวจหาตะกร้าที่หมดอายุ (0 = ปิด)
+	CartExpiryInterval time.Duration
+
+	// CacheBackend ที่เก็บ cache ของข้อมูลร้านและสินค้า (none, memory หรือ redis)
+	CacheBackend string
+	// CacheMaxEntries จำนวนรายการสูงสุดของ cache ในหน่วยความจำ
+	CacheMaxEntries int
+	// CacheRedisURL เช่น redis://localhost:6379/0 ใช้เมื่อ CacheBackend เป็น redis
+	CacheRedisURL string
+	// CacheKeyPrefix นำหน้าทุก key ใน Redis
+	CacheKeyPrefix string
+	// CacheTTLs อายุของ cache ตามชื่อ method ของฐานข้อมูล (0 = ไม่เก็บ)
+	CacheTTLs map[string]time.Duration
+
+	// RateLimitEnabled เปิดการจำกัดจำนวน request
+	RateLimitEnabled bool
+	// RateLimitBackend ที่เก็บสถานะ (memory หรือ redis)
+	RateLimitBackend string
+	// RateLimitRedisURL เช่น redis://localhost:6379/0 ใช้เมื่อ RateLimitBackend เป็น redis
+	RateLimitRedisURL string
+	// RateLimitKeyPrefix นำหน้าทุก key ใน Redis
+	RateLimitKeyPrefix string
+	// RateLimits อัตราที่อนุญาตของแต่ละกลุ่มเส้นทาง
+	RateLimits map[string]ratelimit.Limit
 }
 
-func LoadConfig() (Config, error) {
-	// viper.SetEnvPrefix("POSTGRES")
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+// ErrHelp ผู้ใช้ขอดูวิธีใช้ (-h หรือ --help) วิธีใช้ถูกพิมพ์ออกไปแล้ว
+var ErrHelp = pflag.ErrHelp
+
+// defaults ค่าเริ่มต้นของทุก key ที่รู้จัก key ในไฟล์ config ที่ไม่อยู่ในนี้ถือว่าผิด
+var defaults = map[string]interface{}{
+	"app.profile":          "",
+	"app.port":             "8080",
+	"app.request_timeout":  "5s",
+	"app.trusted_proxies":  []string{"127.0.0.1/8", "::1/128"},
+	"app.auth_proxies":     []string{"127.0.0.1/8", "::1/128"},
+	"app.secure_cookies":   true,
+	"app.public_base_url":  "",
+	"app.product_url":      "",
+	"app.shutdown_delay":   "5s",
+	"app.shutdown_timeout": "20s",
+	"app.v1_deprecated_at": "",
+	"app.v1_sunset":        "",
+
+	"postgres.host":                  "localhost",
+	"postgres.port":                  5432,
+	"postgres.user":                  "postgres",
+	"postgres.password":              "",
+	"postgres.password_file":         "",
+	"postgres.dbname":                "bookstore",
+	"postgres.sslmode":               "disable",
+	"postgres.auto_migrate":          false,
+	"postgres.max_open_conns":        25,
+	"postgres.max_idle_conns":        10,
+	"postgres.conn_max_lifetime":     "5m",
+	"postgres.conn_max_idle_time":    "0s",
+	"postgres.ping_timeout":          "5s",
+	"postgres.health_interval":       "10s",
+	"postgres.reconnect_min_backoff": "1s",
+	"postgres.reconnect_max_backoff": "30s",
+	"postgres.replicas":              []string{},
+	"postgres.replica_stickiness":    "5s",
+
+	"metrics.listen_addr": "127.0.0.1:9090",
+
+	"log.level":  "info",
+	"log.format": "json",
+
+	"tracing.exporter":      "none",
+	"tracing.otlp_endpoint": "localhost:4318",
+	"tracing.otlp_insecure": true,
+	"tracing.sample_ratio":  1.0,
+
+	"health.blob_store_url": "",
+	"health.payment_url":    "",
+
+	"cart.ttl":             "24h",
+	"cart.expiry_interval": "10m",
 
-	// Set default values
-	viper.SetDefault("POSTGRES.HOST", "localhost")
-	viper.SetDefault("POSTGRES.PORT", 5432)
-	viper.SetDefault("POSTGRES.USER", "postgres")
-	viper.SetDefault("POSTGRES.PASSWORD", "")
-	viper.SetDefault("POSTGRES.DBNAME", "bookstore")
-	viper.SetDefault("POSTGRES.SSLMODE", "disable")
+	"cache.backend":        "memory",
+	"cache.max_entries":    10000,
+	"cache.redis_url":      "",
+	"cache.redis_url_file": "",
+	"cache.key_prefix":     "musicstore:",
 
-	// Set config values
+	"cache.ttl.get_all_store_info":                 "10m",
+	"cache.ttl.get_store_info_by_id":               "10m",
+	"cache.ttl.get_product":                        "1m",
+	"cache.ttl.get_products_by_store":              "30s",
+	"cache.ttl.get_new_products_by_store":          "30s",
+	"cache.ttl.get_all_products_by_store":          "30s",
+	"cache.ttl.get_products_by_category_and_store": "30s",
+	"cache.ttl.get_all_products_by_category":       "30s",
+	"cache.ttl.search_products":                    "30s",
+	"cache.ttl.search_products_by_store":           "30s",
+
+	"ratelimit.enabled":        true,
+	"ratelimit.backend":        "memory",
+	"ratelimit.redis_url":      "",
+	"ratelimit.redis_url_file": "",
+	"ratelimit.key_prefix":     "musicstore:ratelimit:",
+
+	"ratelimit.limits.catalog":  "300/m",
+	"ratelimit.limits.search":   "60/m",
+	"ratelimit.limits.cart":     "60/m",
+	"ratelimit.limits.checkout": "10/m",
+	"ratelimit.limits.admin":    "30/m",
+	"ratelimit.limits.auth":     "600/m",
+}
+
+// rateLimitGroups กลุ่มเส้นทางที่ตั้งค่า ratelimit.limits.<กลุ่ม> ได้
+var rateLimitGroups = []string{"catalog", "search", "cart", "checkout", "admin", "auth"}
+
+// cacheMethods ชื่อ method ของฐานข้อมูลที่ตรงกับ key cache.ttl.<name>
+var cacheMethods = map[string]string{
+	"get_all_store_info":                 "GetAllStoreInfo",
+	"get_store_info_by_id":               "GetStoreInfoByID",
+	"get_product":                        "GetProduct",
+	"get_products_by_store":              "GetProductsByStore",
+	"get_new_products_by_store":          "GetNewProductsByStore",
+	"get_all_products_by_store":          "GetAllProductsByStore",
+	"get_products_by_category_and_store": "GetProductsByCategoryAndStore",
+	"get_all_products_by_category":       "GetALLProductsByCategory",
+	"search_products":                    "SearchProducts",
+	"search_products_by_store":           "SearchProductsByStore",
+}
+
+// secretKeys ค่าที่อ่านจากไฟล์ได้ผ่าน <KEY>_FILE (เช่น POSTGRES_PASSWORD_FILE=/run/secrets/db_password)
+// เพื่อไม่ต้องใส่รหัสผ่านไว้ใน env โดยตรง
+var secretKeys = []string{"postgres.password", "cache.redis_url", "ratelimit.redis_url"}
+
+// configDirs ที่ค้นหาไฟล์ config.yaml (หรือ .yml, .toml, .json) เมื่อไม่ได้ระบุไฟล์
+var configDirs = []string{".", "./config", "/etc/musicstore"}
+
+// LoadConfig อ่านการตั้งค่าเรียงตามลำดับ ค่าที่มาทีหลังจะทับค่าก่อนหน้า:
+// ค่าเริ่มต้น, ไฟล์ config, ไฟล์ของ profile (config.<profile>.yaml), ตัวแปร env (เช่น POSTGRES_HOST) และ flag
+//
+// args คือ argument ของโปรแกรมโดยไม่รวมชื่อโปรแกรม flag ต้องอยู่ก่อนคำสั่งย่อย
+// คืน argument ที่เหลือ (คำสั่งย่อยและ argument ของคำสั่งนั้น) และรวม error ของทุกค่าที่ไม่ถูกต้องไว้ใน error เดียว
+func LoadConfig(args []string) (Config, []string, error) {
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	flags := newFlagSet(v)
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	configFile, _ := flags.GetString("config")
+	if configFile == "" {
+		configFile = os.Getenv("APP_CONFIG")
+	}
+	if err := readConfigFiles(v, configFile); err != nil {
+		return Config{}, nil, err
+	}
+	if err := checkUnknownKeys(v); err != nil {
+		return Config{}, nil, err
+	}
+	if err := readSecretFiles(v); err != nil {
+		return Config{}, nil, err
+	}
+
+	r := &reader{v: v}
 	config := Config{
-		AppPort:          viper.GetString("APP.PORT"),
-		DatabaseHost:     viper.GetString("POSTGRES.HOST"),
-		DatabasePort:     viper.GetInt("POSTGRES.PORT"),
-		DatabaseUser:     viper.GetString("POSTGRES.USER"),
-		DatabasePassword: viper.GetString("POSTGRES.PASSWORD"),
-		DatabaseName:     viper.GetString("POSTGRES.DBNAME"),
-		DatabaseSSLMode:  viper.GetString("POSTGRES.SSLMODE"),
+		Profile: r.str("app.profile"),
+
+		AppPort:         r.str("app.port"),
+		RequestTimeout:  r.duration("app.request_timeout"),
+		TrustedProxies:  r.list("app.trusted_proxies"),
+		AuthProxies:     r.list("app.auth_proxies"),
+		SecureCookies:   r.bool("app.secure_cookies"),
+		PublicBaseURL:   strings.TrimRight(r.str("app.public_base_url"), "/"),
+		ProductURL:      r.str("app.product_url"),
+		ShutdownDelay:   r.duration("app.shutdown_delay"),
+		ShutdownTimeout: r.duration("app.shutdown_timeout"),
+		V1DeprecatedAt:  r.date("app.v1_deprecated_at"),
+		V1Sunset:        r.date("app.v1_sunset"),
+
+		DatabaseHost:     r.str("postgres.host"),
+		DatabasePort:     r.int("postgres.port"),
+		DatabaseUser:     r.str("postgres.user"),
+		DatabasePassword: r.str("postgres.password"),
+		DatabaseName:     r.str("postgres.dbname"),
+		DatabaseSSLMode:  r.str("postgres.sslmode"),
+		AutoMigrate:      r.bool("postgres.auto_migrate"),
+
+		DatabaseMaxOpenConns:        r.int("postgres.max_open_conns"),
+		DatabaseMaxIdleConns:        r.int("postgres.max_idle_conns"),
+		DatabaseConnMaxLifetime:     r.duration("postgres.conn_max_lifetime"),
+		DatabaseConnMaxIdleTime:     r.duration("postgres.conn_max_idle_time"),
+		DatabasePingTimeout:         r.duration("postgres.ping_timeout"),
+		DatabaseHealthInterval:      r.duration("postgres.health_interval"),
+		DatabaseReconnectMinBackoff: r.duration("postgres.reconnect_min_backoff"),
+		DatabaseReconnectMaxBackoff: r.duration("postgres.reconnect_max_backoff"),
+		DatabaseReplicas:            r.list("postgres.replicas"),
+		DatabaseReplicaStickiness:   r.duration("postgres.replica_stickiness"),
+
+		MetricsAddr: r.str("metrics.listen_addr"),
+
+		LogLevel:  strings.ToLower(r.str("log.level")),
+		LogFormat: strings.ToLower(r.str("log.format")),
+
+		TracingExporter:     r.str("tracing.exporter"),
+		TracingOTLPEndpoint: r.str("tracing.otlp_endpoint"),
+		TracingOTLPInsecure: r.bool("tracing.otlp_insecure"),
+		TracingSampleRatio:  r.float("tracing.sample_ratio"),
+
+		HealthBlobStoreURL: r.str("health.blob_store_url"),
+		HealthPaymentURL:   r.str("health.payment_url"),
+
+		CartTTL:            r.duration("cart.ttl"),
+		CartExpiryInterval: r.duration("cart.expiry_interval"),
+
+		CacheBackend:    strings.ToLower(r.str("cache.backend")),
+		CacheMaxEntries: r.int("cache.max_entries"),
+		CacheRedisURL:   r.str("cache.redis_url"),
+		CacheKeyPrefix:  r.str("cache.key_prefix"),
+		CacheTTLs:       map[string]time.Duration{},
+	}
+	for name, method := range cacheMethods {
+		config.CacheTTLs[method] = r.duration("cache.ttl." + name)
+	}
+
+	config.RateLimitEnabled = r.bool("ratelimit.enabled")
+	config.RateLimitBackend = strings.ToLower(r.str("ratelimit.backend"))
+	config.RateLimitRedisURL = r.str("ratelimit.redis_url")
+	config.RateLimitKeyPrefix = r.str("ratelimit.key_prefix")
+	config.RateLimits = map[string]ratelimit.Limit{}
+	for _, group := range rateLimitGroups {
+		config.RateLimits[group] = r.limit("ratelimit.limits." + group)
+	}
+
+	// ค่าที่แปลงชนิดไม่ได้ถูกรายงานไปแล้ว ไม่ต้องรายงานซ้ำจากการตรวจสอบช่วงค่า
+	errs := r.errs
+	for _, err := range config.validate() {
+		key, _, _ := strings.Cut(err.Error(), ":")
+		if !r.failed[key] {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return config, nil, fmt.Errorf("invalid config:\n%w", errors.Join(errs...))
+	}
+	return config, flags.Args(), nil
+}
+
+// newFlagSet สร้าง flag ของโปรแกรม flag ที่ผู้ใช้ระบุจะทับค่าจากไฟล์และ env
+// การ parse หยุดที่ argument แรกที่ไม่ใช่ flag ซึ่งคือคำสั่งย่อย
+// CommandsUsage คำสั่งย่อยที่แสดงในวิธีใช้ของ flag โปรแกรมอื่นที่ใช้ LoadConfig (เช่น musicstore) กำหนดใหม่ก่อนเรียกได้
+var CommandsUsage = "[serve | migrate | seed | apikey] [args]"
+
+func newFlagSet(v *viper.Viper) *pflag.FlagSet {
+	name := filepath.Base(os.Args[0])
+	flags := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	flags.SetInterspersed(false)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] %s\n\nflags:\n%s", name, CommandsUsage, flags.FlagUsages())
+	}
+
+	flags.String("config", "", "config file (YAML, TOML or JSON), default: config.* in ., ./config or /etc/musicstore; env APP_CONFIG")
+	for _, f := range []struct{ name, key, usage string }{
+		{"profile", "app.profile", "environment profile, loads config.<profile>.* on top of the config file"},
+		{"port", "app.port", "HTTP port"},
+		{"log-level", "log.level", "log level: debug, info, warn or error"},
+		{"log-format", "log.format", "log format: json or text"},
+		{"db-host", "postgres.host", "database host"},
+		{"db-port", "postgres.port", "database port"},
+		{"db-user", "postgres.user", "database user"},
+		{"db-name", "postgres.dbname", "database name"},
+		{"db-sslmode", "postgres.sslmode", "database sslmode"},
+	} {
+		flags.String(f.name, "", f.usage)
+		_ = v.BindPFlag(f.key, flags.Lookup(f.name))
+	}
+	flags.Bool("auto-migrate", false, "apply pending migrations on start")
+	_ = v.BindPFlag("postgres.auto_migrate", flags.Lookup("auto-migrate"))
+	return flags
+}
+
+// readConfigFiles อ่านไฟล์ config หลักและไฟล์ของ profile ถ้ามี
+// ไม่มีไฟล์ config เลยได้ แต่ถ้าระบุไฟล์ไว้แล้วไม่พบถือว่าผิด
+func readConfigFiles(v *viper.Viper, configFile string) error {
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+	} else {
+		v.SetConfigName("config")
+		for _, dir := range configDirs {
+			v.AddConfigPath(dir)
+		}
+	}
+
+	var notFound viper.ConfigFileNotFoundError
+	if err := v.ReadInConfig(); err != nil && !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	profile := v.GetString("app.profile")
+	if profile == "" {
+		return nil
+	}
+
+	// ไฟล์ของ profile อยู่ที่เดียวกับไฟล์หลัก เช่น config.yaml -> config.production.yaml
+	base := "config"
+	dirs := configDirs
+	if used := v.ConfigFileUsed(); used != "" {
+		base = strings.TrimSuffix(filepath.Base(used), filepath.Ext(used))
+		dirs = []string{filepath.Dir(used)}
 	}
+	for _, dir := range dirs {
+		for _, ext := range []string{"yaml", "yml", "toml", "json"} {
+			path := filepath.Join(dir, base+"."+profile+"."+ext)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			v.SetConfigFile(path)
+			if err := v.MergeInConfig(); err != nil {
+				return fmt.Errorf("failed to read profile config %s: %w", path, err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkUnknownKeys ตรวจหา key ในไฟล์ config ที่ไม่รู้จัก ซึ่งมักเกิดจากพิมพ์ชื่อผิด
+func checkUnknownKeys(v *viper.Viper) error {
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		if _, ok := defaults[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown config keys: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// readSecretFiles อ่านค่าลับจากไฟล์ที่ระบุใน <key>_file (เช่น Docker secrets)
+func readSecretFiles(v *viper.Viper) error {
+	for _, key := range secretKeys {
+		path := v.GetString(key + "_file")
+		if path == "" {
+			continue
+		}
+		if v.GetString(key) != "" {
+			return fmt.Errorf("%s and %s_file are both set, use only one", key, key)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s_file: %w", key, err)
+		}
+		v.Set(key, strings.TrimRight(string(content), "\r\n"))
+	}
+	return nil
+}
 
-	return config, nil
+// reader อ่านค่าจาก viper พร้อมเก็บ error ของค่าที่แปลงชนิดไม่ได้ (viper.GetInt จะคืน 0 เฉยๆ)
+type reader struct {
+	v      *viper.Viper
+	errs   []error
+	failed map[string]bool
 }
 
+func (r *reader) fail(key, format string) {
+	r.errs = append(r.errs, fmt.Errorf("%s: "+format+" (got %q)", key, r.v.GetString(key)))
+	if r.failed == nil {
+		r.failed = map[string]bool{}
+	}
+	r.failed[key] = true
+}
+
+func (r *reader) str(key string) string {
+	return strings.TrimSpace(r.v.GetString(key))
+}
+
+// list อ่านรายการจากไฟล์ config หรือจาก env ที่คั่นด้วยจุลภาค (เช่น POSTGRES_REPLICAS=dsn1,dsn2)
+func (r *reader) list(key string) []string {
+	raw := r.v.Get(key)
+	if s, ok := raw.(string); ok {
+		raw = strings.Split(s, ",")
+	}
+	items, err := cast.ToStringSliceE(raw)
+	if err != nil {
+		r.fail(key, "must be a list")
+		return nil
+	}
+	var list []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (r *reader) limit(key string) ratelimit.Limit {
+	limit, err := ratelimit.ParseLimit(r.v.GetString(key))
+	if err != nil {
+		r.fail(key, "must be <count>/<period> such as 60/m, or off")
+	}
+	return limit
+}
+
+func (r *reader) int(key string) int {
+	n, err := cast.ToIntE(r.v.Get(key))
+	if err != nil {
+		r.fail(key, "must be a whole number")
+	}
+	return n
+}
+
+func (r *reader) bool(key string) bool {
+	b, err := cast.ToBoolE(r.v.Get(key))
+	if err != nil {
+		r.fail(key, "must be true or false")
+	}
+	return b
+}
+
+func (r *reader) float(key string) float64 {
+	f, err := cast.ToFloat64E(r.v.Get(key))
+	if err != nil {
+		r.fail(key, "must be a number")
+	}
+	return f
+}
+
+func (r *reader) duration(key string) time.Duration {
+	d, err := cast.ToDurationE(r.v.Get(key))
+	if err != nil {
+		r.fail(key, "must be a duration such as 30s or 5m")
+	}
+	return d
+}
+
+// date อ่านวันที่แบบ 2006-01-02 หรือ RFC3339 คืนเวลาศูนย์ถ้าไม่ได้ตั้งค่า
+func (r *reader) date(key string) time.Time {
+	raw := r.v.Get(key)
+	if t, ok := raw.(time.Time); ok {
+		return t
+	}
+	s := strings.TrimSpace(cast.ToString(raw))
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	r.fail(key, "must be a date such as 2026-12-31 or an RFC3339 timestamp")
+	return time.Time{}
+}
+
+// validate ตรวจสอบค่าที่อ่านได้ทั้งหมด คืน error ของทุกค่าที่ผิด
+func (c Config) validate() []error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+	oneOf := func(value, key string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		check(false, key, "must be one of %s (got %q)", strings.Join(allowed, ", "), value)
+	}
+
+	port, err := cast.ToIntE(c.AppPort)
+	check(err == nil && port > 0 && port <= 65535, "app.port", "must be a port number between 1 and 65535 (got %q)", c.AppPort)
+	check(c.RequestTimeout > 0, "app.request_timeout", "must be greater than 0")
+	check(c.ShutdownDelay >= 0, "app.shutdown_delay", "must not be negative (0 = stop accepting requests immediately)")
+	check(c.ShutdownTimeout > 0, "app.shutdown_timeout", "must be greater than 0")
+	check(c.V1Sunset.IsZero() || c.V1DeprecatedAt.IsZero() || c.V1Sunset.After(c.V1DeprecatedAt),
+		"app.v1_sunset", "must be after app.v1_deprecated_at")
+	if c.PublicBaseURL != "" {
+		u, err := url.Parse(c.PublicBaseURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "app.public_base_url", "must be an http or https URL (got %q)", c.PublicBaseURL)
+	}
+	check(c.ProductURL == "" || strings.Contains(c.ProductURL, "{id}"), "app.product_url", "must contain {id} (got %q)", c.ProductURL)
+
+	check(c.DatabaseHost != "", "postgres.host", "is required")
+	check(c.DatabasePort > 0 && c.DatabasePort <= 65535, "postgres.port", "must be between 1 and 65535 (got %d)", c.DatabasePort)
+	check(c.DatabaseUser != "", "postgres.user", "is required")
+	check(c.DatabaseName != "", "postgres.dbname", "is required")
+	oneOf(c.DatabaseSSLMode, "postgres.sslmode", "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	if c.Profile == "production" {
+		check(c.DatabasePassword != "", "postgres.password", "is required in the production profile (set POSTGRES_PASSWORD_FILE)")
+	}
+
+	check(c.DatabaseMaxOpenConns >= 0, "postgres.max_open_conns", "must not be negative (0 = unlimited)")
+	check(c.DatabaseMaxIdleConns >= 0, "postgres.max_idle_conns", "must not be negative")
+	check(c.DatabaseMaxOpenConns == 0 || c.DatabaseMaxIdleConns <= c.DatabaseMaxOpenConns,
+		"postgres.max_idle_conns", "must not exceed postgres.max_open_conns (%d > %d)", c.DatabaseMaxIdleConns, c.DatabaseMaxOpenConns)
+	check(c.DatabaseConnMaxLifetime >= 0, "postgres.conn_max_lifetime", "must not be negative")
+	check(c.DatabaseConnMaxIdleTime >= 0, "postgres.conn_max_idle_time", "must not be negative")
+	check(c.DatabasePingTimeout > 0, "postgres.ping_timeout", "must be greater than 0")
+	check(c.DatabaseHealthInterval > 0, "postgres.health_interval", "must be greater than 0")
+	check(c.DatabaseReconnectMinBackoff > 0, "postgres.reconnect_min_backoff", "must be greater than 0")
+	check(c.DatabaseReconnectMaxBackoff >= c.DatabaseReconnectMinBackoff, "postgres.reconnect_max_backoff", "must not be less than postgres.reconnect_min_backoff")
+	check(c.DatabaseReplicaStickiness > 0, "postgres.replica_stickiness", "must be greater than 0")
+
+	if c.MetricsAddr != "" {
+		_, metricsPort, err := net.SplitHostPort(c.MetricsAddr)
+		check(err == nil && metricsPort != "" && metricsPort != c.AppPort, "metrics.listen_addr",
+			"must be host:port on a port other than app.port, such as 127.0.0.1:9090 (got %q)", c.MetricsAddr)
+	}
+
+	oneOf(c.LogLevel, "log.level", "debug", "info", "warn", "error")
+	oneOf(c.LogFormat, "log.format", "json", "text")
+
+	oneOf(c.TracingExporter, "tracing.exporter", "none", "stdout", "otlp")
+	check(c.TracingExporter != "otlp" || c.TracingOTLPEndpoint != "", "tracing.otlp_endpoint", "is required when tracing.exporter is otlp")
+	check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1 (got %g)", c.TracingSampleRatio)
+
+	for key, value := range map[string]string{"health.blob_store_url": c.HealthBlobStoreURL, "health.payment_url": c.HealthPaymentURL} {
+		if value == "" {
+			continue
+		}
+		u, err := url.Parse(value)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", key, "must be an http or https URL (got %q)", value)
+	}
+
+	check(c.CartTTL > 0, "cart.ttl", "must be greater than 0")
+	check(c.CartExpiryInterval >= 0, "cart.expiry_interval", "must not be negative (0 disables cart expiry)")
+
+	oneOf(c.CacheBackend, "cache.backend", "none", "memory", "redis")
+	check(c.CacheMaxEntries > 0, "cache.max_entries", "must be greater than 0")
+	if c.CacheBackend == "redis" {
+		u, err := url.Parse(c.CacheRedisURL)
+		check(err == nil && (u.Scheme == "redis" || u.Scheme == "rediss") && u.Host != "", "cache.redis_url", "must be a redis:// or rediss:// URL when cache.backend is redis")
+	}
+	for name, method := range cacheMethods {
+		check(c.CacheTTLs[method] >= 0, "cache.ttl."+name, "must not be negative (0 disables caching)")
+	}
+
+	for key, proxies := range map[string][]string{"app.trusted_proxies": c.TrustedProxies, "app.auth_proxies": c.AuthProxies} {
+		for _, proxy := range proxies {
+			_, _, cidrErr := net.ParseCIDR(proxy)
+			check(cidrErr == nil || net.ParseIP(proxy) != nil, key, "must be IP addresses or CIDR ranges (got %q)", proxy)
+		}
+	}
+	oneOf(c.RateLimitBackend, "ratelimit.backend", "memory", "redis")
+	if c.RateLimitEnabled && c.RateLimitBackend == "redis" {
+		u, err := url.Parse(c.RateLimitRedisURL)
+		check(err == nil && (u.Scheme == "redis" || u.Scheme == "rediss") && u.Host != "", "ratelimit.redis_url", "must be a redis:// or rediss:// URL when ratelimit.backend is redis")
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// GetConnectionString สร้าง connection string แบบ key=value ของ libpq
+// ค่าที่มีช่องว่าง ', \ หรือเป็นค่าว่างจะถูกครอบด้วย ' และ escape ตามรูปแบบของ libpq
 func (c *Config) GetConnectionString() string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		c.DatabaseHost,
+		quoteConnValue(c.DatabaseHost),
 		c.DatabasePort,
-		c.DatabaseUser,
-		c.DatabasePassword,
-		c.DatabaseName,
-		c.DatabaseSSLMode)
+		quoteConnValue(c.DatabaseUser),
+		quoteConnValue(c.DatabasePassword),
+		quoteConnValue(c.DatabaseName),
+		quoteConnValue(c.DatabaseSSLMode))
+}
+
+func quoteConnValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n'\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
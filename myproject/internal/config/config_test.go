@@ -0,0 +1,216 @@
+// config_test.go
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load เรียก LoadConfig ด้วยไฟล์ config.yaml ที่มีเนื้อหา file (และไฟล์อื่นใน extra) ในโฟลเดอร์ชั่วคราว
+// พร้อมตัวแปร env ที่กำหนด เพื่อไม่ให้ไฟล์หรือ env ของเครื่องที่รัน test มีผล
+func load(t *testing.T, file string, extra map[string]string, env map[string]string, args ...string) (Config, []string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range extra {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("APP_CONFIG", path)
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	return LoadConfig(args)
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, args, err := load(t, "", nil, nil)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(args) != 0 {
+		t.Errorf("args = %v, want none", args)
+	}
+	if cfg.AppPort != "8080" || cfg.RequestTimeout != 5*time.Second || cfg.DatabasePort != 5432 {
+		t.Errorf("port, timeout, db port = %s, %s, %d, want 8080, 5s, 5432", cfg.AppPort, cfg.RequestTimeout, cfg.DatabasePort)
+	}
+	if !cfg.SecureCookies {
+		t.Error("SecureCookies = false, want true by default")
+	}
+	for name, proxies := range map[string][]string{"TrustedProxies": cfg.TrustedProxies, "AuthProxies": cfg.AuthProxies} {
+		if got := strings.Join(proxies, ","); got != "127.0.0.1/8,::1/128" {
+			t.Errorf("%s = %s, want loopback only", name, got)
+		}
+	}
+	if cfg.CacheTTLs["GetProduct"] != time.Minute {
+		t.Errorf("CacheTTLs[GetProduct] = %s, want 1m", cfg.CacheTTLs["GetProduct"])
+	}
+	if limit := cfg.RateLimits["checkout"]; limit.Burst != 10 || limit.Window() != time.Minute {
+		t.Errorf("RateLimits[checkout] = %d per %s, want 10 per 1m", limit.Burst, limit.Window())
+	}
+}
+
+func TestLoadConfigLayering(t *testing.T) {
+	const file = "app:\n  port: 9000\n  profile: staging\nlog:\n  level: debug\n"
+	profile := map[string]string{"config.staging.yaml": "app:\n  port: 9100\n  request_timeout: 7s\n"}
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		args     []string
+		wantPort string
+	}{
+		{"profile file overrides config file", nil, nil, "9100"},
+		{"env overrides files", map[string]string{"APP_PORT": "9200"}, nil, "9200"},
+		{"flag overrides env", map[string]string{"APP_PORT": "9200"}, []string{"--port", "9300"}, "9300"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := load(t, file, profile, tt.env, tt.args...)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.AppPort != tt.wantPort {
+				t.Errorf("AppPort = %s, want %s", cfg.AppPort, tt.wantPort)
+			}
+			// ค่าที่ไม่ได้ทับยังมาจากชั้นก่อนหน้า
+			if cfg.LogLevel != "debug" || cfg.RequestTimeout != 7*time.Second {
+				t.Errorf("LogLevel, RequestTimeout = %s, %s, want debug from the config file and 7s from the profile", cfg.LogLevel, cfg.RequestTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReturnsCommandArgs(t *testing.T) {
+	_, args, err := load(t, "", nil, nil, "--log-level", "warn", "migrate", "down", "2")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if got := strings.Join(args, " "); got != "migrate down 2" {
+		t.Errorf("args = %q, want %q", got, "migrate down 2")
+	}
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		// want ข้อความที่ต้องอยู่ใน error ถ้าว่างต้องไม่มี error
+		want []string
+		// notWant ข้อความที่ต้องไม่อยู่ใน error
+		notWant []string
+	}{
+		{name: "valid overrides", env: map[string]string{"APP_PORT": "9000", "POSTGRES_REPLICAS": "host=r1, host=r2"}},
+		{name: "port out of range", env: map[string]string{"APP_PORT": "70000"}, want: []string{"app.port: must be a port number between 1 and 65535"}},
+		{name: "unknown key in file", file: "app:\n  prot: 8080\n", want: []string{"unknown config keys: app.prot"}},
+		{
+			name:    "type error is reported once",
+			env:     map[string]string{"POSTGRES_PORT": "abc"},
+			want:    []string{`postgres.port: must be a whole number (got "abc")`},
+			notWant: []string{"must be between 1 and 65535"},
+		},
+		{
+			name: "every invalid value is reported",
+			env:  map[string]string{"LOG_LEVEL": "verbose", "LOG_FORMAT": "xml", "TRACING_SAMPLE_RATIO": "2"},
+			want: []string{"log.level: must be one of", "log.format: must be one of", "tracing.sample_ratio: must be between 0 and 1"},
+		},
+		{
+			name: "idle connections above open connections",
+			env:  map[string]string{"POSTGRES_MAX_OPEN_CONNS": "5", "POSTGRES_MAX_IDLE_CONNS": "10"},
+			want: []string{"postgres.max_idle_conns: must not exceed postgres.max_open_conns (10 > 5)"},
+		},
+		{
+			name: "reconnect backoff range",
+			env:  map[string]string{"POSTGRES_RECONNECT_MIN_BACKOFF": "10s", "POSTGRES_RECONNECT_MAX_BACKOFF": "1s"},
+			want: []string{"postgres.reconnect_max_backoff: must not be less than postgres.reconnect_min_backoff"},
+		},
+		{name: "bad duration", env: map[string]string{"CART_TTL": "soon"}, want: []string{"cart.ttl: must be a duration"}},
+		{name: "bad rate limit", env: map[string]string{"RATELIMIT_LIMITS_SEARCH": "fast"}, want: []string{"ratelimit.limits.search: must be <count>/<period>"}},
+		{
+			name: "redis backend needs a redis URL",
+			env:  map[string]string{"CACHE_BACKEND": "redis", "CACHE_REDIS_URL": "http://cache:6379"},
+			want: []string{"cache.redis_url: must be a redis:// or rediss:// URL"},
+		},
+		{name: "bad proxy", env: map[string]string{"APP_AUTH_PROXIES": "10.0.0.1,gateway"}, want: []string{`app.auth_proxies: must be IP addresses or CIDR ranges (got "gateway")`}},
+		{name: "production needs a password", env: map[string]string{"APP_PROFILE": "production"}, want: []string{"postgres.password: is required in the production profile"}},
+		{name: "product URL without id", env: map[string]string{"APP_PRODUCT_URL": "https://shop.example.com/p"}, want: []string{"app.product_url: must contain {id}"}},
+		{name: "metrics on the API port", env: map[string]string{"METRICS_LISTEN_ADDR": ":8080"}, want: []string{"metrics.listen_addr: must be host:port on a port other than app.port"}},
+		{name: "negative shutdown delay", env: map[string]string{"APP_SHUTDOWN_DELAY": "-1s"}, want: []string{"app.shutdown_delay: must not be negative"}},
+		{
+			name: "sunset before deprecation",
+			env:  map[string]string{"APP_V1_DEPRECATED_AT": "2027-01-01", "APP_V1_SUNSET": "2026-12-31"},
+			want: []string{"app.v1_sunset: must be after app.v1_deprecated_at"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := load(t, tt.file, nil, tt.env)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("LoadConfig() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("LoadConfig() error = nil, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(err.Error(), notWant) {
+					t.Errorf("error %q contains %q", err, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigSecretFiles(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "db_password")
+	if err := os.WriteFile(secret, []byte("s3cret pass\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "read from file without trailing newline", env: map[string]string{"POSTGRES_PASSWORD_FILE": secret}, want: "s3cret pass"},
+		{name: "file and value both set", env: map[string]string{"POSTGRES_PASSWORD_FILE": secret, "POSTGRES_PASSWORD": "other"}, wantErr: "postgres.password and postgres.password_file are both set"},
+		{name: "missing file", env: map[string]string{"POSTGRES_PASSWORD_FILE": secret + ".missing"}, wantErr: "failed to read postgres.password_file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := load(t, "", nil, tt.env)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.DatabasePassword != tt.want {
+				t.Errorf("DatabasePassword = %q, want %q", cfg.DatabasePassword, tt.want)
+			}
+			// ค่าที่มีช่องว่างต้องถูกครอบใน connection string
+			if want := "password='s3cret pass'"; !strings.Contains(cfg.GetConnectionString(), want) {
+				t.Errorf("GetConnectionString() = %q, want it to contain %q", cfg.GetConnectionString(), want)
+			}
+		})
+	}
+}
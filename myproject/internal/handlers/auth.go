@@ -0,0 +1,177 @@
+// auth.go
+package handlers
+
+import (
+	"fmt"
+	"myproject/internal/bookstore"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// apiKeyHeader header สำหรับส่ง API key แทน Authorization: Bearer
+	apiKeyHeader = "X-API-Key"
+	// apiKeyContextKey key ใน gin.Context ที่เก็บ API key ที่ยืนยันแล้ว
+	apiKeyContextKey = "api_key"
+	// userContextKey key ใน gin.Context ที่เก็บรหัสผู้ใช้ที่ยืนยันแล้ว
+	userContextKey = "user_id"
+)
+
+// TrustedUser อ่านผู้ใช้ที่ล็อกอินแล้วจาก X-User-ID ซึ่งระบบยืนยันตัวตนด้านหน้าเป็นผู้ใส่มาให้
+// header นี้เชื่อได้เฉพาะ request ที่เชื่อมต่อตรงมาจาก proxies (IP ของการเชื่อมต่อ ไม่ใช่ X-Forwarded-For)
+// request จากที่อื่นจะถูกลบ header นี้ทิ้งและถือเป็นแขก ไม่อย่างนั้นใครก็อ้างเป็นผู้ใช้คนอื่นแล้วเข้าถึงตะกร้าของเขาได้
+func TrustedUser(proxies []string) (gin.HandlerFunc, error) {
+	networks, err := parseNetworks(proxies)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *gin.Context) {
+		userIDStr := c.GetHeader(userIDHeader)
+		if userIDStr == "" {
+			c.Next()
+			return
+		}
+		if !containsIP(networks, net.ParseIP(c.RemoteIP())) {
+			c.Request.Header.Del(userIDHeader)
+			c.Next()
+			return
+		}
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil || userID <= 0 {
+			abort(c, errInvalidUserID)
+			return
+		}
+		c.Set(userContextKey, userID)
+		c.Next()
+	}, nil
+}
+
+// requestUserID ผู้ใช้ที่ยืนยันแล้วของ request นี้ (ดู TrustedUser) คืน 0 ถ้าเป็นแขก
+func requestUserID(c *gin.Context) int {
+	return c.GetInt(userContextKey)
+}
+
+// parseNetworks แปลงรายการ IP หรือ CIDR เป็นช่วงเครือข่าย IP เดี่ยวถือเป็นช่วงที่มีแค่ IP นั้น
+func parseNetworks(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, item := range list {
+		if ip := net.ParseIP(item); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR range %q", item)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyAuth ยืนยัน API key ของ partner จาก Authorization: Bearer <key> หรือ X-API-Key
+// request ที่ไม่มี key ผ่านไปได้ตามเดิม (เส้นทางสาธารณะ) ส่วน key ที่ผิดหรือถูกยกเลิกแล้วจะได้ 401
+// key ที่ถูกต้องใช้เป็นตัวตนของ client สำหรับ rate limit แทน IP
+//
+// Authorization ที่ไม่ใช่ API key ของเรา (ไม่ขึ้นต้นด้วย msk_) เช่น token ของระบบยืนยันตัวตนด้านหน้า จะถูกปล่อยผ่าน
+func APIKeyAuth(bs *bookstore.BookStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := presentedAPIKey(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		apiKey, err := bs.AuthenticateAPIKey(c.Request.Context(), key)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			abort(c, err)
+			return
+		}
+		c.Set(apiKeyContextKey, apiKey)
+		c.Set(rateLimitClientKey, "apikey:"+strconv.Itoa(apiKey.ID))
+		c.Next()
+	}
+}
+
+// presentedAPIKey API key ที่ส่งมากับ request (ยังไม่ได้ยืนยัน) คืนค่าว่างถ้าไม่มี
+func presentedAPIKey(c *gin.Context) string {
+	if key := c.GetHeader(apiKeyHeader); key != "" {
+		return key
+	}
+	if scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		if token = strings.TrimSpace(token); strings.HasPrefix(token, bookstore.APIKeyPrefix) {
+			return token
+		}
+	}
+	return ""
+}
+
+// requestAPIKey API key ที่ยืนยันแล้วของ request นี้
+func requestAPIKey(c *gin.Context) (bookstore.APIKey, bool) {
+	v, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return bookstore.APIKey{}, false
+	}
+	apiKey, ok := v.(bookstore.APIKey)
+	return apiKey, ok
+}
+
+// RequireScope บังคับให้ต้องมี API key ที่มี scope นี้ ใช้กับเส้นทางของ partner และเจ้าของร้าน
+// ถ้าเส้นทางมี :store_id key ต้องใช้กับร้านนั้นได้ (ดู bookstore.APIKey.CanAccessStore)
+// ไม่อย่างนั้น key ของร้านหนึ่งจะอ่านคำสั่งซื้อหรือแก้สต็อกของร้านอื่นได้
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := requestAPIKey(c)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			abort(c, bookstore.NewError(bookstore.ErrUnauthorized, "API key required"))
+			return
+		}
+		if !apiKey.HasScope(scope) {
+			abort(c, forbiddenScope(scope))
+			return
+		}
+		// store_id ที่ไม่ใช่ตัวเลขปล่อยให้ handler ตอบ 400 ตามปกติ
+		if storeID, err := strconv.Atoi(c.Param("store_id")); err == nil && !apiKey.CanAccessStore(storeID, scope) {
+			abort(c, bookstore.NewError(bookstore.ErrForbidden, "API key is not allowed to access this store").
+				WithDetails(map[string]interface{}{"store_id": storeID}))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ScopeIfAPIKey เส้นทางสาธารณะที่ partner เรียกได้ด้วย ถ้าส่ง API key มาต้องเป็น key ที่มี scope นี้
+func ScopeIfAPIKey(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey, ok := requestAPIKey(c); ok && !apiKey.HasScope(scope) {
+			abort(c, forbiddenScope(scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+func forbiddenScope(scope string) error {
+	return bookstore.NewError(bookstore.ErrForbidden, "API key is missing the required scope").
+		WithDetails(map[string]interface{}{"required_scope": scope})
+}
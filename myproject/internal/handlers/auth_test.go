@@ -0,0 +1,137 @@
+// auth_test.go
+package handlers
+
+import (
+	"myproject/internal/bookstore"
+	"myproject/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTrustedUser(t *testing.T) {
+	trustedUser, err := TrustedUser([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remote     string
+		userID     string
+		wantStatus int
+		wantUser   int
+	}{
+		{name: "guest", remote: "192.0.2.1:1234", wantStatus: http.StatusOK},
+		{name: "trusted proxy", remote: "10.1.2.3:1234", userID: "42", wantStatus: http.StatusOK, wantUser: 42},
+		{name: "trusted IPv6 proxy", remote: "[::1]:1234", userID: "42", wantStatus: http.StatusOK, wantUser: 42},
+		{name: "untrusted client is a guest", remote: "192.0.2.1:1234", userID: "42", wantStatus: http.StatusOK},
+		{name: "invalid id from trusted proxy", remote: "10.1.2.3:1234", userID: "abc", wantStatus: http.StatusBadRequest},
+		{name: "zero id from trusted proxy", remote: "10.1.2.3:1234", userID: "0", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(ErrorHandler(), trustedUser)
+			r.GET("/", func(c *gin.Context) {
+				// handler ต้องไม่เห็น X-User-ID ที่ไม่ได้มาจาก proxy ที่เชื่อได้
+				c.String(http.StatusOK, "%d %s", requestUserID(c), c.GetHeader(userIDHeader))
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, limitedRequest("/", tt.remote, tt.userID))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			want := "0 "
+			if tt.wantUser != 0 {
+				want = strconv.Itoa(tt.wantUser) + " " + tt.userID
+			}
+			if got := w.Body.String(); got != want {
+				t.Errorf("user, header = %q, want %q", got, want)
+			}
+		})
+	}
+
+	if _, err := TrustedUser([]string{"gateway"}); err == nil {
+		t.Error("TrustedUser(gateway) error = nil, want invalid range")
+	}
+}
+
+func TestRequireScopeStoreBinding(t *testing.T) {
+	store := func(id int) *int { return &id }
+
+	tests := []struct {
+		name       string
+		key        *bookstore.APIKey
+		scope      string
+		path       string
+		wantStatus int
+	}{
+		{name: "no key", scope: bookstore.ScopeOrdersRead, path: "/stores/1", wantStatus: http.StatusUnauthorized},
+		{name: "missing scope", key: &bookstore.APIKey{Scopes: []string{bookstore.ScopeCatalogRead}, StoreID: store(1)}, scope: bookstore.ScopeOrdersRead, path: "/stores/1", wantStatus: http.StatusForbidden},
+		{name: "own store", key: &bookstore.APIKey{Scopes: []string{bookstore.ScopeOrdersRead}, StoreID: store(1)}, scope: bookstore.ScopeOrdersRead, path: "/stores/1", wantStatus: http.StatusOK},
+		{name: "other store", key: &bookstore.APIKey{Scopes: []string{bookstore.ScopeOrdersRead}, StoreID: store(1)}, scope: bookstore.ScopeOrdersRead, path: "/stores/2", wantStatus: http.StatusForbidden},
+		{name: "bound catalog key on other store", key: &bookstore.APIKey{Scopes: []string{bookstore.ScopeCatalogRead}, StoreID: store(1)}, scope: bookstore.ScopeCatalogRead, path: "/stores/2", wantStatus: http.StatusForbidden},
+		{name: "unbound catalog key", key: &bookstore.APIKey{Scopes: []string{bookstore.ScopeCatalogRead}}, scope: bookstore.ScopeCatalogRead, path: "/stores/2", wantStatus: http.StatusOK},
+		{name: "unbound key with store scope", key: &bookstore.APIKey{Scopes: []string{bookstore.ScopeInventoryWrite}}, scope: bookstore.ScopeInventoryWrite, path: "/stores/1", wantStatus: http.StatusForbidden},
+		{name: "non-numeric store is left to the handler", key: &bookstore.APIKey{Scopes: []string{bookstore.ScopeOrdersRead}, StoreID: store(1)}, scope: bookstore.ScopeOrdersRead, path: "/stores/abc", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(ErrorHandler(), func(c *gin.Context) {
+				if tt.key != nil {
+					c.Set(apiKeyContextKey, *tt.key)
+				}
+				c.Next()
+			})
+			r.GET("/stores/:store_id", RequireScope(tt.scope), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAttemptsLimitedBeforeLookup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := NewRateLimiter(ratelimit.NewMemory(), map[string]ratelimit.Limit{"auth": {Rate: 1.0 / 60, Burst: 1}})
+	r := gin.New()
+	r.Use(ErrorHandler(), apiKeyAttempts(limiter))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	tests := []struct {
+		name       string
+		header     map[string]string
+		wantStatus int
+	}{
+		{"first key attempt", map[string]string{apiKeyHeader: bookstore.APIKeyPrefix + "guess1"}, http.StatusNoContent},
+		{"second key attempt", map[string]string{apiKeyHeader: bookstore.APIKeyPrefix + "guess2"}, http.StatusTooManyRequests},
+		{"bearer key attempt", map[string]string{"Authorization": "Bearer " + bookstore.APIKeyPrefix + "guess3"}, http.StatusTooManyRequests},
+		{"other bearer token is not counted", map[string]string{"Authorization": "Bearer session-token"}, http.StatusNoContent},
+		{"no key is not counted", nil, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := limitedRequest("/", "192.0.2.1:1234", "")
+		for key, value := range tt.header {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+	}
+}
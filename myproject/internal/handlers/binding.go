@@ -0,0 +1,124 @@
+// binding.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"myproject/internal/bookstore"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxBodySize ขนาดสูงสุดของ body ที่ bindBody ยอมอ่าน (ไม่รวมการนำเข้าไฟล์ซึ่งมีขีดจำกัดของตัวเอง)
+const maxBodySize = 1 << 20
+
+func init() {
+	// ให้ FieldError.Field() เป็นชื่อตาม json tag แบบเดียวกับที่ client ส่งมา
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// bindBody อ่าน body ที่เป็น JSON หรือ form ลงใน req แล้วตรวจตามกฎใน tag binding
+// body ว่างถือว่าไม่ได้ส่งฟิลด์ใดมา (ค่าเริ่มต้นใน req ยังอยู่) แต่ยังต้องผ่านกฎเช่น required
+// ฟิลด์ที่ไม่ผ่านกฎหรือชนิดไม่ตรงคืนเป็น ErrValidation พร้อมข้อความของแต่ละฟิลด์ใน details.fields
+func bindBody(c *gin.Context, req interface{}) error {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize)
+
+	err := c.ShouldBind(req)
+	if errors.Is(err, io.EOF) {
+		err = binding.Validator.ValidateStruct(req)
+	}
+
+	var (
+		fieldErrs validator.ValidationErrors
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+		sizeErr   *http.MaxBytesError
+	)
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &fieldErrs):
+		fields := make(map[string]string, len(fieldErrs))
+		for _, fe := range fieldErrs {
+			fields[fe.Field()] = fieldMessage(fe)
+		}
+		return fieldsError(fields)
+	case errors.As(err, &typeErr):
+		return fieldsError(map[string]string{typeErr.Field: "must be " + jsonTypeName(typeErr.Type)})
+	case errors.As(err, &sizeErr):
+		return badRequest("Request body is larger than 1 MB")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest("Malformed JSON body")
+	default:
+		return badRequest("Malformed request body")
+	}
+}
+
+// fieldsError error ของฟิลด์ใน body ที่ไม่ผ่านการตรวจสอบ ตอบเป็น 422 validation_failed
+func fieldsError(fields map[string]string) error {
+	return bookstore.NewError(bookstore.ErrValidation, "Request body failed validation").
+		WithDetails(map[string]interface{}{"fields": fields})
+}
+
+// fieldMessage ข้อความที่อธิบายว่าฟิลด์ผิดกฎข้อไหน
+func fieldMessage(fe validator.FieldError) string {
+	unit := ""
+	if fe.Kind() == reflect.String {
+		unit = " characters"
+	}
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fe.Param() + unit
+	case "max", "lte":
+		return "must be at most " + fe.Param() + unit
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	default:
+		return "is invalid"
+	}
+}
+
+// jsonTypeName ชื่อชนิดของค่าที่ฟิลด์ต้องการ สำหรับข้อความเมื่อ client ส่งค่าผิดชนิดมา
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
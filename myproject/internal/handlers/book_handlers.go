@@ -3,9 +3,11 @@ package handlers
 
 import (
 	"myproject/internal/bookstore"
+	"myproject/internal/metrics"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,7 +19,7 @@ func (h *BookHandlers) GetAllGuitars(c *gin.Context) {
 	// ดึงข้อมูลร้านทั้งหมด
 	stores, err := h.bs.GetAllStoreInfo(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
@@ -26,7 +28,7 @@ func (h *BookHandlers) GetAllGuitars(c *gin.Context) {
 		// ดึงสินค้าทั้งหมดจากร้านนี้
 		products, err := h.bs.GetProductsByStore(c.Request.Context(), store.ID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			abort(c, err)
 			return
 		}
 
@@ -40,7 +42,7 @@ func (h *BookHandlers) GetAllGuitars(c *gin.Context) {
 
 	// ถ้าไม่มีสินค้ากีตาร์ในร้าน
 	if len(allGuitars) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No guitars found"})
+		abort(c, notFound("No guitars found"))
 		return
 	}
 
@@ -49,11 +51,77 @@ func (h *BookHandlers) GetAllGuitars(c *gin.Context) {
 }
 
 type BookHandlers struct {
-	bs *bookstore.BookStore
+	bs   *bookstore.BookStore
+	opts Options
 }
 
-func NewBookHandlers(bs *bookstore.BookStore) *BookHandlers {
-	return &BookHandlers{bs: bs}
+// Options การตั้งค่าของ BookHandlers
+type Options struct {
+	// SecureCookies ส่ง cookie ของตะกร้าแขกผ่าน HTTPS เท่านั้น ปิดได้เมื่อทดสอบบนเครื่องผ่าน http
+	SecureCookies bool
+	// PublicBaseURL URL ที่ client ภายนอกใช้เรียกเซิร์ฟเวอร์นี้ เช่น https://api.example.com ใช้สร้างลิงก์ใน feed
+	PublicBaseURL string
+	// ProductURL ลิงก์หน้าสินค้าใน feed (ดู catalogio.FeedInfo.ProductURL)
+	ProductURL string
+}
+
+func NewBookHandlers(bs *bookstore.BookStore, opts Options) *BookHandlers {
+	return &BookHandlers{bs: bs, opts: opts}
+}
+
+const (
+	// cartTokenCookie ชื่อ cookie ที่เก็บ token ของตะกร้าแขก
+	cartTokenCookie = "cart_token"
+	// cartTokenHeader header สำหรับ client ที่ไม่ใช้ cookie (เช่น mobile app)
+	cartTokenHeader = "X-Cart-Token"
+	// userIDHeader ผู้ใช้ที่ล็อกอินแล้ว ระบบยืนยันตัวตนด้านหน้าจะเป็นผู้ใส่ค่านี้มาให้ (ดู TrustedUser)
+	userIDHeader = "X-User-ID"
+	// cartTokenMaxAge อายุของ cookie ตะกร้าแขก (30 วัน)
+	cartTokenMaxAge = 30 * 24 * 60 * 60
+)
+
+// errInvalidUserID header X-User-ID ไม่ใช่ตัวเลขที่ถูกต้อง
+var errInvalidUserID = badRequest("Invalid user ID")
+
+// cartOwner หาว่าตะกร้าของ request นี้เป็นของใคร และผูก request กับเจ้าของตะกร้า
+// เพื่อให้การอ่านหลังการเขียนของเจ้าของคนเดียวกันไม่ได้ข้อมูลเก่าจาก read replica
+// ผู้ใช้ที่ล็อกอินแล้วมาจาก TrustedUser เท่านั้น ถ้ายังถือ token ของแขกอยู่ จะรวมตะกร้าแขกเข้ากับตะกร้าของผู้ใช้ก่อน
+func (h *BookHandlers) cartOwner(c *gin.Context) (bookstore.CartOwner, error) {
+	var owner bookstore.CartOwner
+
+	token := c.GetHeader(cartTokenHeader)
+	if token == "" {
+		token, _ = c.Cookie(cartTokenCookie)
+	}
+
+	if userID := requestUserID(c); userID != 0 {
+		owner.UserID = userID
+		withSession(c, owner)
+
+		if token != "" {
+			if err := h.bs.MergeGuestCart(c.Request.Context(), token, userID); err != nil {
+				return owner, err
+			}
+			// ตะกร้าแขกถูกรวมแล้ว ลบ cookie ทิ้ง
+			c.SetCookie(cartTokenCookie, "", -1, "/", "", h.opts.SecureCookies, true)
+		}
+		return owner, nil
+	}
+
+	owner.Token = token
+	withSession(c, owner)
+	return owner, nil
+}
+
+func withSession(c *gin.Context, owner bookstore.CartOwner) {
+	c.Request = c.Request.WithContext(bookstore.WithSession(c.Request.Context(), owner))
+}
+
+// setCartToken ส่ง token ของตะกร้าแขกกลับไปทั้งใน cookie และ header
+func (h *BookHandlers) setCartToken(c *gin.Context, token string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cartTokenCookie, token, cartTokenMaxAge, "/", "", h.opts.SecureCookies, true)
+	c.Header(cartTokenHeader, token)
 }
 
 func (h *BookHandlers) HealthCheck(c *gin.Context) {
@@ -71,31 +139,31 @@ func (h *BookHandlers) HealthCheck(c *gin.Context) {
 func (h *BookHandlers) GetAllStoreInfo(c *gin.Context) {
 	stores, err := h.bs.GetAllStoreInfo(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"store_info": stores})
+	jsonWithETag(c, http.StatusOK, gin.H{"store_info": stores}, cacheable{CacheControl: cacheControlStore})
 }
 
 func (h *BookHandlers) GetStoreInfoByID(c *gin.Context) {
 	// ดึง ID จาก URL parameter
-	idStr := c.Param("id")
+	idStr := c.Param("store_id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
 	// เรียกฟังก์ชัน GetStoreInfoByID จาก BookStore
 	store, err := h.bs.GetStoreInfoByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
 	// ส่งข้อมูลร้านในรูปแบบ JSON
-	c.JSON(http.StatusOK, gin.H{
+	jsonWithETag(c, http.StatusOK, gin.H{
 		"id":           store.ID,
 		"logo_path":    store.LogoPath,
 		"store_name":   store.StoreName,
@@ -103,51 +171,51 @@ func (h *BookHandlers) GetStoreInfoByID(c *gin.Context) {
 		"address":      store.Address,
 		"phone_number": store.PhoneNumber,
 		"email":        store.Email,
-	})
+	}, cacheable{CacheControl: cacheControlStore})
 }
 
 func (h *BookHandlers) GetProductsByStore(c *gin.Context) {
 	storeIDStr := c.Param("store_id")
 	storeID, err := strconv.Atoi(storeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
 	products, err := h.bs.GetProductsByStore(c.Request.Context(), storeID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
 	if len(products) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No products found for this store"})
+		abort(c, notFound("No products found for this store"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"store_id": storeID, "products": products})
+	jsonWithETag(c, http.StatusOK, gin.H{"store_id": storeID, "products": products}, cacheable{CacheControl: cacheControlCatalog})
 }
 
 func (h *BookHandlers) GetNewProductsByStore(c *gin.Context) {
 	storeIDStr := c.Param("store_id")
 	storeID, err := strconv.Atoi(storeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
 	products, err := h.bs.GetNewProductsByStore(c.Request.Context(), storeID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
 	if len(products) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No products found for this store"})
+		abort(c, notFound("No products found for this store"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"store_id": storeID, "products": products})
+	jsonWithETag(c, http.StatusOK, gin.H{"store_id": storeID, "products": products}, cacheable{CacheControl: cacheControlCatalog})
 }
 
 func (h *BookHandlers) SearchProducts(c *gin.Context) {
@@ -156,19 +224,19 @@ func (h *BookHandlers) SearchProducts(c *gin.Context) {
 
 	// ถ้าไม่มีค่าของ product_name
 	if productName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		abort(c, badRequest("Search query is required"))
 		return
 	}
 
 	// ค้นหาผลิตภัณฑ์ที่มีชื่อตรงกับ productName
 	products, err := h.bs.SearchProducts(c.Request.Context(), productName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
 	// ส่งข้อมูลผลิตภัณฑ์ที่ค้นหากลับไป
-	c.JSON(http.StatusOK, gin.H{"products": products})
+	jsonWithETag(c, http.StatusOK, gin.H{"products": products}, cacheable{CacheControl: cacheControlCatalog})
 }
 
 func (h *BookHandlers) GetProduct(c *gin.Context) {
@@ -176,19 +244,19 @@ func (h *BookHandlers) GetProduct(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		abort(c, badRequest("Invalid product ID"))
 		return
 	}
 
 	// ค้นหาผลิตภัณฑ์
 	product, err := h.bs.GetProduct(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		abort(c, err)
 		return
 	}
 
 	// ส่งข้อมูลสินค้า
-	c.JSON(http.StatusOK, gin.H{"product": product})
+	jsonWithETag(c, http.StatusOK, gin.H{"product": product}, cacheable{CacheControl: cacheControlProduct, LastModified: product.UpdatedAt})
 }
 
 func (h *BookHandlers) SearchProductsByStore(c *gin.Context) {
@@ -196,7 +264,7 @@ func (h *BookHandlers) SearchProductsByStore(c *gin.Context) {
 	storeIDStr := c.Param("store_id")
 	storeID, err := strconv.Atoi(storeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
@@ -205,26 +273,26 @@ func (h *BookHandlers) SearchProductsByStore(c *gin.Context) {
 
 	// ตรวจสอบว่ามีการส่งคำค้นหาหรือไม่
 	if productName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		abort(c, badRequest("Search query is required"))
 		return
 	}
 
 	// เรียกใช้ฟังก์ชันค้นหาผลิตภัณฑ์ในร้านที่กำหนดจาก BookStore
 	products, err := h.bs.SearchProductsByStore(c.Request.Context(), productName, storeID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
 	// ส่งข้อมูลผลิตภัณฑ์ที่ค้นหากลับไป
-	c.JSON(http.StatusOK, gin.H{"store_id": storeID, "products": products})
+	jsonWithETag(c, http.StatusOK, gin.H{"store_id": storeID, "products": products}, cacheable{CacheControl: cacheControlCatalog})
 }
 
 func (h *BookHandlers) GetAllProductsByStore(c *gin.Context) {
 	storeIDStr := c.Param("store_id")
 	storeID, err := strconv.Atoi(storeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
@@ -234,16 +302,16 @@ func (h *BookHandlers) GetAllProductsByStore(c *gin.Context) {
 	// เรียกฟังก์ชัน GetAllProductsByStore จาก BookStore พร้อมกับ sortOrder
 	products, err := h.bs.GetAllProductsByStore(c.Request.Context(), storeID, sortOrder)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
 	if len(products) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No products found for this store"})
+		abort(c, notFound("No products found for this store"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"store_id": storeID, "products": products})
+	jsonWithETag(c, http.StatusOK, gin.H{"store_id": storeID, "products": products}, cacheable{CacheControl: cacheControlCatalog})
 }
 
 // ตัวอย่างสำหรับ Go (Gin framework)
@@ -251,51 +319,57 @@ func (h *BookHandlers) GetProductsByCategoryAndStore(c *gin.Context) {
 	storeIDStr := c.Param("store_id")
 	storeID, err := strconv.Atoi(storeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
 	category := c.Query("category")
 	if category == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Category is required"})
+		abort(c, badRequest("Category is required"))
 		return
 	}
 
 	products, err := h.bs.GetProductsByCategoryAndStore(c.Request.Context(), storeID, category)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
 	if len(products) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No products found for this store and category"})
+		abort(c, notFound("No products found for this store and category"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"store_id": storeID, "category": category, "products": products})
+	jsonWithETag(c, http.StatusOK, gin.H{"store_id": storeID, "category": category, "products": products}, cacheable{CacheControl: cacheControlCatalog})
 }
 
 func (h *BookHandlers) GetALLProductsByCategory(c *gin.Context) {
 	category := c.DefaultQuery("category", "") // รับค่าหมวดหมู่จาก query string
 
 	if category == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Category is required"})
+		abort(c, badRequest("Category is required"))
 		return
 	}
 
 	// เรียกฟังก์ชัน GetALLProductsByCategory จาก BookStore
 	products, err := h.bs.GetALLProductsByCategory(c.Request.Context(), category)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
 	if len(products) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No products found for this category"})
+		abort(c, notFound("No products found for this category"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"category": category, "products": products})
+	jsonWithETag(c, http.StatusOK, gin.H{"category": category, "products": products}, cacheable{CacheControl: cacheControlCatalog})
+}
+
+// addToCartRequest จำนวนที่จะเพิ่มลงตะกร้า ไม่ส่งมาถือว่าเป็น 1 (JSON ไม่ใช้ default ใน tag form จึงต้องตั้งค่าไว้ก่อน bind ด้วย)
+// ค่าสูงสุดใน tag binding ต้องตรงกับ bookstore.MaxCartItemQuantity
+type addToCartRequest struct {
+	Quantity int `json:"quantity" form:"quantity,default=1" binding:"min=1,max=99"`
 }
 
 func (h *BookHandlers) AddToCart(c *gin.Context) {
@@ -303,7 +377,7 @@ func (h *BookHandlers) AddToCart(c *gin.Context) {
 	storeIDStr := c.Param("store_id")
 	storeID, err := strconv.Atoi(storeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
@@ -311,55 +385,137 @@ func (h *BookHandlers) AddToCart(c *gin.Context) {
 	productIDStr := c.Param("product_id")
 	productID, err := strconv.Atoi(productIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		abort(c, badRequest("Invalid product ID"))
 		return
 	}
 
 	// ตรวจสอบ quantity และตั้งค่าเริ่มต้นเป็น 1 ถ้าไม่ได้ส่งมา
-	quantityStr := c.DefaultPostForm("quantity", "1")
-	quantity, err := strconv.Atoi(quantityStr)
-	if err != nil || quantity <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quantity"})
+	req := addToCartRequest{Quantity: 1}
+	if err := bindBody(c, &req); err != nil {
+		abort(c, err)
 		return
 	}
 
+	owner, err := h.cartOwner(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	// แขกที่ยังไม่มีตะกร้า ออก token ให้ใหม่
+	newToken := owner.IsZero()
+	if newToken {
+		owner.Token, err = bookstore.NewCartToken()
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		withSession(c, owner)
+	}
+
 	// เพิ่มสินค้าลงในตะกร้า
-	err = h.bs.AddToCart(c.Request.Context(), storeID, productID, quantity)
+	created, err := h.bs.AddToCart(c.Request.Context(), owner, storeID, productID, req.Quantity)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
+	if created {
+		metrics.CartCreated(storeID)
+	}
+
+	// ส่ง token ให้แขกหลังจากเพิ่มสินค้าสำเร็จแล้วเท่านั้น
+	if newToken {
+		h.setCartToken(c, owner.Token)
+	}
 
 	// ส่ง response ว่าสินค้าได้ถูกเพิ่มในตะกร้า
 	c.JSON(http.StatusOK, gin.H{
 		"message":    "Product added to cart",
 		"store_id":   storeID,
 		"product_id": productID,
-		"quantity":   quantity,
+		"quantity":   req.Quantity,
+		"cart_owner": owner,
 	})
 }
 
-func (h *BookHandlers) GetCartItemsByStore(c *gin.Context) {
+// GetCart แสดงตะกร้าของร้านนั้น พร้อมยอดต่อบรรทัด ยอดรวม และจำนวนคงเหลือในสต็อก
+func (h *BookHandlers) GetCart(c *gin.Context) {
 	storeIDStr := c.Param("store_id")
 	storeID, err := strconv.Atoi(storeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
-	// ดึงข้อมูลสินค้าที่อยู่ในตะกร้าของร้านนั้น
-	products, err := h.bs.GetCartItemsByStore(c.Request.Context(), storeID)
+	owner, err := h.cartOwner(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
-	if len(products) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No products in the cart for this store"})
+	// ยังไม่มีตะกร้า ส่งตะกร้าว่างกลับไป
+	cart := bookstore.Cart{StoreID: storeID, Items: []bookstore.CartItem{}}
+	if !owner.IsZero() {
+		cart, err = h.bs.GetCart(c.Request.Context(), owner, storeID)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cart": cart})
+}
+
+// updateCartItemRequest จำนวนใหม่ของรายการในตะกร้า (0 = ลบรายการ)
+type updateCartItemRequest struct {
+	Quantity *int `json:"quantity" form:"quantity" binding:"required,min=0,max=99"`
+}
+
+// UpdateCartItemQuantity ตั้งจำนวนของรายการในตะกร้าโดยตรง แล้วส่งตะกร้าที่อัปเดตแล้วกลับไป
+func (h *BookHandlers) UpdateCartItemQuantity(c *gin.Context) {
+	storeIDStr := c.Param("store_id")
+	storeID, err := strconv.Atoi(storeIDStr)
+	if err != nil {
+		abort(c, badRequest("Invalid store ID"))
+		return
+	}
+
+	itemIDStr := c.Param("item_id")
+	itemID, err := strconv.Atoi(itemIDStr)
+	if err != nil {
+		abort(c, badRequest("Invalid cart item ID"))
+		return
+	}
+
+	var req updateCartItemRequest
+	if err := bindBody(c, &req); err != nil {
+		abort(c, err)
+		return
+	}
+
+	owner, err := h.cartOwner(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if owner.IsZero() {
+		abort(c, bookstore.ErrCartItemNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"store_id": storeID, "cart_items": products})
+	err = h.bs.UpdateCartItemQuantity(c.Request.Context(), owner, storeID, itemID, *req.Quantity)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	cart, err := h.bs.GetCart(c.Request.Context(), owner, storeID)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cart": cart})
 }
 
 func (h *BookHandlers) DeleteProductFromCart(c *gin.Context) {
@@ -367,7 +523,7 @@ func (h *BookHandlers) DeleteProductFromCart(c *gin.Context) {
 	storeIDStr := c.Param("store_id")
 	storeID, err := strconv.Atoi(storeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
@@ -375,14 +531,24 @@ func (h *BookHandlers) DeleteProductFromCart(c *gin.Context) {
 	productIDStr := c.Param("product_id")
 	productID, err := strconv.Atoi(productIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		abort(c, badRequest("Invalid product ID"))
+		return
+	}
+
+	owner, err := h.cartOwner(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if owner.IsZero() {
+		abort(c, bookstore.ErrCartItemNotFound)
 		return
 	}
 
 	// เรียกใช้ฟังก์ชันลบสินค้าจากตะกร้าใน BookStore
-	err = h.bs.DeleteProductFromCart(c.Request.Context(), storeID, productID)
+	err = h.bs.DeleteProductFromCart(c.Request.Context(), owner, storeID, productID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
@@ -394,52 +560,132 @@ func (h *BookHandlers) DeleteProductFromCart(c *gin.Context) {
 	})
 }
 
+// checkoutRequest ข้อมูลติดต่อที่ส่งมาตอน checkout (รับได้ทั้ง JSON และ form)
+// ความยาวสูงสุดของชื่อ อีเมล และเบอร์โทรตามขนาดคอลัมน์ของตาราง orders
+type checkoutRequest struct {
+	Name    string `json:"name" form:"name" binding:"max=255"`
+	Email   string `json:"email" form:"email" binding:"omitempty,email,max=100"`
+	Phone   string `json:"phone" form:"phone" binding:"max=20"`
+	Address string `json:"address" form:"address" binding:"max=1000"`
+}
+
 func (h *BookHandlers) Checkout(c *gin.Context) {
 	// ตรวจสอบ store_id
 	storeIDStr := c.Param("store_id")
 	storeID, err := strconv.Atoi(storeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store ID"})
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
-	// เรียกใช้ฟังก์ชันดึงสินค้าทั้งหมดในตะกร้าของร้านนั้น
-	cartItems, err := h.bs.GetCartItemsByStore(c.Request.Context(), storeID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// ข้อมูลติดต่อ ไม่บังคับสำหรับผู้ใช้ที่ล็อกอินแล้ว
+	var req checkoutRequest
+	if err := bindBody(c, &req); err != nil {
+		abort(c, err)
 		return
 	}
 
-	if len(cartItems) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No items in cart to checkout"})
+	order, err := h.placeOrder(c, storeID, req)
+	if err != nil {
+		abort(c, err)
 		return
 	}
 
-	// คำนวณยอดรวมการสั่งซื้อจากสินค้าที่อยู่ในตะกร้า
-	totalAmount := 0.0
-	for _, item := range cartItems {
-		totalAmount += item.Price * float64(item.Quantity)
+	// ส่ง response ว่าการสั่งซื้อเสร็จสมบูรณ์
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Checkout successful",
+		"store_id":     storeID,
+		"order_id":     order.ID,
+		"total_amount": order.TotalAmount,
+	})
+}
+
+// placeOrder ชำระเงินและสร้างคำสั่งซื้อจากตะกร้าของร้าน ใช้ร่วมกันทั้ง API v1 และ v2
+// การชำระเงินที่ล้มเหลวหลังจากพบตะกร้าแล้วจะถูกนับใน metrics ก่อนคืน error
+func (h *BookHandlers) placeOrder(c *gin.Context, storeID int, req checkoutRequest) (bookstore.Order, error) {
+	owner, err := h.cartOwner(c)
+	if err != nil {
+		return bookstore.Order{}, err
+	}
+	if owner.IsZero() {
+		return bookstore.Order{}, badRequest("No items in cart to checkout")
+	}
+
+	// แขกต้องให้ชื่อและอีเมลไว้สำหรับติดต่อ
+	if owner.UserID == 0 {
+		fields := make(map[string]string)
+		if req.Name == "" {
+			fields["name"] = "is required for guest checkout"
+		}
+		if req.Email == "" {
+			fields["email"] = "is required for guest checkout"
+		}
+		if len(fields) > 0 {
+			return bookstore.Order{}, fieldsError(fields)
+		}
+	}
+
+	// เรียกใช้ฟังก์ชันดึงสินค้าทั้งหมดในตะกร้าของร้านนั้น
+	cart, err := h.bs.GetCart(c.Request.Context(), owner, storeID)
+	if err != nil {
+		return bookstore.Order{}, checkoutFailed(storeID, err)
+	}
+
+	if len(cart.Items) == 0 {
+		return bookstore.Order{}, checkoutFailed(storeID, badRequest("No items in cart to checkout"))
 	}
 
 	// ทดสอบการชำระเงิน (ในที่นี้เป็นแค่การจำลองการทำงาน)
 	paymentSuccess := true // ควรเปลี่ยนให้เป็นการตรวจสอบจากระบบชำระเงินจริง ๆ
 
 	if !paymentSuccess {
-		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Payment failed"})
+		return bookstore.Order{}, checkoutFailed(storeID, bookstore.NewError(bookstore.ErrPaymentFailed, "Payment failed"))
+	}
+
+	// สร้างคำสั่งซื้อและอัปเดตสถานะสินค้าในตะกร้าให้เป็น 'checked_out'
+	order, err := h.bs.CheckoutCart(c.Request.Context(), owner, storeID, bookstore.ContactInfo{
+		Name:    req.Name,
+		Email:   req.Email,
+		Phone:   req.Phone,
+		Address: req.Address,
+	})
+	if err != nil {
+		return bookstore.Order{}, checkoutFailed(storeID, err)
+	}
+	metrics.CheckoutSucceeded(storeID, order.TotalAmount)
+	return order, nil
+}
+
+// checkoutFailed นับการชำระเงินที่ล้มเหลวตามรหัส error แล้วคืน error เดิม
+func checkoutFailed(storeID int, err error) error {
+	_, resp := errorResponse(err)
+	metrics.CheckoutFailed(storeID, resp.Code)
+	return err
+}
+
+// GetAbandonedCarts ดึงรายการตะกร้าที่ถูกทิ้งของร้าน ใช้ since (RFC3339) กำหนดช่วงเวลา ค่าเริ่มต้นคือ 30 วันที่ผ่านมา
+func (h *BookHandlers) GetAbandonedCarts(c *gin.Context) {
+	storeIDStr := c.Param("store_id")
+	storeID, err := strconv.Atoi(storeIDStr)
+	if err != nil {
+		abort(c, badRequest("Invalid store ID"))
 		return
 	}
 
-	// อัปเดตสถานะสินค้าในตะกร้าให้เป็น 'checked_out'
-	err = h.bs.CheckoutCart(c.Request.Context(), storeID)
+	since := time.Now().AddDate(0, 0, -30)
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		since, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			abort(c, badRequest("Invalid since, expected RFC3339 timestamp"))
+			return
+		}
+	}
+
+	events, err := h.bs.GetAbandonedCartEvents(c.Request.Context(), storeID, since)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abort(c, err)
 		return
 	}
 
-	// ส่ง response ว่าการสั่งซื้อเสร็จสมบูรณ์
-	c.JSON(http.StatusOK, gin.H{
-		"message":      "Checkout successful",
-		"store_id":     storeID,
-		"total_amount": totalAmount,
-	})
+	c.JSON(http.StatusOK, gin.H{"store_id": storeID, "since": since, "abandoned_carts": events})
 }
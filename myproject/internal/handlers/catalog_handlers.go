@@ -0,0 +1,181 @@
+// catalog_handlers.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"myproject/internal/bookstore"
+	"myproject/internal/catalogio"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxImportSize ขนาดไฟล์ import สูงสุด (10 MB)
+	maxImportSize = 10 << 20
+	// importTimeout เวลาสูงสุดของการบันทึกสินค้าที่นำเข้า ยาวกว่า timeout ปกติของ request
+	importTimeout = 2 * time.Minute
+	// exportTimeout เวลาสูงสุดของการ export ทั้งร้าน ยาวกว่า timeout ปกติของ request
+	exportTimeout = 5 * time.Minute
+	// exportFlushEvery ส่งข้อมูลที่เขียนแล้วให้ client ทุกๆ จำนวนสินค้านี้
+	exportFlushEvery = 100
+)
+
+// ImportProducts นำเข้าสินค้าของร้านจากไฟล์ CSV หรือ XLSX แบบ upsert ตาม brand และ model
+// รับไฟล์ได้ทั้งแบบ multipart (field "file") หรือส่งเป็น body ตรงๆ พร้อม Content-Type
+// ใส่ dry_run=true เพื่อตรวจสอบและดูผลลัพธ์โดยไม่บันทึก
+func (h *BookHandlers) ImportProducts(c *gin.Context) {
+	report, err := h.importProducts(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"import": report})
+}
+
+// importProducts อ่านไฟล์จาก request แล้วนำเข้าสินค้า ใช้ร่วมกันทั้ง API v1 และ v2
+func (h *BookHandlers) importProducts(c *gin.Context) (bookstore.ImportReport, error) {
+	storeIDStr := c.Param("store_id")
+	storeID, err := strconv.Atoi(storeIDStr)
+	if err != nil {
+		return bookstore.ImportReport{}, badRequest("Invalid store ID")
+	}
+
+	dryRun := false
+	if v := c.Query("dry_run"); v != "" {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			return bookstore.ImportReport{}, badRequest("Invalid dry_run, expected true or false")
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var (
+		body     io.Reader = c.Request.Body
+		filename string
+	)
+	contentType := c.ContentType()
+	if contentType == "multipart/form-data" {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return bookstore.ImportReport{}, badRequest("Missing import file in form field \"file\"")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return bookstore.ImportReport{}, err
+		}
+		defer file.Close()
+		body, filename, contentType = file, fileHeader.Filename, fileHeader.Header.Get("Content-Type")
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = catalogio.DetectFormat(filename, contentType)
+	}
+	if format == "" {
+		return bookstore.ImportReport{}, badRequest("Unknown file format, send a .csv or .xlsx file or set format")
+	}
+
+	rows, err := catalogio.ReadProducts(body, format)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return bookstore.ImportReport{}, badRequest("Import file is larger than 10 MB")
+	}
+	if err != nil {
+		return bookstore.ImportReport{}, err
+	}
+
+	// ไฟล์ใหญ่ใช้เวลานานกว่า timeout ของ request ทั่วไป จึงตั้ง deadline ใหม่เหมือน export
+	// เมื่อเริ่มบันทึกแล้วจะทำจนเสร็จแม้ client ปิดการเชื่อมต่อ เพื่อไม่ให้ผลขึ้นกับว่า client รอนานแค่ไหน
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), importTimeout)
+	defer cancel()
+	return h.bs.ImportProducts(ctx, storeID, rows, dryRun)
+}
+
+// ExportProducts ส่งสินค้าทั้งหมดของร้านเป็นไฟล์ตาม format (csv, jsonl หรือ merchant-xml) แบบ stream
+// merchant-xml เป็น feed สำหรับ Google Merchant Center และมีเฉพาะสินค้าที่ยังขายอยู่
+func (h *BookHandlers) ExportProducts(c *gin.Context) {
+	storeIDStr := c.Param("store_id")
+	storeID, err := strconv.Atoi(storeIDStr)
+	if err != nil {
+		abort(c, badRequest("Invalid store ID"))
+		return
+	}
+
+	format := c.DefaultQuery("format", catalogio.FormatCSV)
+	contentType := catalogio.ContentType(format)
+	if contentType == "" {
+		abort(c, badRequest("Invalid format, expected csv, jsonl or merchant-xml"))
+		return
+	}
+
+	store, err := h.bs.GetStoreInfoByID(c.Request.Context(), storeID)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	// export ร้านใหญ่ใช้เวลานานกว่า timeout ของ request ทั่วไป จึงตั้ง deadline ใหม่
+	// ถ้า client ปิดการเชื่อมต่อ การเขียนจะล้มเหลวและหยุดอ่านจากฐานข้อมูลเอง
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), exportTimeout)
+	defer cancel()
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="store-%d-products.%s"`, storeID, catalogio.FileExtension(format)))
+	c.Status(http.StatusOK)
+
+	w, err := catalogio.NewWriter(c.Writer, format, catalogio.FeedInfo{
+		Title:       store.StoreName,
+		Description: store.Description,
+		BaseURL:     h.baseURL(c),
+		ProductURL:  h.opts.ProductURL,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "export failed", slog.Int("store_id", storeID), slog.String("format", format), slog.Any("error", err))
+		return
+	}
+
+	count := 0
+	err = h.bs.EachProductByStore(ctx, storeID, format == catalogio.FormatMerchant, func(p bookstore.Product) error {
+		if err := w.Write(p); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		// header ถูกส่งไปแล้ว แจ้งสถานะไม่ได้ ทำได้แค่บันทึก log ไฟล์ที่ client ได้รับจะไม่สมบูรณ์
+		slog.ErrorContext(ctx, "export failed", slog.Int("store_id", storeID), slog.String("format", format),
+			slog.Int("products_written", count), slog.Any("error", err))
+		return
+	}
+	c.Writer.Flush()
+}
+
+// baseURL URL ของเซิร์ฟเวอร์สำหรับลิงก์ใน feed ใช้ Options.PublicBaseURL ถ้ากำหนดไว้
+// ไม่อย่างนั้นใช้ host ที่ client เรียกมาโดยตรง ไม่เชื่อ X-Forwarded-Host และ X-Forwarded-Proto
+// เพราะใครก็ใส่มาได้ ถ้าอยู่หลัง reverse proxy ให้กำหนด app.public_base_url
+func (h *BookHandlers) baseURL(c *gin.Context) string {
+	if h.opts.PublicBaseURL != "" {
+		return h.opts.PublicBaseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
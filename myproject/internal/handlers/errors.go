@@ -0,0 +1,202 @@
+// errors.go
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"myproject/internal/bookstore"
+	"myproject/internal/logging"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDHeader header ที่ใช้รับและส่งรหัสของ request
+const requestIDHeader = "X-Request-ID"
+
+// errBadRequest ข้อมูลที่ส่งมาอ่านไม่ได้ เช่น ID ไม่ใช่ตัวเลข หรือไม่ได้ส่งพารามิเตอร์ที่จำเป็น
+var errBadRequest = errors.New("bad request")
+
+// ErrorResponse รูปแบบ JSON ของ error ที่ทุก endpoint ส่งกลับ
+type ErrorResponse struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id"`
+}
+
+// RequestID ใช้ X-Request-ID ที่ client ส่งมา หรือสร้างใหม่ถ้าไม่มี แล้วส่งกลับไปใน response header
+// และใส่ไว้ใน context ของ request เพื่อให้ log ทุกบรรทัดที่เกิดจาก request นี้มี request_id
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 8)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set(requestIDHeader, id)
+		c.Header(requestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// ErrorHandler แปลง error ที่ handler เพิ่มไว้ด้วย c.Error เป็น ErrorResponse
+// error ภายในจะถูกบันทึกลง log แต่ client จะเห็นแค่ข้อความทั่วไป
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		status, resp := errorResponse(err)
+		if status == http.StatusInternalServerError {
+			slog.ErrorContext(c.Request.Context(), "request failed",
+				slog.String("method", c.Request.Method),
+				slog.String("route", c.FullPath()),
+				slog.Any("error", err),
+			)
+		}
+		writeError(c, status, resp)
+	}
+}
+
+// Recovery จับ panic ของ handler บันทึกลง log แล้วตอบเป็น ErrorResponse แทนการปิดการเชื่อมต่อ
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				slog.ErrorContext(c.Request.Context(), "handler panicked",
+					slog.String("method", c.Request.Method),
+					slog.String("route", c.FullPath()),
+					slog.Any("panic", rec),
+				)
+				if c.Writer.Written() {
+					c.Abort()
+					return
+				}
+				status, resp := errorResponse(fmt.Errorf("panic: %v", rec))
+				c.Abort()
+				writeError(c, status, resp)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// RequestLogger บันทึก access log ของทุก request แทน logger ของ gin
+// status 5xx บันทึกที่ระดับ error, 4xx ที่ระดับ warn และที่เหลือที่ระดับ info
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		for _, param := range []string{"store_id", "product_id", "id"} {
+			if v := c.Param(param); v != "" {
+				attrs = append(attrs, slog.String(param, v))
+			}
+		}
+		if apiKey, ok := requestAPIKey(c); ok {
+			attrs = append(attrs, slog.Int("api_key_id", apiKey.ID), slog.String("partner", apiKey.Partner))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// errorResponse หา status code และเนื้อหาของ error แต่ละประเภท
+func errorResponse(err error) (int, ErrorResponse) {
+	var resp ErrorResponse
+	var domainErr *bookstore.Error
+	if errors.As(err, &domainErr) {
+		resp.Message = domainErr.Message
+		resp.Details = domainErr.Details
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errBadRequest):
+		status, resp.Code = http.StatusBadRequest, "bad_request"
+	case errors.Is(err, bookstore.ErrNotFound):
+		status, resp.Code = http.StatusNotFound, "not_found"
+	case errors.Is(err, bookstore.ErrOutOfStock):
+		status, resp.Code = http.StatusConflict, "out_of_stock"
+		resp.Message = bookstore.ErrOutOfStock.Error()
+	case errors.Is(err, bookstore.ErrConflict):
+		status, resp.Code = http.StatusConflict, "conflict"
+	case errors.Is(err, bookstore.ErrValidation):
+		status, resp.Code = http.StatusUnprocessableEntity, "validation_failed"
+	case errors.Is(err, bookstore.ErrPaymentFailed):
+		status, resp.Code = http.StatusPaymentRequired, "payment_failed"
+	case errors.Is(err, bookstore.ErrUnauthorized):
+		status, resp.Code = http.StatusUnauthorized, "unauthorized"
+	case errors.Is(err, bookstore.ErrForbidden):
+		status, resp.Code = http.StatusForbidden, "forbidden"
+	case errors.Is(err, errRateLimited):
+		status, resp.Code = http.StatusTooManyRequests, "rate_limited"
+	case errors.Is(err, context.DeadlineExceeded):
+		status, resp.Code = http.StatusGatewayTimeout, "timeout"
+		resp.Message, resp.Details = "Request timed out", nil
+	default:
+		resp.Code = "internal_error"
+		resp.Message, resp.Details = "Internal server error", nil
+	}
+
+	if resp.Message == "" {
+		resp.Message = http.StatusText(status)
+	}
+	return status, resp
+}
+
+// writeError ส่ง ErrorResponse ในรูปแบบของ API ที่ request นี้เรียก
+// API v2 ห่อ error ไว้ใน {"error": ...} ให้เหมือนกับ response ที่สำเร็จซึ่งอยู่ใน {"data": ...}
+func writeError(c *gin.Context, status int, resp ErrorResponse) {
+	resp.RequestID = c.GetString(requestIDHeader)
+	if c.GetBool(errorEnvelopeKey) {
+		c.JSON(status, errorEnvelope{Error: resp})
+		return
+	}
+	c.JSON(status, resp)
+}
+
+// abort หยุดการทำงานของ handler และส่ง error ให้ ErrorHandler จัดการต่อ
+func abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// badRequest สร้าง error สำหรับข้อมูลที่ส่งมาไม่ถูกต้อง
+func badRequest(message string) error {
+	return bookstore.NewError(errBadRequest, message)
+}
+
+// notFound สร้าง error สำหรับกรณีที่ไม่พบข้อมูล
+func notFound(message string) error {
+	return bookstore.NewError(bookstore.ErrNotFound, message)
+}
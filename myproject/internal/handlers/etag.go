@@ -0,0 +1,83 @@
+// etag.go
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cache-Control ของ endpoint ที่อ่านข้อมูลร้านและสินค้า ข้อมูลเหมือนกันสำหรับทุกคนจึงให้ proxy เก็บได้ (public)
+// รายการสินค้ามีจำนวนคงเหลือที่เปลี่ยนบ่อยจึงเก็บได้สั้นกว่าข้อมูลร้าน หลังหมดอายุ client ถามซ้ำด้วย ETag แล้วได้ 304 ถ้าไม่มีอะไรเปลี่ยน
+const (
+	cacheControlStore   = "public, max-age=300"
+	cacheControlProduct = "public, max-age=60"
+	cacheControlCatalog = "public, max-age=30"
+)
+
+// cacheable การตั้งค่า header ของ response ที่ client เก็บ cache ได้
+type cacheable struct {
+	// CacheControl ค่าของ header Cache-Control
+	CacheControl string
+	// LastModified เวลาที่ข้อมูลเปลี่ยนล่าสุด ถ้าเป็นค่าว่างจะไม่ส่ง Last-Modified และไม่ตรวจ If-Modified-Since
+	// ใช้กับข้อมูลชิ้นเดียวเท่านั้น รายการไม่มีเวลาที่บอกได้ว่าเปลี่ยนเมื่อใด (เช่น สินค้าที่ถูกลบหรือย้ายออกไป)
+	// จึงอาศัย ETag ที่คำนวณจากเนื้อหาอย่างเดียว
+	LastModified time.Time
+}
+
+// jsonWithETag ส่ง JSON พร้อม ETag ที่คำนวณจากเนื้อหา Last-Modified และ Cache-Control
+// ถ้า client ส่ง If-None-Match ที่ตรงกัน หรือ If-Modified-Since ที่ไม่เก่ากว่าข้อมูล จะตอบ 304 โดยไม่ส่งเนื้อหา
+// ทำให้ client ที่ cache ไว้แล้วไม่ต้องดาวน์โหลดซ้ำ
+func jsonWithETag(c *gin.Context, status int, obj interface{}, opts cacheable) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if opts.CacheControl != "" {
+		c.Header("Cache-Control", opts.CacheControl)
+	}
+	// HTTP date ละเอียดถึงวินาที จึงปัดเศษทิ้งก่อนเทียบกับ If-Modified-Since
+	modified := opts.LastModified.UTC().Truncate(time.Second)
+	if !opts.LastModified.IsZero() {
+		c.Header("Last-Modified", modified.Format(http.TimeFormat))
+	}
+
+	if (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) && notModified(c.Request, etag, modified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(status, "application/json; charset=utf-8", body)
+}
+
+// notModified ตรวจ conditional request ตาม RFC 9110: ถ้ามี If-None-Match จะใช้ ETag อย่างเดียวและไม่สนใจ If-Modified-Since
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatch(ifNoneMatch, etag)
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.After(since)
+}
+
+// etagMatch ตรวจว่า If-None-Match มี etag นี้อยู่หรือไม่ (เทียบแบบ weak ตาม RFC 9110)
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+// etag_test.go
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveCached ส่ง body เดิมผ่าน jsonWithETag ด้วย method และ header ที่กำหนด
+func serveCached(t *testing.T, method string, opts cacheable, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(method, "/resource", func(c *gin.Context) {
+		jsonWithETag(c, http.StatusOK, gin.H{"product": gin.H{"id": 1, "name": "Guitar"}}, opts)
+	})
+
+	req := httptest.NewRequest(method, "/resource", nil)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestJSONWithETagConditionalGet(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+	single := cacheable{CacheControl: cacheControlProduct, LastModified: modified}
+	list := cacheable{CacheControl: cacheControlCatalog}
+	etag := serveCached(t, http.MethodGet, single, nil).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("response has no ETag")
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		opts       cacheable
+		header     map[string]string
+		wantStatus int
+	}{
+		{"no validators", http.MethodGet, single, nil, http.StatusOK},
+		{"matching etag", http.MethodGet, single, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"weak etag in a list", http.MethodGet, single, map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"wildcard etag", http.MethodGet, single, map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"stale etag", http.MethodGet, single, map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"head with matching etag", http.MethodHead, single, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"post ignores validators", http.MethodPost, single, map[string]string{"If-None-Match": etag}, http.StatusOK},
+		{"modified since is the same second", http.MethodGet, single, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusNotModified},
+		{"modified since is older", http.MethodGet, single, map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)}, http.StatusOK},
+		{"bad modified since", http.MethodGet, single, map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+		{
+			"stale etag wins over modified since",
+			http.MethodGet, single,
+			map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified.Format(http.TimeFormat)},
+			http.StatusOK,
+		},
+		{"list ignores modified since", http.MethodGet, list, map[string]string{"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{"list with matching etag", http.MethodGet, list, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCached(t, tt.method, tt.opts, tt.header)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %s, want %s", got, etag)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.opts.CacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.opts.CacheControl)
+			}
+			wantModified := ""
+			if !tt.opts.LastModified.IsZero() {
+				wantModified = "Sun, 01 Mar 2026 12:00:00 GMT"
+			}
+			if got := w.Header().Get("Last-Modified"); got != wantModified {
+				t.Errorf("Last-Modified = %q, want %q", got, wantModified)
+			}
+			if tt.wantStatus == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 has a body: %s", w.Body)
+			}
+		})
+	}
+}
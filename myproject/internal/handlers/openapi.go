@@ -0,0 +1,575 @@
+// openapi.go
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"myproject/internal/bookstore"
+	"myproject/internal/catalogio"
+	"myproject/internal/openapi"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// docsPage หน้าเอกสาร API ที่อ่าน /openapi.json แล้วแสดงผล ไม่ต้องโหลดอะไรจากภายนอก
+//
+//go:embed docs.html
+var docsPage []byte
+
+const (
+	// v2ResponsePrefix ชื่อของ error response ใน components ที่ห่อด้วย {"error": ...} สำหรับ API v2
+	v2ResponsePrefix = "V2"
+	// responseRefPrefix ส่วนต้นของ $ref ที่อ้างถึง response ใน components
+	responseRefPrefix = "#/components/responses/"
+)
+
+// cacheControlSpec เอกสารเปลี่ยนเฉพาะตอน deploy ใหม่
+const cacheControlSpec = "public, max-age=300"
+
+// specJSON เอกสาร OpenAPI ที่แปลงเป็น JSON แล้ว สร้างครั้งเดียวตอนมีคนเรียกครั้งแรก
+var specJSON = sync.OnceValues(func() ([]byte, error) {
+	return json.Marshal(OpenAPISpec())
+})
+
+// OpenAPI ส่งเอกสาร OpenAPI ของ API v1
+func OpenAPI(c *gin.Context) {
+	spec, err := specJSON()
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	c.Header("Cache-Control", cacheControlSpec)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+}
+
+// Docs ส่งหน้าเอกสาร API
+func Docs(c *gin.Context) {
+	c.Header("Cache-Control", cacheControlSpec)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+// OpenAPISpec สร้างเอกสาร OpenAPI ของ API v1 schema ของข้อมูลสร้างจาก type จริงใน bookstore และ handlers
+// เส้นทางเขียนไว้เองตาม RegisterRoutes test จะไม่ผ่านถ้าสองที่นี้ไม่ตรงกัน
+func OpenAPISpec() *openapi.Document {
+	d := openapi.New(openapi.Info{
+		Title:   "Music Store API",
+		Version: "1.0.0",
+		Description: "Public catalog, cart and checkout API of the music store, plus store owner endpoints for partners.\n\n" +
+			"Every error has a stable `code` and the `request_id` to quote when reporting problems. " +
+			"v1 returns the error body (`ErrorResponse`) as is; v2 wraps successful responses in `data` (lists also have `meta`) and errors in `error`.\n\n" +
+			"v1 is deprecated in favour of v2. Its responses may carry `Deprecation`, `Sunset` and `Link: rel=\"successor-version\"` headers.",
+	})
+	d.Servers = []openapi.Server{{URL: "/api"}}
+	d.Tags = []openapi.Tag{
+		{Name: "stores", Description: "Store information"},
+		{Name: "products", Description: "Product catalog"},
+		{Name: "search", Description: "Product search"},
+		{Name: "cart", Description: "Shopping cart of a guest (cart token) or a signed-in user (X-User-ID)"},
+		{Name: "checkout", Description: "Turn a cart into an order"},
+		{Name: "admin", Description: "Store owner endpoints, require a partner API key"},
+	}
+	d.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: apiKeyHeader,
+		Description: "Partner API key (starts with `" + bookstore.APIKeyPrefix + "`).",
+	}
+	d.Components.SecuritySchemes["bearerAuth"] = &openapi.SecurityScheme{
+		Type: "http", Scheme: "bearer",
+		Description: "Partner API key sent as `Authorization: Bearer " + bookstore.APIKeyPrefix + "...`. Bearer tokens without this prefix are ignored.",
+	}
+
+	errorSchema := d.SchemaOf(ErrorResponse{})
+	envelopeSchema := d.SchemaOf(errorEnvelope{})
+	for name, desc := range map[string]string{
+		"BadRequest":       "Malformed request, e.g. a non-numeric ID or a missing parameter (`bad_request`).",
+		"Unauthorized":     "Missing, invalid or revoked API key (`unauthorized`).",
+		"Forbidden":        "The API key lacks the required scope or is bound to another store (`forbidden`).",
+		"NotFound":         "The store, product or cart item does not exist (`not_found`).",
+		"Conflict":         "Not enough stock (`out_of_stock`) or a conflicting update (`conflict`).",
+		"ValidationFailed": "The data is well-formed but invalid; `details.fields` lists the fields (`validation_failed`).",
+		"TooManyRequests":  "Rate limit of the route group exceeded (`rate_limited`). Retry after the `Retry-After` seconds.",
+		"InternalError":    "Unexpected server error (`internal_error`).",
+		"Timeout":          "The request took too long (`timeout`).",
+	} {
+		d.Components.Responses[name] = &openapi.Response{Description: desc, Content: openapi.JSON(errorSchema)}
+		d.Components.Responses[v2ResponsePrefix+name] = &openapi.Response{Description: desc, Content: openapi.JSON(envelopeSchema)}
+	}
+	retryAfter := map[string]*openapi.Header{
+		"Retry-After": {Description: "Seconds until a request is allowed again", Schema: openapi.Integer("")},
+	}
+	d.Components.Responses["TooManyRequests"].Headers = retryAfter
+	d.Components.Responses[v2ResponsePrefix+"TooManyRequests"].Headers = retryAfter
+	d.Components.Responses["NotModified"] = &openapi.Response{
+		Description: "The cached copy is still current (`If-None-Match` or `If-Modified-Since` matched).",
+	}
+
+	specV1(d)
+	specV2(d)
+	return d
+}
+
+// specV1 เส้นทางของ API v1 ตาม registerV1
+func specV1(d *openapi.Document) {
+	errorSchema := d.SchemaOf(ErrorResponse{})
+	storeInfo := d.SchemaOf(bookstore.StoreInfo{})
+	product := d.SchemaOf(bookstore.Product{})
+	products := openapi.ArrayOf(product)
+	cart := d.SchemaOf(bookstore.Cart{})
+	storeID := openapi.Integer("Store ID")
+
+	// ----- stores -----
+	addOperation(d, "GET", "/v1/AllStoreInfo", "catalog", &openapi.Operation{
+		OperationID: "v1ListStores", Summary: "List all stores", Tags: []string{"stores"},
+		Responses: cachedResponses("All stores", openapi.Object(map[string]*openapi.Schema{"store_info": openapi.ArrayOf(storeInfo)}), cacheControlStore),
+	})
+	addOperation(d, "GET", "/v1/store/{store_id}", "catalog", &openapi.Operation{
+		OperationID: "v1GetStore", Summary: "Get a store", Tags: []string{"stores"},
+		Parameters: []*openapi.Parameter{pathID("store_id", "Store ID")},
+		Responses:  withNotFound(cachedResponses("The store", storeInfo, cacheControlStore)),
+	})
+
+	// ----- products -----
+	addOperation(d, "GET", "/v1/product/{store_id}", "catalog", &openapi.Operation{
+		OperationID: "v1ListStoreProducts", Summary: "List products of a store", Tags: []string{"products"},
+		Parameters: []*openapi.Parameter{pathID("store_id", "Store ID")},
+		Responses: withNotFound(cachedResponses("Products of the store",
+			openapi.Object(map[string]*openapi.Schema{"store_id": storeID, "products": products}), cacheControlCatalog)),
+	})
+	addOperation(d, "GET", "/v1/newproduct/{store_id}", "catalog", &openapi.Operation{
+		OperationID: "v1ListNewStoreProducts", Summary: "List the newest products of a store", Tags: []string{"products"},
+		Parameters: []*openapi.Parameter{pathID("store_id", "Store ID")},
+		Responses: withNotFound(cachedResponses("Newest products of the store",
+			openapi.Object(map[string]*openapi.Schema{"store_id": storeID, "products": products}), cacheControlCatalog)),
+	})
+	addOperation(d, "GET", "/v1/products/{id}", "catalog", &openapi.Operation{
+		OperationID: "v1GetProduct", Summary: "Get a product", Tags: []string{"products"},
+		Parameters: []*openapi.Parameter{pathID("id", "Product ID")},
+		Responses:  withNotFound(withLastModified(cachedResponses("The product", openapi.Object(map[string]*openapi.Schema{"product": product}), cacheControlProduct))),
+	})
+	addOperation(d, "GET", "/v1/Allproduct/{store_id}/sort", "catalog", &openapi.Operation{
+		OperationID: "v1ListStoreProductsByPrice", Summary: "List products of a store sorted by price", Tags: []string{"products"},
+		Parameters: []*openapi.Parameter{
+			pathID("store_id", "Store ID"),
+			queryParam("sortOrder", "Price order; unknown values sort ascending", false, openapi.String("").WithEnum("asc", "desc").WithDefault("asc")),
+		},
+		Responses: withNotFound(cachedResponses("Products of the store",
+			openapi.Object(map[string]*openapi.Schema{"store_id": storeID, "products": products}), cacheControlCatalog)),
+	})
+	addOperation(d, "GET", "/v1/{store_id}/by-category", "catalog", &openapi.Operation{
+		OperationID: "v1ListStoreProductsByCategory", Summary: "List products of a store in a category", Tags: []string{"products"},
+		Parameters: []*openapi.Parameter{
+			pathID("store_id", "Store ID"),
+			queryParam("category", "Category name", true, openapi.String("")),
+		},
+		Responses: withNotFound(cachedResponses("Products of the store in the category",
+			openapi.Object(map[string]*openapi.Schema{"store_id": storeID, "category": openapi.String(""), "products": products}), cacheControlCatalog)),
+	})
+	addOperation(d, "GET", "/v1/category", "catalog", &openapi.Operation{
+		OperationID: "v1ListProductsByCategory", Summary: "List products of every store in a category", Tags: []string{"products"},
+		Parameters: []*openapi.Parameter{queryParam("category", "Category name", true, openapi.String(""))},
+		Responses: withNotFound(cachedResponses("Products in the category",
+			openapi.Object(map[string]*openapi.Schema{"category": openapi.String(""), "products": products}), cacheControlCatalog)),
+	})
+	addOperation(d, "GET", "/v1/all-guitars", "catalog", &openapi.Operation{
+		OperationID: "v1ListGuitars", Summary: "List guitars of every store", Tags: []string{"products"},
+		Description: "Products whose name contains \"กีตาร์\" (guitar).",
+		Responses: withNotFound(withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("All guitars", openapi.Object(map[string]*openapi.Schema{"guitars": products})),
+		})),
+	})
+
+	// ----- search -----
+	addOperation(d, "GET", "/v1/searchproducts", "search", &openapi.Operation{
+		OperationID: "v1SearchProducts", Summary: "Search products of every store by name", Tags: []string{"search"},
+		Parameters: []*openapi.Parameter{queryParam("product_name", "Part of the product name (case-insensitive)", true, openapi.String(""))},
+		Responses:  cachedResponses("Matching products", openapi.Object(map[string]*openapi.Schema{"products": products}), cacheControlCatalog),
+	})
+	addOperation(d, "GET", "/v1/{store_id}/search", "search", &openapi.Operation{
+		OperationID: "v1SearchStoreProducts", Summary: "Search products of a store by name", Tags: []string{"search"},
+		Parameters: []*openapi.Parameter{
+			pathID("store_id", "Store ID"),
+			queryParam("product_name", "Part of the product name (case-insensitive)", true, openapi.String("")),
+		},
+		Responses: cachedResponses("Matching products of the store",
+			openapi.Object(map[string]*openapi.Schema{"store_id": storeID, "products": products}), cacheControlCatalog),
+	})
+
+	// ----- cart -----
+	addOperation(d, "POST", "/v1/store/{store_id}/product/{product_id}/add_to_cart", "cart", &openapi.Operation{
+		OperationID: "v1AddToCart", Summary: "Add a product to the cart", Tags: []string{"cart"},
+		Description: "Guests without a cart get a new cart token in the `" + cartTokenCookie + "` cookie and the `" + cartTokenHeader + "` header. " +
+			"Send it back on later cart requests.",
+		Parameters:  append([]*openapi.Parameter{pathID("store_id", "Store ID"), pathID("product_id", "Product ID")}, cartOwnerParams()...),
+		RequestBody: &openapi.RequestBody{Content: jsonOrForm(d.SchemaOf(addToCartRequest{}))},
+		Responses: withCartErrors(withErrors(map[string]*openapi.Response{
+			"200": {
+				Description: "The product was added",
+				Headers: map[string]*openapi.Header{
+					cartTokenHeader: {Description: "New cart token, only sent when a guest cart was created", Schema: openapi.String("")},
+				},
+				Content: openapi.JSON(openapi.Object(map[string]*openapi.Schema{
+					"message":    openapi.String(""),
+					"store_id":   storeID,
+					"product_id": openapi.Integer("Product ID"),
+					"quantity":   openapi.Integer("Quantity added"),
+					"cart_owner": d.SchemaOf(bookstore.CartOwner{}),
+				})),
+			},
+		})),
+	})
+	addOperation(d, "GET", "/v1/cart/{store_id}", "cart", &openapi.Operation{
+		OperationID: "v1GetCart", Summary: "Get the cart", Tags: []string{"cart"},
+		Description: "Returns an empty cart when the request has no cart owner yet.",
+		Parameters:  append([]*openapi.Parameter{pathID("store_id", "Store ID")}, cartOwnerParams()...),
+		Responses: withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("The cart with line totals and subtotal", openapi.Object(map[string]*openapi.Schema{"cart": cart})),
+		}),
+	})
+	addOperation(d, "PATCH", "/v1/cart/{store_id}/items/{item_id}", "cart", &openapi.Operation{
+		OperationID: "v1UpdateCartItem", Summary: "Set the quantity of a cart item", Tags: []string{"cart"},
+		Description: "A quantity of 0 removes the item.",
+		Parameters:  append([]*openapi.Parameter{pathID("store_id", "Store ID"), pathID("item_id", "Cart item ID (`CartItem.id`)")}, cartOwnerParams()...),
+		RequestBody: &openapi.RequestBody{Required: true, Content: jsonOrForm(d.SchemaOf(updateCartItemRequest{}))},
+		Responses: withCartErrors(withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("The updated cart", openapi.Object(map[string]*openapi.Schema{"cart": cart})),
+		})),
+	})
+	addOperation(d, "DELETE", "/v1/store/{store_id}/product/{product_id}/remove_from_cart", "cart", &openapi.Operation{
+		OperationID: "v1RemoveFromCart", Summary: "Remove a product from the cart", Tags: []string{"cart"},
+		Parameters: append([]*openapi.Parameter{pathID("store_id", "Store ID"), pathID("product_id", "Product ID")}, cartOwnerParams()...),
+		Responses: withNotFound(withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("The product was removed", openapi.Object(map[string]*openapi.Schema{
+				"message":    openapi.String(""),
+				"store_id":   storeID,
+				"product_id": openapi.Integer("Product ID"),
+			})),
+		})),
+	})
+
+	// ----- checkout -----
+	addOperation(d, "POST", "/v1/checkout/{store_id}", "checkout", &openapi.Operation{
+		OperationID: "v1Checkout", Summary: "Check out the cart", Tags: []string{"checkout"},
+		Description: "Creates an order from every item in the cart of the store. Guests must give a name and email.",
+		Parameters:  append([]*openapi.Parameter{pathID("store_id", "Store ID")}, cartOwnerParams()...),
+		RequestBody: &openapi.RequestBody{Content: jsonOrForm(d.SchemaOf(checkoutRequest{}))},
+		Responses: withCartErrors(withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("The order was created", openapi.Object(map[string]*openapi.Schema{
+				"message":      openapi.String(""),
+				"store_id":     storeID,
+				"order_id":     openapi.Integer("Order ID"),
+				"total_amount": openapi.Number("Total amount of the order"),
+			})),
+			"402": jsonResponse("Payment failed (`payment_failed`)", errorSchema),
+		})),
+	})
+
+	// ----- admin -----
+	addOperation(d, "POST", "/v1/store/{store_id}/products/import", "admin",
+		importOperation("v1ImportProducts", openapi.Object(map[string]*openapi.Schema{"import": d.SchemaOf(bookstore.ImportReport{})})))
+	addOperation(d, "GET", "/v1/store/{store_id}/products/export", "admin", exportOperation("v1ExportProducts"))
+	addOperation(d, "GET", "/v1/store/{store_id}/abandoned-carts", "admin", &openapi.Operation{
+		OperationID: "v1ListAbandonedCarts", Summary: "List abandoned carts of a store", Tags: []string{"admin"},
+		Description:   "Cart items that expired without checkout, for following up with customers.",
+		RequiredScope: bookstore.ScopeOrdersRead,
+		Parameters:    []*openapi.Parameter{pathID("store_id", "Store ID"), sinceParam()},
+		Responses: withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("Abandoned cart items", openapi.Object(map[string]*openapi.Schema{
+				"store_id":        storeID,
+				"since":           openapi.String("").WithFormat("date-time"),
+				"abandoned_carts": openapi.ArrayOf(d.SchemaOf(bookstore.AbandonedCartEvent{})),
+			})),
+		}),
+	})
+}
+
+// specV2 เส้นทางของ API v2 ตาม registerV2
+func specV2(d *openapi.Document) {
+	storeInfo := d.SchemaOf(bookstore.StoreInfo{})
+	product := d.SchemaOf(bookstore.Product{})
+	cart := d.SchemaOf(bookstore.Cart{})
+	meta := d.SchemaOf(listMeta{})
+	dataOf := func(schema *openapi.Schema) *openapi.Schema {
+		return openapi.Object(map[string]*openapi.Schema{"data": schema})
+	}
+	listOf := func(schema *openapi.Schema) *openapi.Schema {
+		return openapi.Object(map[string]*openapi.Schema{"data": openapi.ArrayOf(schema), "meta": meta})
+	}
+	productFilters := []*openapi.Parameter{
+		queryParam("q", "Part of the product name (case-insensitive). Requests with q count against the `search` rate limit", false, openapi.String("")),
+		queryParam("category", "Category name", false, openapi.String("")),
+		queryParam("sort", "Sort order; `-price` is the most expensive first. Unsorted when empty", false,
+			openapi.String("").WithEnum(stringsToValues(productSortNames)...)),
+	}
+
+	// ----- stores -----
+	addOperation(d, "GET", "/v2/stores", "catalog", &openapi.Operation{
+		OperationID: "listStores", Summary: "List stores", Tags: []string{"stores"},
+		Responses: cachedResponses("All stores", listOf(storeInfo), cacheControlStore),
+	})
+	addOperation(d, "GET", "/v2/stores/{store_id}", "catalog", &openapi.Operation{
+		OperationID: "getStore", Summary: "Get a store", Tags: []string{"stores"},
+		Parameters: []*openapi.Parameter{pathID("store_id", "Store ID")},
+		Responses:  withNotFound(cachedResponses("The store", dataOf(storeInfo), cacheControlStore)),
+	})
+
+	// ----- products -----
+	addOperation(d, "GET", "/v2/stores/{store_id}/products", "catalog", &openapi.Operation{
+		OperationID: "listStoreProducts", Summary: "List products of a store", Tags: []string{"products"},
+		Description: "Filters combine. A store without matching products gives an empty list.",
+		Parameters:  append([]*openapi.Parameter{pathID("store_id", "Store ID")}, productFilters...),
+		Responses:   withNotFound(cachedResponses("Products of the store", listOf(product), cacheControlCatalog)),
+	})
+	addOperation(d, "GET", "/v2/products", "catalog", &openapi.Operation{
+		OperationID: "listProducts", Summary: "Search products of every store", Tags: []string{"products"},
+		Description: "Requires `q` or `category`; use `/v2/stores/{store_id}/products` to list a whole store.",
+		Parameters:  productFilters,
+		Responses:   cachedResponses("Matching products", listOf(product), cacheControlCatalog),
+	})
+	addOperation(d, "GET", "/v2/products/{product_id}", "catalog", &openapi.Operation{
+		OperationID: "getProduct", Summary: "Get a product", Tags: []string{"products"},
+		Parameters: []*openapi.Parameter{pathID("product_id", "Product ID")},
+		Responses:  withNotFound(withLastModified(cachedResponses("The product", dataOf(product), cacheControlProduct))),
+	})
+
+	// ----- cart -----
+	cartPerStore := "A cart belongs to one owner in one store, so the store ID is the cart ID."
+	addOperation(d, "GET", "/v2/carts/{store_id}", "cart", &openapi.Operation{
+		OperationID: "getCart", Summary: "Get a cart", Tags: []string{"cart"},
+		Description: cartPerStore + " Returns an empty cart when the request has no cart owner yet.",
+		Parameters:  append([]*openapi.Parameter{pathID("store_id", "Store ID")}, cartOwnerParams()...),
+		Responses: withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("The cart with line totals and subtotal", dataOf(cart)),
+		}),
+	})
+	addOperation(d, "POST", "/v2/carts/{store_id}/items", "cart", &openapi.Operation{
+		OperationID: "addCartItem", Summary: "Add a product to a cart", Tags: []string{"cart"},
+		Description: cartPerStore + " Adding a product already in the cart increases its quantity. " +
+			"Guests without a cart get a new cart token in the `" + cartTokenCookie + "` cookie and the `" + cartTokenHeader + "` header.",
+		Parameters:  append([]*openapi.Parameter{pathID("store_id", "Store ID")}, cartOwnerParams()...),
+		RequestBody: &openapi.RequestBody{Required: true, Content: jsonOrForm(d.SchemaOf(addCartItemRequest{}))},
+		Responses: withCartErrors(withErrors(map[string]*openapi.Response{
+			"201": {
+				Description: "The updated cart",
+				Headers: map[string]*openapi.Header{
+					cartTokenHeader: {Description: "New cart token, only sent when a guest cart was created", Schema: openapi.String("")},
+				},
+				Content: openapi.JSON(dataOf(cart)),
+			},
+		})),
+	})
+	addOperation(d, "PATCH", "/v2/carts/{store_id}/items/{item_id}", "cart", &openapi.Operation{
+		OperationID: "updateCartItem", Summary: "Set the quantity of a cart item", Tags: []string{"cart"},
+		Description: "A quantity of 0 removes the item.",
+		Parameters:  append([]*openapi.Parameter{pathID("store_id", "Store ID"), pathID("item_id", "Cart item ID (`CartItem.id`)")}, cartOwnerParams()...),
+		RequestBody: &openapi.RequestBody{Required: true, Content: jsonOrForm(d.SchemaOf(updateCartItemRequest{}))},
+		Responses: withCartErrors(withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("The updated cart", dataOf(cart)),
+		})),
+	})
+	addOperation(d, "DELETE", "/v2/carts/{store_id}/items/{item_id}", "cart", &openapi.Operation{
+		OperationID: "removeCartItem", Summary: "Remove an item from a cart", Tags: []string{"cart"},
+		Parameters: append([]*openapi.Parameter{pathID("store_id", "Store ID"), pathID("item_id", "Cart item ID (`CartItem.id`)")}, cartOwnerParams()...),
+		Responses: withNotFound(withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("The remaining cart", dataOf(cart)),
+		})),
+	})
+
+	// ----- checkout -----
+	addOperation(d, "POST", "/v2/orders", "checkout", &openapi.Operation{
+		OperationID: "createOrder", Summary: "Check out a cart", Tags: []string{"checkout"},
+		Description: "Creates an order from every item in the cart of `store_id`. Guests must give a name and email.",
+		Parameters:  cartOwnerParams(),
+		RequestBody: &openapi.RequestBody{Required: true, Content: jsonOrForm(d.SchemaOf(createOrderRequest{}))},
+		Responses: withCartErrors(withErrors(map[string]*openapi.Response{
+			"201": jsonResponse("The order", dataOf(d.SchemaOf(bookstore.Order{}))),
+			"402": jsonResponse("Payment failed (`payment_failed`)", d.SchemaOf(errorEnvelope{})),
+		})),
+	})
+
+	// ----- admin -----
+	addOperation(d, "POST", "/v2/stores/{store_id}/products/import", "admin",
+		importOperation("importProducts", dataOf(d.SchemaOf(bookstore.ImportReport{}))))
+	addOperation(d, "GET", "/v2/stores/{store_id}/products/export", "admin", exportOperation("exportProducts"))
+	addOperation(d, "GET", "/v2/stores/{store_id}/abandoned-carts", "admin", &openapi.Operation{
+		OperationID: "listAbandonedCarts", Summary: "List abandoned carts of a store", Tags: []string{"admin"},
+		Description:   "Cart items that expired without checkout, for following up with customers.",
+		RequiredScope: bookstore.ScopeOrdersRead,
+		Parameters:    []*openapi.Parameter{pathID("store_id", "Store ID"), sinceParam()},
+		Responses: withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("Abandoned cart items", listOf(d.SchemaOf(bookstore.AbandonedCartEvent{}))),
+		}),
+	})
+}
+
+// importOperation การนำเข้าสินค้า v1 และ v2 ต่างกันแค่รูปแบบของรายงานที่ส่งกลับ
+func importOperation(id string, report *openapi.Schema) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id, Summary: "Import products from CSV or XLSX", Tags: []string{"admin"},
+		Description:   "Upserts products by brand and model. The file is at most 10 MB. Rows are reported one by one; nothing is saved if any row is invalid.",
+		RequiredScope: bookstore.ScopeInventoryWrite,
+		Parameters: []*openapi.Parameter{
+			pathID("store_id", "Store ID"),
+			queryParam("dry_run", "Validate and report without saving", false, openapi.Boolean("").WithDefault(false)),
+			queryParam("format", "File format; detected from the file name or Content-Type when empty", false,
+				openapi.String("").WithEnum(catalogio.FormatCSV, catalogio.FormatXLSX)),
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"multipart/form-data": {Schema: openapi.Object(map[string]*openapi.Schema{
+				"file": openapi.String("The import file").WithFormat("binary"),
+			})},
+			"text/csv": {Schema: openapi.String("").WithFormat("binary")},
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": {Schema: openapi.String("").WithFormat("binary")},
+		}},
+		Responses: withNotFound(withErrors(map[string]*openapi.Response{
+			"200": jsonResponse("Import report", report),
+			"422": openapi.ResponseRef("ValidationFailed"),
+		})),
+	}
+}
+
+// exportOperation การส่งออกสินค้า เป็นไฟล์เหมือนกันทั้ง v1 และ v2
+func exportOperation(id string) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id, Summary: "Export every product of a store", Tags: []string{"admin"},
+		Description:   "Streams the file. `merchant-xml` is a Google Merchant Center feed that only has products on sale.",
+		RequiredScope: bookstore.ScopeCatalogRead,
+		Parameters: []*openapi.Parameter{
+			pathID("store_id", "Store ID"),
+			queryParam("format", "File format", false,
+				openapi.String("").WithEnum(catalogio.FormatCSV, catalogio.FormatJSONL, catalogio.FormatMerchant).WithDefault(catalogio.FormatCSV)),
+		},
+		Responses: withNotFound(withErrors(map[string]*openapi.Response{
+			"200": {
+				Description: "The export file",
+				Content: map[string]openapi.MediaType{
+					catalogio.ContentType(catalogio.FormatCSV):      {Schema: openapi.String("").WithFormat("binary")},
+					catalogio.ContentType(catalogio.FormatJSONL):    {Schema: openapi.String("").WithFormat("binary")},
+					catalogio.ContentType(catalogio.FormatMerchant): {Schema: openapi.String("").WithFormat("binary")},
+				},
+			},
+		})),
+	}
+}
+
+func sinceParam() *openapi.Parameter {
+	return queryParam("since", "Only carts abandoned after this time; defaults to 30 days ago", false, openapi.String("").WithFormat("date-time"))
+}
+
+func stringsToValues(list []string) []interface{} {
+	values := make([]interface{}, len(list))
+	for i, s := range list {
+		values[i] = s
+	}
+	return values
+}
+
+// addOperation เพิ่ม operation ที่อยู่ในกลุ่ม rate limit group พร้อมวิธียืนยันตัวตนและ error ที่ทุกเส้นทางอาจตอบ
+// operation ของ v2 อ้างถึง error ในรูปแบบ {"error": ...} แทน และ operation ของ v1 ถูกทำเครื่องหมายว่าเลิกใช้แล้ว
+func addOperation(d *openapi.Document, method, path, group string, op *openapi.Operation) {
+	op.RateLimitGroup = group
+	keyAuth := []openapi.SecurityRequirement{{"apiKey": {}}, {"bearerAuth": {}}}
+	switch {
+	case op.RequiredScope != "":
+		op.Security = keyAuth
+		op.Description = joinLines(op.Description, "Requires an API key with the `"+op.RequiredScope+"` scope.")
+		op.Responses["403"] = openapi.ResponseRef("Forbidden")
+	case group == "catalog" || group == "search":
+		// เส้นทางสาธารณะ แต่ถ้าส่ง key มา key นั้นต้องมี scope catalog:read
+		op.Security = append([]openapi.SecurityRequirement{{}}, keyAuth...)
+		op.Description = joinLines(op.Description, "Public. An API key, if sent, must have the `"+bookstore.ScopeCatalogRead+"` scope.")
+		op.Responses["403"] = openapi.ResponseRef("Forbidden")
+	}
+	// ทุกเส้นทางตรวจ API key ที่ส่งมา key ที่ผิดได้ 401 เสมอ
+	op.Responses["401"] = openapi.ResponseRef("Unauthorized")
+	op.Responses["429"] = openapi.ResponseRef("TooManyRequests")
+
+	if strings.HasPrefix(path, "/v2/") {
+		for _, resp := range op.Responses {
+			if name := strings.TrimPrefix(resp.Ref, responseRefPrefix); name != resp.Ref && name != "NotModified" {
+				resp.Ref = responseRefPrefix + v2ResponsePrefix + name
+			}
+		}
+	} else {
+		op.Deprecated = true
+	}
+	d.Add(method, path, op)
+}
+
+func joinLines(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "\n\n" + b
+}
+
+// pathID พารามิเตอร์ที่เป็นรหัสใน path
+func pathID(name, description string) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "path", Description: description, Required: true, Schema: openapi.Integer("")}
+}
+
+func queryParam(name, description string, required bool, schema *openapi.Schema) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "query", Description: description, Required: required, Schema: schema}
+}
+
+// cartOwnerParams header ที่บอกว่าตะกร้าเป็นของใคร (ดู cartOwner)
+func cartOwnerParams() []*openapi.Parameter {
+	return []*openapi.Parameter{
+		{Name: cartTokenHeader, In: "header", Schema: openapi.String(""),
+			Description: "Guest cart token, for clients that do not keep the `" + cartTokenCookie + "` cookie"},
+		{Name: userIDHeader, In: "header", Schema: openapi.Integer(""),
+			Description: "Signed-in user, set by the authentication proxy. Ignored unless the request comes directly from one of `app.auth_proxies`. A guest cart in the same request is merged into the user's cart"},
+	}
+}
+
+func jsonResponse(description string, schema *openapi.Schema) *openapi.Response {
+	return &openapi.Response{Description: description, Content: openapi.JSON(schema)}
+}
+
+func jsonOrForm(schema *openapi.Schema) map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{
+		"application/json":                  {Schema: schema},
+		"application/x-www-form-urlencoded": {Schema: schema},
+	}
+}
+
+// cachedResponses ผลลัพธ์ของ endpoint ที่ใช้ jsonWithETag
+func cachedResponses(description string, schema *openapi.Schema, cacheControl string) map[string]*openapi.Response {
+	resp := jsonResponse(description, schema)
+	resp.Headers = map[string]*openapi.Header{
+		"ETag":          {Description: "Send back in `If-None-Match` to get 304 when nothing changed", Schema: openapi.String("")},
+		"Cache-Control": {Description: "`" + cacheControl + "`", Schema: openapi.String("")},
+	}
+	return withErrors(map[string]*openapi.Response{
+		"200": resp,
+		"304": openapi.ResponseRef("NotModified"),
+	})
+}
+
+// withErrors เพิ่ม error ที่ทุก endpoint อาจตอบ
+func withErrors(responses map[string]*openapi.Response) map[string]*openapi.Response {
+	responses["400"] = openapi.ResponseRef("BadRequest")
+	responses["500"] = openapi.ResponseRef("InternalError")
+	responses["504"] = openapi.ResponseRef("Timeout")
+	return responses
+}
+
+// withLastModified เพิ่ม Last-Modified ให้ผลลัพธ์ของ endpoint ที่ส่งข้อมูลชิ้นเดียว
+func withLastModified(responses map[string]*openapi.Response) map[string]*openapi.Response {
+	responses["200"].Headers["Last-Modified"] = &openapi.Header{Description: "`updated_at` of the product; send back in `If-Modified-Since`", Schema: openapi.String("")}
+	return responses
+}
+
+func withNotFound(responses map[string]*openapi.Response) map[string]*openapi.Response {
+	responses["404"] = openapi.ResponseRef("NotFound")
+	return responses
+}
+
+// withCartErrors error ของการแก้ตะกร้า: ไม่พบสินค้า สต็อกไม่พอ หรือสินค้าเลิกขายแล้ว
+func withCartErrors(responses map[string]*openapi.Response) map[string]*openapi.Response {
+	responses["404"] = openapi.ResponseRef("NotFound")
+	responses["409"] = openapi.ResponseRef("Conflict")
+	responses["422"] = openapi.ResponseRef("ValidationFailed")
+	return responses
+}
@@ -0,0 +1,142 @@
+// openapi_test.go
+package handlers
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const apiPrefix = "/api"
+
+var ginParam = regexp.MustCompile(`[:*]([^/]+)`)
+var specParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// registeredRoutes คืน "METHOD path" ของทุกเส้นทางที่ RegisterRoutes ลงทะเบียนไว้ ในรูปแบบ path ของ OpenAPI เทียบกับ /api
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterRoutes(r, NewBookHandlers(nil, Options{}), NewRateLimiter(nil, nil), Deprecation{})
+
+	var routes []string
+	for _, route := range r.Routes() {
+		path, ok := strings.CutPrefix(route.Path, apiPrefix)
+		if !ok {
+			t.Errorf("route %s %s is outside %s", route.Method, route.Path, apiPrefix)
+			continue
+		}
+		routes = append(routes, route.Method+" "+ginParam.ReplaceAllString(path, "{$1}"))
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	spec := OpenAPISpec()
+	if len(spec.Servers) != 1 || spec.Servers[0].URL != apiPrefix {
+		t.Fatalf("servers = %+v, want a single %s", spec.Servers, apiPrefix)
+	}
+
+	routes := registeredRoutes(t)
+	documented := spec.Operations()
+
+	inSpec := make(map[string]bool, len(documented))
+	for _, op := range documented {
+		inSpec[op] = true
+	}
+	inRouter := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		inRouter[route] = true
+		if !inSpec[route] {
+			t.Errorf("route %s is registered but missing from OpenAPISpec", route)
+		}
+	}
+	for _, op := range documented {
+		if !inRouter[op] {
+			t.Errorf("OpenAPISpec documents %s but RegisterRoutes does not register it", op)
+		}
+	}
+}
+
+func TestOpenAPISpecOperations(t *testing.T) {
+	spec := OpenAPISpec()
+	ids := make(map[string]string)
+	for path, item := range spec.Paths {
+		want := specParam.FindAllStringSubmatch(path, -1)
+		for method, op := range *item {
+			name := strings.ToUpper(method) + " " + path
+			if op.OperationID == "" {
+				t.Errorf("%s has no operationId", name)
+			} else if other, dup := ids[op.OperationID]; dup {
+				t.Errorf("%s and %s share operationId %q", name, other, op.OperationID)
+			}
+			ids[op.OperationID] = name
+
+			if op.Responses["200"] == nil && op.Responses["201"] == nil {
+				t.Errorf("%s does not document its success response", name)
+			}
+
+			var got []string
+			for _, p := range op.Parameters {
+				if p.In != "path" {
+					continue
+				}
+				got = append(got, p.Name)
+				if !p.Required {
+					t.Errorf("%s: path parameter %s must be required", name, p.Name)
+				}
+			}
+			if len(got) != len(want) {
+				t.Errorf("%s: path parameters %v do not match the path", name, got)
+				continue
+			}
+			for i, m := range want {
+				if got[i] != m[1] {
+					t.Errorf("%s: path parameter %d is %s, want %s", name, i, got[i], m[1])
+				}
+			}
+		}
+	}
+}
+
+// TestOpenAPISpecRefs ตรวจว่าทุก $ref ในเอกสารชี้ไปยังสิ่งที่มีอยู่จริง
+func TestOpenAPISpecRefs(t *testing.T) {
+	body, err := json.Marshal(OpenAPISpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				var target interface{} = doc
+				for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]interface{})
+					target = m[key]
+				}
+				if target == nil {
+					t.Errorf("$ref %s does not resolve", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
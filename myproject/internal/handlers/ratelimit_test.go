@@ -0,0 +1,135 @@
+// ratelimit_test.go
+package handlers
+
+import (
+	"encoding/json"
+	"myproject/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitedRouter router ที่มีเส้นทาง /limited ในกลุ่ม "test" ซึ่งรับได้ 2 request ต่อนาที
+// ผ่าน TrustedUser ที่เชื่อ X-User-ID จาก 10.0.0.1 เท่านั้น
+func rateLimitedRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	trustedUser, err := TrustedUser([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := NewRateLimiter(ratelimit.NewMemory(), map[string]ratelimit.Limit{"test": {Rate: 2.0 / 60, Burst: 2}})
+
+	r := gin.New()
+	r.Use(ErrorHandler(), trustedUser)
+	r.GET("/limited", limiter.Group("test"), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/unlimited", limiter.Group("other"), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return r
+}
+
+// limitedRequest request จาก remote (IP:port) พร้อม X-User-ID ถ้า userID ไม่ว่าง
+func limitedRequest(path, remote, userID string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remote
+	if userID != "" {
+		req.Header.Set(userIDHeader, userID)
+	}
+	return req
+}
+
+func TestRateLimiterGroupHeaders(t *testing.T) {
+	r := rateLimitedRouter(t)
+
+	tests := []struct {
+		wantStatus    int
+		wantRemaining string
+		wantRetry     string
+	}{
+		{http.StatusNoContent, "1", ""},
+		{http.StatusNoContent, "0", ""},
+		{http.StatusTooManyRequests, "0", "30"},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, limitedRequest("/limited", "192.0.2.1:1234", ""))
+		if w.Code != tt.wantStatus {
+			t.Fatalf("request %d: status = %d, want %d", i, w.Code, tt.wantStatus)
+		}
+		for header, want := range map[string]string{
+			"RateLimit-Policy":    "2;w=60",
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": tt.wantRemaining,
+			"Retry-After":         tt.wantRetry,
+		} {
+			if got := w.Header().Get(header); got != want {
+				t.Errorf("request %d: %s = %q, want %q", i, header, got, want)
+			}
+		}
+		if tt.wantStatus != http.StatusTooManyRequests {
+			continue
+		}
+		var body ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("429 body %s: %v", w.Body, err)
+		}
+		if got := body.Details["retry_after_seconds"]; got != float64(30) {
+			t.Errorf("retry_after_seconds = %v, want 30", got)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, limitedRequest("/unlimited", "192.0.2.1:1234", ""))
+	if w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("unconfigured group: status = %d, RateLimit-Limit = %q, want 204 without rate limit headers", w.Code, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimiterClientIdentity(t *testing.T) {
+	tests := []struct {
+		name string
+		// first ใช้ bucket จนหมด second ต้องได้ผลตาม wantStatus
+		first, second *http.Request
+		wantStatus    int
+	}{
+		{
+			name:       "same IP shares a bucket",
+			first:      limitedRequest("/limited", "192.0.2.1:1111", ""),
+			second:     limitedRequest("/limited", "192.0.2.1:2222", ""),
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "other IP has its own bucket",
+			first:      limitedRequest("/limited", "192.0.2.1:1111", ""),
+			second:     limitedRequest("/limited", "192.0.2.2:1111", ""),
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "untrusted X-User-ID does not get a new bucket",
+			first:      limitedRequest("/limited", "192.0.2.1:1111", "1"),
+			second:     limitedRequest("/limited", "192.0.2.1:1111", "2"),
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "verified users have their own buckets",
+			first:      limitedRequest("/limited", "10.0.0.1:1111", "1"),
+			second:     limitedRequest("/limited", "10.0.0.1:1111", "2"),
+			wantStatus: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rateLimitedRouter(t)
+			for i := 0; i < 2; i++ {
+				first := tt.first.Clone(tt.first.Context())
+				r.ServeHTTP(httptest.NewRecorder(), first)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, tt.second)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// routes.go
+package handlers
+
+import (
+	"myproject/internal/bookstore"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecation กำหนดการเลิกใช้ API เวอร์ชันหนึ่ง ค่าว่างหมายถึงยังไม่ได้กำหนด
+type Deprecation struct {
+	// At วันที่ประกาศเลิกใช้ (header Deprecation ตาม RFC 9745)
+	At time.Time
+	// Sunset วันที่จะปิดเส้นทางเหล่านี้ (header Sunset ตาม RFC 8594)
+	Sunset time.Time
+}
+
+// Deprecated middleware ที่แจ้ง client ว่าเส้นทางนี้เลิกใช้แล้ว และชี้ไปยังเวอร์ชันที่มาแทนด้วย header Link
+// ถ้ายังไม่ได้กำหนดวันใดเลยจะไม่ส่ง header อะไร
+func Deprecated(d Deprecation, successor string) gin.HandlerFunc {
+	if d.At.IsZero() && d.Sunset.IsZero() {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		if !d.At.IsZero() {
+			c.Header("Deprecation", "@"+strconv.FormatInt(d.At.Unix(), 10))
+		}
+		if !d.Sunset.IsZero() {
+			c.Header("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+		c.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+		c.Next()
+	}
+}
+
+// RegisterRoutes ลงทะเบียนเส้นทางทั้งหมดของ API v1 (ใต้ /api/v1) และ v2 (ใต้ /api/v2)
+// ทั้งสองเวอร์ชันใช้ handler และ BookStore ชุดเดียวกัน v1 ส่ง header แจ้งการเลิกใช้ตาม v1Deprecation
+// แบ่งเป็นกลุ่มตามการจำกัดจำนวน request (ratelimit.limits.<กลุ่ม>) กลุ่มเดียวกันของทั้งสองเวอร์ชันใช้ bucket เดียวกัน
+// partner ส่ง API key มากับ request ได้ทุกเส้นทาง เส้นทางของเจ้าของร้านต้องใช้ key ที่มี scope ตรงกัน
+// request ที่ส่ง key มาถูกจำกัดตามกลุ่ม auth (ต่อ IP) ก่อนค้น key ในฐานข้อมูล
+//
+// เส้นทางที่เพิ่มหรือแก้ที่นี่ต้องแก้ใน OpenAPISpec ด้วย ไม่อย่างนั้น test จะไม่ผ่าน
+func RegisterRoutes(r gin.IRouter, h *BookHandlers, limiter *RateLimiter, v1Deprecation Deprecation) {
+	keyAttempts, apiKeyAuth := apiKeyAttempts(limiter), APIKeyAuth(h.bs)
+	registerV1(r.Group("/api/v1", Deprecated(v1Deprecation, "/api/v2"), keyAttempts, apiKeyAuth), h, limiter)
+	registerV2(r.Group("/api/v2", v2Envelope, keyAttempts, apiKeyAuth), h, limiter)
+}
+
+func registerV1(v1 *gin.RouterGroup, h *BookHandlers, limiter *RateLimiter) {
+	catalog := v1.Group("", limiter.Group("catalog"), ScopeIfAPIKey(bookstore.ScopeCatalogRead))
+	{
+		catalog.GET("/AllStoreInfo", h.GetAllStoreInfo)
+		// ใช้ชื่อ :store_id ให้ตรงกับเส้นทางอื่นใต้ /store ไม่อย่างนั้น gin จะ panic เพราะชื่อ wildcard ชนกัน
+		catalog.GET("/store/:store_id", h.GetStoreInfoByID)
+		catalog.GET("/product/:store_id", h.GetProductsByStore)
+		catalog.GET("/newproduct/:store_id", h.GetNewProductsByStore)
+		catalog.GET("/products/:id", h.GetProduct)
+		catalog.GET("/Allproduct/:store_id/sort", h.GetAllProductsByStore)
+		catalog.GET("/:store_id/by-category", h.GetProductsByCategoryAndStore)
+		catalog.GET("/category", h.GetALLProductsByCategory)
+		catalog.GET("/all-guitars", h.GetAllGuitars)
+	}
+
+	// การค้นหาใช้ ILIKE ซึ่งหนักกว่าการอ่านปกติ จึงจำกัดเข้มกว่า
+	search := v1.Group("", limiter.Group("search"), ScopeIfAPIKey(bookstore.ScopeCatalogRead))
+	{
+		search.GET("/searchproducts", h.SearchProducts)
+		search.GET("/:store_id/search", h.SearchProductsByStore)
+	}
+
+	cart := v1.Group("", limiter.Group("cart"))
+	{
+		cart.POST("/store/:store_id/product/:product_id/add_to_cart", h.AddToCart)
+		cart.GET("/cart/:store_id", h.GetCart)
+		cart.PATCH("/cart/:store_id/items/:item_id", h.UpdateCartItemQuantity)
+		cart.DELETE("/store/:store_id/product/:product_id/remove_from_cart", h.DeleteProductFromCart)
+	}
+
+	// เส้นทางสำหรับ Checkout (ย้ายข้อมูลจาก cart ไป order_history)
+	checkout := v1.Group("", limiter.Group("checkout"))
+	{
+		checkout.POST("/checkout/:store_id", h.Checkout)
+	}
+
+	// งานของเจ้าของร้าน: นำเข้า/ส่งออกสินค้า และตะกร้าที่ถูกทิ้งจนหมดอายุ
+	admin := v1.Group("", limiter.Group("admin"))
+	{
+		admin.POST("/store/:store_id/products/import", RequireScope(bookstore.ScopeInventoryWrite), h.ImportProducts)
+		admin.GET("/store/:store_id/products/export", RequireScope(bookstore.ScopeCatalogRead), h.ExportProducts)
+		admin.GET("/store/:store_id/abandoned-carts", RequireScope(bookstore.ScopeOrdersRead), h.GetAbandonedCarts)
+	}
+}
+
+// registerV2 เส้นทางของ API v2 ตั้งชื่อตาม resource (stores, products, carts, orders)
+// response ที่สำเร็จอยู่ใน {"data": ...} (รายการมี "meta" ด้วย) และ error อยู่ใน {"error": ...}
+func registerV2(v2 *gin.RouterGroup, h *BookHandlers, limiter *RateLimiter) {
+	catalog := v2.Group("", limiter.Group("catalog"), ScopeIfAPIKey(bookstore.ScopeCatalogRead))
+	{
+		catalog.GET("/stores", h.ListStores)
+		catalog.GET("/stores/:store_id", h.ShowStore)
+		catalog.GET("/products/:product_id", h.ShowProduct)
+	}
+
+	// รายการสินค้าที่มีคำค้น (q) นับเป็นการค้นหา ไม่อย่างนั้นนับเป็นการอ่าน catalog
+	listing := v2.Group("", searchOrCatalog(limiter), ScopeIfAPIKey(bookstore.ScopeCatalogRead))
+	{
+		listing.GET("/stores/:store_id/products", h.ListStoreProducts)
+		listing.GET("/products", h.ListProducts)
+	}
+
+	// ตะกร้ามีใบเดียวต่อเจ้าของต่อร้าน จึงใช้รหัสร้านเป็นรหัสของตะกร้า
+	cart := v2.Group("", limiter.Group("cart"))
+	{
+		cart.GET("/carts/:store_id", h.ShowCart)
+		cart.POST("/carts/:store_id/items", h.AddCartItem)
+		cart.PATCH("/carts/:store_id/items/:item_id", h.UpdateCartItem)
+		cart.DELETE("/carts/:store_id/items/:item_id", h.RemoveCartItem)
+	}
+
+	checkout := v2.Group("", limiter.Group("checkout"))
+	{
+		checkout.POST("/orders", h.CreateOrder)
+	}
+
+	admin := v2.Group("", limiter.Group("admin"))
+	{
+		admin.POST("/stores/:store_id/products/import", RequireScope(bookstore.ScopeInventoryWrite), h.ImportStoreProducts)
+		admin.GET("/stores/:store_id/products/export", RequireScope(bookstore.ScopeCatalogRead), h.ExportProducts)
+		admin.GET("/stores/:store_id/abandoned-carts", RequireScope(bookstore.ScopeOrdersRead), h.ListAbandonedCarts)
+	}
+}
+
+// searchOrCatalog จำกัดจำนวน request ตามกลุ่ม search ถ้ามีคำค้น (q) ไม่อย่างนั้นตามกลุ่ม catalog
+func searchOrCatalog(limiter *RateLimiter) gin.HandlerFunc {
+	search, catalog := limiter.Group("search"), limiter.Group("catalog")
+	return func(c *gin.Context) {
+		if c.Query("q") != "" {
+			search(c)
+			return
+		}
+		catalog(c)
+	}
+}
+
+// apiKeyAttempts จำกัดจำนวน request ที่ส่ง API key มาตามกลุ่ม auth ก่อน APIKeyAuth
+// เพื่อไม่ให้การสุ่ม key ผิดจำนวนมากต้องค้นฐานข้อมูลทุกครั้ง request ที่ไม่มี key ไม่ถูกนับ
+func apiKeyAttempts(limiter *RateLimiter) gin.HandlerFunc {
+	auth := limiter.Group("auth")
+	return func(c *gin.Context) {
+		if presentedAPIKey(c) != "" {
+			auth(c)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,419 @@
+// v2.go
+package handlers
+
+import (
+	"myproject/internal/bookstore"
+	"myproject/internal/metrics"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorEnvelopeKey key ใน gin.Context ที่บอกว่า error ของ request นี้ต้องห่อด้วย {"error": ...} (API v2)
+const errorEnvelopeKey = "error_envelope"
+
+// dataResponse response ที่สำเร็จของ API v2 ทุก endpoint
+type dataResponse struct {
+	Data interface{} `json:"data"`
+	Meta *listMeta   `json:"meta,omitempty"`
+}
+
+// listMeta ข้อมูลประกอบของ response ที่เป็นรายการ
+type listMeta struct {
+	Count int `json:"count"`
+}
+
+// errorEnvelope error ของ API v2
+type errorEnvelope struct {
+	Error ErrorResponse `json:"error"`
+}
+
+func itemResponse(data interface{}) dataResponse {
+	return dataResponse{Data: data}
+}
+
+func listResponse(data interface{}, count int) dataResponse {
+	return dataResponse{Data: data, Meta: &listMeta{Count: count}}
+}
+
+// v2Envelope ให้ ErrorHandler ห่อ error ของ API v2 ด้วย {"error": ...}
+func v2Envelope(c *gin.Context) {
+	c.Set(errorEnvelopeKey, true)
+	c.Next()
+}
+
+// การเรียงสินค้าที่ API v2 รองรับ (sort=price, -price, newest, name)
+var productSorts = map[string]func(a, b bookstore.Product) bool{
+	"price":  func(a, b bookstore.Product) bool { return a.Price < b.Price },
+	"-price": func(a, b bookstore.Product) bool { return a.Price > b.Price },
+	"newest": func(a, b bookstore.Product) bool { return a.CreatedAt.After(b.CreatedAt) },
+	"name":   func(a, b bookstore.Product) bool { return a.ProductName < b.ProductName },
+}
+
+// productSortNames ชื่อการเรียงทั้งหมดสำหรับข้อความ error และเอกสาร API
+var productSortNames = []string{"price", "-price", "newest", "name"}
+
+// storeIDParam อ่านรหัสร้านจาก path
+func storeIDParam(c *gin.Context) (int, error) {
+	storeID, err := strconv.Atoi(c.Param("store_id"))
+	if err != nil {
+		return 0, badRequest("Invalid store ID")
+	}
+	return storeID, nil
+}
+
+// filterProducts กรองสินค้าตามหมวดหมู่ที่เหลือจากการค้นในฐานข้อมูล แล้วเรียงตาม sort
+// คำค้น (q) ถูกกรองด้วย ILIKE ในฐานข้อมูลแล้วเสมอ จึงไม่ต้องกรองซ้ำ
+func filterProducts(products []bookstore.Product, category, sortBy string) []bookstore.Product {
+	filtered := make([]bookstore.Product, 0, len(products))
+	for _, p := range products {
+		if category != "" && p.Category != category {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	if less, ok := productSorts[sortBy]; ok {
+		sort.SliceStable(filtered, func(i, j int) bool { return less(filtered[i], filtered[j]) })
+	}
+	return filtered
+}
+
+// productListQuery พารามิเตอร์ของการค้นหาสินค้าใน API v2
+type productListQuery struct {
+	Query    string
+	Category string
+	Sort     string
+}
+
+func parseProductListQuery(c *gin.Context) (productListQuery, error) {
+	q := productListQuery{
+		Query:    strings.TrimSpace(c.Query("q")),
+		Category: strings.TrimSpace(c.Query("category")),
+		Sort:     c.Query("sort"),
+	}
+	if _, ok := productSorts[q.Sort]; q.Sort != "" && !ok {
+		return q, badRequest("Invalid sort, expected one of " + strings.Join(productSortNames, ", "))
+	}
+	return q, nil
+}
+
+// ListStores แสดงร้านทั้งหมด
+func (h *BookHandlers) ListStores(c *gin.Context) {
+	stores, err := h.bs.GetAllStoreInfo(c.Request.Context())
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if stores == nil {
+		stores = []bookstore.StoreInfo{}
+	}
+
+	jsonWithETag(c, http.StatusOK, listResponse(stores, len(stores)), cacheable{CacheControl: cacheControlStore})
+}
+
+// ShowStore แสดงข้อมูลร้าน
+func (h *BookHandlers) ShowStore(c *gin.Context) {
+	storeID, err := storeIDParam(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	store, err := h.bs.GetStoreInfoByID(c.Request.Context(), storeID)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	jsonWithETag(c, http.StatusOK, itemResponse(store), cacheable{CacheControl: cacheControlStore})
+}
+
+// ListStoreProducts แสดงสินค้าของร้าน กรองด้วย q (ชื่อสินค้า) และ category และเรียงด้วย sort ได้
+// ร้านที่ไม่มีสินค้าตรงเงื่อนไขได้รายการว่าง ไม่ใช่ 404 เหมือน v1
+func (h *BookHandlers) ListStoreProducts(c *gin.Context) {
+	storeID, err := storeIDParam(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	q, err := parseProductListQuery(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	// ให้ฐานข้อมูลกรองด้วยเงื่อนไขที่แคบที่สุดก่อน เงื่อนไขที่เหลือกรองต่อใน filterProducts
+	ctx := c.Request.Context()
+	var products []bookstore.Product
+	switch {
+	case q.Query != "":
+		products, err = h.bs.SearchProductsByStore(ctx, q.Query, storeID)
+	case q.Category != "":
+		products, err = h.bs.GetProductsByCategoryAndStore(ctx, storeID, q.Category)
+	default:
+		products, err = h.bs.GetProductsByStore(ctx, storeID)
+	}
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	// ร้านที่ไม่มีอยู่ต้องได้ 404 ไม่ใช่รายการว่าง
+	if len(products) == 0 {
+		if _, err := h.bs.GetStoreInfoByID(ctx, storeID); err != nil {
+			abort(c, err)
+			return
+		}
+	}
+
+	products = filterProducts(products, q.Category, q.Sort)
+	jsonWithETag(c, http.StatusOK, listResponse(products, len(products)),
+		cacheable{CacheControl: cacheControlCatalog})
+}
+
+// ListProducts ค้นหาสินค้าจากทุกร้าน ต้องระบุ q (ชื่อสินค้า) หรือ category อย่างน้อยหนึ่งอย่าง
+func (h *BookHandlers) ListProducts(c *gin.Context) {
+	q, err := parseProductListQuery(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	var products []bookstore.Product
+	switch {
+	case q.Query != "":
+		products, err = h.bs.SearchProducts(ctx, q.Query)
+	case q.Category != "":
+		products, err = h.bs.GetALLProductsByCategory(ctx, q.Category)
+	default:
+		abort(c, badRequest("Set q or category to list products, or use /stores/{store_id}/products"))
+		return
+	}
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	products = filterProducts(products, q.Category, q.Sort)
+	jsonWithETag(c, http.StatusOK, listResponse(products, len(products)),
+		cacheable{CacheControl: cacheControlCatalog})
+}
+
+// ShowProduct แสดงสินค้า 1 ชิ้น
+func (h *BookHandlers) ShowProduct(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		abort(c, badRequest("Invalid product ID"))
+		return
+	}
+
+	product, err := h.bs.GetProduct(c.Request.Context(), productID)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	jsonWithETag(c, http.StatusOK, itemResponse(product), cacheable{CacheControl: cacheControlProduct, LastModified: product.UpdatedAt})
+}
+
+// ShowCart แสดงตะกร้าของร้าน ตะกร้ามีใบเดียวต่อเจ้าของต่อร้าน จึงใช้รหัสร้านเป็นรหัสของตะกร้า
+func (h *BookHandlers) ShowCart(c *gin.Context) {
+	storeID, err := storeIDParam(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	owner, err := h.cartOwner(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	cart := bookstore.Cart{StoreID: storeID, Items: []bookstore.CartItem{}}
+	if !owner.IsZero() {
+		cart, err = h.bs.GetCart(c.Request.Context(), owner, storeID)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, itemResponse(cart))
+}
+
+// addCartItemRequest สินค้าที่จะเพิ่มลงตะกร้า ไม่ส่ง quantity มาถือว่าเป็น 1
+type addCartItemRequest struct {
+	ProductID int `json:"product_id" form:"product_id" binding:"required,min=1"`
+	Quantity  int `json:"quantity" form:"quantity,default=1" binding:"min=1,max=99"`
+}
+
+// AddCartItem เพิ่มสินค้าลงตะกร้า ถ้ามีสินค้านี้อยู่แล้วจะเพิ่มจำนวน แล้วส่งตะกร้าที่อัปเดตแล้วกลับไป
+// แขกที่ยังไม่มีตะกร้าจะได้ token ใหม่ใน cookie และ header เหมือน v1
+func (h *BookHandlers) AddCartItem(c *gin.Context) {
+	storeID, err := storeIDParam(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	req := addCartItemRequest{Quantity: 1}
+	if err := bindBody(c, &req); err != nil {
+		abort(c, err)
+		return
+	}
+
+	owner, err := h.cartOwner(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	newToken := owner.IsZero()
+	if newToken {
+		owner.Token, err = bookstore.NewCartToken()
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		withSession(c, owner)
+	}
+
+	created, err := h.bs.AddToCart(c.Request.Context(), owner, storeID, req.ProductID, req.Quantity)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if created {
+		metrics.CartCreated(storeID)
+	}
+	if newToken {
+		h.setCartToken(c, owner.Token)
+	}
+
+	cart, err := h.bs.GetCart(c.Request.Context(), owner, storeID)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, itemResponse(cart))
+}
+
+// UpdateCartItem ตั้งจำนวนของรายการในตะกร้า (0 = ลบรายการ) แล้วส่งตะกร้าที่อัปเดตแล้วกลับไป
+func (h *BookHandlers) UpdateCartItem(c *gin.Context) {
+	var req updateCartItemRequest
+	if err := bindBody(c, &req); err != nil {
+		abort(c, err)
+		return
+	}
+	h.setCartItemQuantity(c, *req.Quantity)
+}
+
+// RemoveCartItem ลบรายการออกจากตะกร้า แล้วส่งตะกร้าที่เหลือกลับไป
+func (h *BookHandlers) RemoveCartItem(c *gin.Context) {
+	h.setCartItemQuantity(c, 0)
+}
+
+func (h *BookHandlers) setCartItemQuantity(c *gin.Context, quantity int) {
+	storeID, err := storeIDParam(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	itemID, err := strconv.Atoi(c.Param("item_id"))
+	if err != nil {
+		abort(c, badRequest("Invalid cart item ID"))
+		return
+	}
+
+	owner, err := h.cartOwner(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if owner.IsZero() {
+		abort(c, bookstore.ErrCartItemNotFound)
+		return
+	}
+
+	if err := h.bs.UpdateCartItemQuantity(c.Request.Context(), owner, storeID, itemID, quantity); err != nil {
+		abort(c, err)
+		return
+	}
+
+	cart, err := h.bs.GetCart(c.Request.Context(), owner, storeID)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, itemResponse(cart))
+}
+
+// createOrderRequest ร้านที่จะ checkout และข้อมูลติดต่อ
+type createOrderRequest struct {
+	StoreID int `json:"store_id" form:"store_id" binding:"required,min=1"`
+	checkoutRequest
+}
+
+// CreateOrder สร้างคำสั่งซื้อจากตะกร้าของร้าน store_id แล้วส่งคำสั่งซื้อกลับไป
+func (h *BookHandlers) CreateOrder(c *gin.Context) {
+	var req createOrderRequest
+	if err := bindBody(c, &req); err != nil {
+		abort(c, err)
+		return
+	}
+
+	order, err := h.placeOrder(c, req.StoreID, req.checkoutRequest)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, itemResponse(order))
+}
+
+// ImportStoreProducts นำเข้าสินค้าของร้านจากไฟล์ เหมือน ImportProducts ของ v1 แต่ตอบในรูปแบบของ v2
+func (h *BookHandlers) ImportStoreProducts(c *gin.Context) {
+	report, err := h.importProducts(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, itemResponse(report))
+}
+
+// ListAbandonedCarts แสดงรายการตะกร้าที่ถูกทิ้งของร้านตั้งแต่ since (RFC3339) ค่าเริ่มต้นคือ 30 วันที่ผ่านมา
+func (h *BookHandlers) ListAbandonedCarts(c *gin.Context) {
+	storeID, err := storeIDParam(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -30)
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		since, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			abort(c, badRequest("Invalid since, expected RFC3339 timestamp"))
+			return
+		}
+	}
+
+	events, err := h.bs.GetAbandonedCartEvents(c.Request.Context(), storeID, since)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if events == nil {
+		events = []bookstore.AbandonedCartEvent{}
+	}
+
+	c.JSON(http.StatusOK, listResponse(events, len(events)))
+}
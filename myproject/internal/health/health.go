@@ -0,0 +1,145 @@
+// health.go
+
+// Package health ตรวจสอบความพร้อมของเซิร์ฟเวอร์และระบบที่ต้องพึ่งพา สำหรับ /livez และ /readyz
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// สถานะของแต่ละการตรวจสอบและของทั้งระบบ
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+	StatusSkipped  = "not_configured"
+)
+
+// Check การตรวจสอบระบบที่ต้องพึ่งพา 1 ระบบ
+type Check struct {
+	Name string
+	// Critical ถ้าเป็น true และตรวจสอบไม่ผ่าน เซิร์ฟเวอร์จะถือว่ายังไม่พร้อมรับ request
+	// ถ้าเป็น false จะรายงานเป็น degraded แต่ยังรับ request ได้
+	Critical bool
+	// Run คืน error ถ้าระบบนั้นใช้งานไม่ได้ ถ้าเป็น nil ถือว่าไม่ได้ตั้งค่าไว้
+	Run func(ctx context.Context) error
+}
+
+// Result ผลการตรวจสอบ 1 ระบบ
+type Result struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report ผลการตรวจสอบทั้งหมด
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker รันการตรวจสอบทั้งหมดพร้อมกัน
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+// NewChecker สร้าง Checker ที่ให้แต่ละการตรวจสอบใช้เวลาได้ไม่เกิน timeout
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+// Run ตรวจสอบทุกระบบพร้อมกันแล้วสรุปสถานะรวม
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := c.run(ctx, check)
+			mu.Lock()
+			report.Checks[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusDown {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusDown
+		} else if report.Status == StatusUp {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	result := Result{Status: StatusSkipped, Critical: check.Critical}
+	if check.Run == nil {
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	result.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	result.Status = StatusUp
+	if err != nil {
+		result.Status, result.Error = StatusDown, err.Error()
+	}
+	return result
+}
+
+// Livez บอกว่า process ยังทำงานอยู่ ไม่ตรวจสอบระบบที่ต้องพึ่งพา
+// เพื่อไม่ให้ orchestrator restart เซิร์ฟเวอร์เพียงเพราะฐานข้อมูลล่มชั่วคราว
+func Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// Readyz รายงานสถานะของทุกระบบที่ต้องพึ่งพา ตอบ 503 ถ้าระบบที่จำเป็นตัวใดตัวหนึ่งใช้งานไม่ได้
+func (c *Checker) Readyz(ctx *gin.Context) {
+	report := c.Run(ctx.Request.Context())
+	status := http.StatusOK
+	if report.Status == StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, report)
+}
+
+// HTTPCheck ตรวจสอบว่า url ตอบกลับด้วย status ที่ไม่ใช่ 5xx คืน nil ถ้า url ว่าง (ไม่ได้ตั้งค่า)
+func HTTPCheck(url string) func(ctx context.Context) error {
+	if url == "" {
+		return nil
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
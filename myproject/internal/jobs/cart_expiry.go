@@ -0,0 +1,24 @@
+// cart_expiry.go
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"myproject/internal/bookstore"
+	"time"
+)
+
+// NewCartExpiry สร้างงานที่ทำให้ตะกร้าซึ่งไม่มีความเคลื่อนไหวนานกว่า ttl หมดอายุ
+// และบันทึกเป็น abandoned cart event ไว้ให้เจ้าของร้านดึงไปใช้ภายหลัง
+func NewCartExpiry(bs *bookstore.BookStore, ttl time.Duration) Job {
+	return NewFunc("cart-expiry", func(ctx context.Context) error {
+		expired, err := bs.ExpireIdleCarts(ctx, time.Now().Add(-ttl))
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			slog.InfoContext(ctx, "expired abandoned cart items", slog.Int("count", expired))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+// jobs.go
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job งานเบื้องหลังที่ Runner เรียกซ้ำตามรอบเวลา
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// funcJob ใช้ฟังก์ชันธรรมดาเป็น Job
+type funcJob struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (j funcJob) Name() string {
+	return j.name
+}
+
+func (j funcJob) Run(ctx context.Context) error {
+	return j.fn(ctx)
+}
+
+// NewFunc สร้าง Job จากฟังก์ชัน
+func NewFunc(name string, fn func(ctx context.Context) error) Job {
+	return funcJob{name: name, fn: fn}
+}
+
+type entry struct {
+	job      Job
+	interval time.Duration
+}
+
+// Runner รันงานเบื้องหลังแต่ละงานใน goroutine ของตัวเอง จนกว่า context จะถูกยกเลิก
+type Runner struct {
+	entries []entry
+	wg      sync.WaitGroup
+}
+
+// NewRunner สร้าง Runner ที่ยังไม่มีงาน
+func NewRunner() *Runner {
+	return &Runner{}
+}
+
+// Add เพิ่มงานที่จะรันทุก ๆ interval ต้องเรียกก่อน Start ถ้า interval ไม่มากกว่า 0 ถือว่าปิดงานนั้น
+func (r *Runner) Add(job Job, interval time.Duration) {
+	if interval <= 0 {
+		slog.Info("job disabled", slog.String("job", job.Name()))
+		return
+	}
+	r.entries = append(r.entries, entry{job: job, interval: interval})
+}
+
+// Start เริ่มรันงานทั้งหมด แล้วคืนค่าทันที
+func (r *Runner) Start(ctx context.Context) {
+	for _, e := range r.entries {
+		r.wg.Add(1)
+		go r.loop(ctx, e)
+	}
+}
+
+// Wait รอจนทุกงานหยุดทำงาน (หลังจาก context ของ Start ถูกยกเลิก)
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Runner) loop(ctx context.Context, e entry) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.runOnce(ctx, e.job)
+		}
+	}
+}
+
+// runOnce รันงาน 1 รอบ ถ้างาน panic จะบันทึก log แล้วรอรอบถัดไป ไม่ให้ทั้งโปรแกรมล่ม
+func (r *Runner) runOnce(ctx context.Context, job Job) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.ErrorContext(ctx, "job panicked", slog.String("job", job.Name()), slog.Any("panic", rec))
+		}
+	}()
+
+	start := time.Now()
+	err := job.Run(ctx)
+	elapsed := slog.Duration("duration", time.Since(start))
+	if err != nil {
+		slog.ErrorContext(ctx, "job failed", slog.String("job", job.Name()), elapsed, slog.Any("error", err))
+		return
+	}
+	slog.DebugContext(ctx, "job finished", slog.String("job", job.Name()), elapsed)
+}
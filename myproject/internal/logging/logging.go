@@ -0,0 +1,76 @@
+// logging.go
+
+// Package logging ตั้งค่า log/slog ของทั้งระบบ และส่งรหัส request ผ่าน context.Context
+// เพื่อให้ทุกบรรทัดที่ log ด้วย slog.*Context มี request_id ของ request ที่ทำให้เกิด log นั้น
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID คืน context ที่มีรหัส request
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID คืนรหัส request จาก context หรือค่าว่างถ้าไม่มี
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ParseLevel แปลงชื่อระดับ log (debug, info, warn, error) เป็น slog.Level
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return level, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", name)
+	}
+	return level, nil
+}
+
+// New สร้าง logger ที่เขียนลง w ในรูปแบบ format ("json" หรือ "text") ตั้งแต่ระดับ level ขึ้นไป
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch format {
+	case "", "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (use json or text)", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// contextHandler เพิ่ม request_id และ trace_id/span_id (ถ้ามี span) จาก context ให้ทุก record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,184 @@
+// metrics.go
+
+// Package metrics เก็บค่าวัดของระบบในรูปแบบ Prometheus และเปิดให้ดึงผ่าน /metrics บน listener ภายใน
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "musicstore"
+
+// Registry ที่เก็บค่าวัดทั้งหมดของระบบ แยกจาก registry ส่วนกลางของ client_golang
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_reconnect_attempts_total",
+		Help:      "Database reconnect attempts made by the health check loop, by result.",
+	}, []string{"result"})
+
+	cartsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "carts_created_total",
+		Help:      "Carts started (first item added to an empty cart), by store.",
+	}, []string{"store_id"})
+
+	checkouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checkouts_total",
+		Help:      "Successful checkouts by store.",
+	}, []string{"store_id"})
+
+	checkoutFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checkout_failures_total",
+		Help:      "Failed checkouts by store and reason (error code).",
+	}, []string{"store_id", "reason"})
+
+	revenue = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Order totals of successful checkouts, by store.",
+	}, []string{"store_id"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Cache lookups by database method and result (hit or miss).",
+	}, []string{"method", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, dbReconnects,
+		cartsCreated, checkouts, checkoutFailures, revenue,
+		cacheLookups,
+	)
+}
+
+// Handler ส่งค่าวัดทั้งหมดในรูปแบบที่ Prometheus อ่านได้
+// ค่าวัดมียอดขายและรายชื่อเส้นทางทั้งหมด จึงต้องเปิดบน listener ภายในเท่านั้น ไม่ใช่บน router ของ API
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// HTTP middleware นับจำนวนและเวลาของ request ตาม route
+// ใช้ route pattern (เช่น /api/v1/products/:id) แทน path จริง เพื่อไม่ให้จำนวน label โตไม่จำกัด
+func HTTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDBStats เพิ่มค่าวัดของ connection pool โดยเรียก stats ทุกครั้งที่ Prometheus ดึงข้อมูล
+// จึงได้ค่าของ pool ปัจจุบันเสมอแม้จะมีการเชื่อมต่อใหม่
+func RegisterDBStats(stats func() sql.DBStats) {
+	Registry.MustRegister(&dbStatsCollector{stats: stats})
+}
+
+// ReconnectAttempt นับการพยายามเชื่อมต่อฐานข้อมูลใหม่
+func ReconnectAttempt(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	dbReconnects.WithLabelValues(result).Inc()
+}
+
+// CartCreated นับตะกร้าใหม่ของร้าน
+func CartCreated(storeID int) {
+	cartsCreated.WithLabelValues(strconv.Itoa(storeID)).Inc()
+}
+
+// CheckoutSucceeded นับการชำระเงินที่สำเร็จและยอดขายของร้าน
+func CheckoutSucceeded(storeID int, amount float64) {
+	label := strconv.Itoa(storeID)
+	checkouts.WithLabelValues(label).Inc()
+	revenue.WithLabelValues(label).Add(amount)
+}
+
+// CheckoutFailed นับการชำระเงินที่ล้มเหลว reason คือรหัส error ที่ส่งให้ client เช่น out_of_stock
+func CheckoutFailed(storeID int, reason string) {
+	checkoutFailures.WithLabelValues(strconv.Itoa(storeID), reason).Inc()
+}
+
+// CacheResult นับการอ่าน cache ของแต่ละ method ว่าพบหรือไม่
+func CacheResult(method string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(method, result).Inc()
+}
+
+var (
+	dbOpenDesc      = dbDesc("open_connections", "Established connections, both in use and idle.")
+	dbInUseDesc     = dbDesc("in_use_connections", "Connections currently in use.")
+	dbIdleDesc      = dbDesc("idle_connections", "Idle connections.")
+	dbMaxOpenDesc   = dbDesc("max_open_connections", "Maximum number of open connections (0 = unlimited).")
+	dbWaitCountDesc = dbDesc("wait_count_total", "Total number of connections waited for.")
+	dbWaitTimeDesc  = dbDesc("wait_duration_seconds_total", "Total time blocked waiting for a new connection.")
+	dbClosedDesc    = prometheus.NewDesc(namespace+"_db_closed_connections_total",
+		"Connections closed by the pool, by reason.", []string{"reason"}, nil)
+)
+
+func dbDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(namespace+"_db_"+name, help, nil, nil)
+}
+
+// dbStatsCollector แปลง sql.DBStats เป็นค่าวัดของ Prometheus
+type dbStatsCollector struct {
+	stats func() sql.DBStats
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{dbOpenDesc, dbInUseDesc, dbIdleDesc, dbMaxOpenDesc, dbWaitCountDesc, dbWaitTimeDesc, dbClosedDesc} {
+		ch <- d
+	}
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(dbOpenDesc, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUseDesc, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdleDesc, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(dbMaxOpenDesc, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitTimeDesc, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(dbClosedDesc, prometheus.CounterValue, float64(s.MaxIdleClosed), "max_idle")
+	ch <- prometheus.MustNewConstMetric(dbClosedDesc, prometheus.CounterValue, float64(s.MaxIdleTimeClosed), "max_idle_time")
+	ch <- prometheus.MustNewConstMetric(dbClosedDesc, prometheus.CounterValue, float64(s.MaxLifetimeClosed), "max_lifetime")
+}
@@ -0,0 +1,94 @@
+// command.go
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Usage วิธีใช้คำสั่ง migrate
+const Usage = "usage: migrate up | down [steps] | status [-json]"
+
+// Command รันคำสั่ง migrate up|down|status จาก command line แล้วพิมพ์ผลลัพธ์ลง out
+func Command(ctx context.Context, connStr string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	db, err := Open(ctx, connStr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := New(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, mig := range done {
+			fmt.Fprintf(out, "applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(out, "database is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		done, err := m.Down(ctx, steps)
+		for _, mig := range done {
+			fmt.Fprintf(out, "rolled back %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(out, "no migrations to roll back")
+		}
+
+	case "status":
+		fs := flag.NewFlagSet("migrate status", flag.ContinueOnError)
+		fs.SetOutput(out)
+		asJSON := fs.Bool("json", false, "print JSON instead of a table")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(statuses)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		return errors.New(Usage)
+	}
+	return nil
+}
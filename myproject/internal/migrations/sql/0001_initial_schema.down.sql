@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS cart;
+DROP TRIGGER IF EXISTS set_timestamp ON product_info;
+DROP FUNCTION IF EXISTS update_updated_at_column();
+DROP TABLE IF EXISTS product_info;
+DROP TABLE IF EXISTS store_info;
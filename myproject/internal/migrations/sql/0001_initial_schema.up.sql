@@ -0,0 +1,52 @@
+-- โครงสร้างเริ่มต้นจาก init.sql เดิม ใช้ IF NOT EXISTS เพื่อให้ฐานข้อมูลที่สร้างจาก init.sql ไปแล้วใช้ต่อได้
+
+CREATE TABLE IF NOT EXISTS store_info (
+    id SERIAL PRIMARY KEY,
+    logo_path VARCHAR(255), -- ที่เก็บเส้นทางโลโก้ของร้าน เช่น URL หรือพาธในระบบไฟล์
+    store_name VARCHAR(255) NOT NULL,
+    description TEXT,
+    address VARCHAR(255),
+    phone_number VARCHAR(20),
+    email VARCHAR(100)
+);
+
+CREATE TABLE IF NOT EXISTS product_info (
+    id SERIAL PRIMARY KEY,
+    product_name VARCHAR(255) NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    quantity INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    category VARCHAR(100),
+    brand VARCHAR(100),
+    model VARCHAR(100),
+    store_id INT REFERENCES store_info(id),
+    is_recommended BOOLEAN DEFAULT FALSE,
+    image_path VARCHAR(255) NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS set_timestamp ON product_info;
+CREATE TRIGGER set_timestamp
+BEFORE UPDATE ON product_info
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS cart (
+    id SERIAL PRIMARY KEY,
+    store_id INT NOT NULL,  -- อ้างอิงถึงร้านค้าจาก store_info
+    product_id INT NOT NULL,  -- รหัสสินค้า
+    quantity INT NOT NULL,  -- จำนวนสินค้าในรถเข็น
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- เวลาที่สินค้าได้รับการเพิ่มเข้าไปในรถเข็น
+    checked_out_at TIMESTAMP,  -- เวลาเช็คเอาต์ (เมื่อมีการจ่ายเงินหรือทำการเช็คเอาต์)
+    status VARCHAR(50) DEFAULT 'in_cart',  -- สถานะของสินค้า (in_cart, checked_out, expired)
+    FOREIGN KEY (store_id) REFERENCES store_info(id),  -- อ้างอิงถึง store_info(id)
+    FOREIGN KEY (product_id) REFERENCES product_info(id)  -- อ้างอิงถึงสินค้าในตาราง product_info
+);
@@ -0,0 +1,11 @@
+-- ตารางที่ PostgresDatabase.Checkout ใช้เก็บประวัติการสั่งซื้อ
+CREATE TABLE IF NOT EXISTS order_history (
+    id SERIAL PRIMARY KEY,
+    store_id INT NOT NULL REFERENCES store_info(id),
+    product_id INT NOT NULL REFERENCES product_info(id),
+    quantity INT NOT NULL,
+    status VARCHAR(50) DEFAULT 'ordered',
+    ordered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_history_store ON order_history (store_id, ordered_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_cart_token;
+DROP INDEX IF EXISTS idx_cart_user_id;
+ALTER TABLE cart DROP COLUMN IF EXISTS order_id;
+ALTER TABLE cart DROP COLUMN IF EXISTS cart_token;
+ALTER TABLE cart DROP COLUMN IF EXISTS user_id;
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,21 @@
+-- ตะกร้าของแขก (cart_token) และผู้ใช้ (user_id) พร้อมคำสั่งซื้อที่เก็บข้อมูลติดต่อ
+CREATE TABLE IF NOT EXISTS orders (
+    id SERIAL PRIMARY KEY,
+    store_id INT NOT NULL REFERENCES store_info(id),
+    user_id INT,  -- ผู้ใช้ที่ล็อกอิน (NULL ถ้าสั่งซื้อแบบแขก)
+    cart_token VARCHAR(64),  -- token ของตะกร้าแขกที่ใช้สั่งซื้อ
+    contact_name VARCHAR(255),
+    contact_email VARCHAR(100),
+    contact_phone VARCHAR(20),
+    shipping_address TEXT,
+    total_amount DECIMAL(10, 2) NOT NULL,
+    status VARCHAR(50) DEFAULT 'placed',  -- สถานะคำสั่งซื้อ (placed, cancelled, refunded)
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE cart ADD COLUMN IF NOT EXISTS user_id INT;
+ALTER TABLE cart ADD COLUMN IF NOT EXISTS cart_token VARCHAR(64);
+ALTER TABLE cart ADD COLUMN IF NOT EXISTS order_id INT REFERENCES orders(id);
+
+CREATE INDEX IF NOT EXISTS idx_cart_user_id ON cart (user_id) WHERE status = 'in_cart';
+CREATE INDEX IF NOT EXISTS idx_cart_token ON cart (cart_token) WHERE status = 'in_cart';
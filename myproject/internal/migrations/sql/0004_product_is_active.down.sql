@@ -0,0 +1 @@
+ALTER TABLE product_info DROP COLUMN IF EXISTS is_active;
@@ -0,0 +1,2 @@
+-- ปิดการขายสินค้าโดยไม่ต้องลบออก
+ALTER TABLE product_info ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS abandoned_cart_events;
+ALTER TABLE cart DROP COLUMN IF EXISTS updated_at;
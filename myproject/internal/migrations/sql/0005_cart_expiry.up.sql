@@ -0,0 +1,17 @@
+-- เวลาที่แก้ไขตะกร้าล่าสุด และประวัติตะกร้าที่ถูกทิ้งจนหมดอายุ
+ALTER TABLE cart ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS abandoned_cart_events (
+    id SERIAL PRIMARY KEY,
+    cart_item_id INT NOT NULL REFERENCES cart(id),
+    store_id INT NOT NULL REFERENCES store_info(id),
+    user_id INT,
+    cart_token VARCHAR(64),
+    product_id INT NOT NULL REFERENCES product_info(id),
+    quantity INT NOT NULL,
+    unit_price DECIMAL(10, 2) NOT NULL,  -- ราคาสินค้า ณ เวลาที่ตะกร้าหมดอายุ
+    last_activity_at TIMESTAMP NOT NULL,
+    abandoned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_abandoned_cart_events_store ON abandoned_cart_events (store_id, abandoned_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS categories;
+DROP INDEX IF EXISTS ux_product_info_store_brand_model;
+DROP INDEX IF EXISTS ux_store_info_store_name;
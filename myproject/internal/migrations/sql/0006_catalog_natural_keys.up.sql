@@ -0,0 +1,15 @@
+-- คีย์ธรรมชาติสำหรับ upsert ข้อมูลร้านและสินค้า (seed และ import)
+CREATE UNIQUE INDEX IF NOT EXISTS ux_store_info_store_name ON store_info (store_name);
+CREATE UNIQUE INDEX IF NOT EXISTS ux_product_info_store_brand_model ON product_info (store_id, brand, model);
+
+-- หมวดหมู่สินค้า product_info.category อ้างอิงด้วยชื่อ
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    image_path VARCHAR(255)
+);
+
+INSERT INTO categories (name)
+SELECT DISTINCT category FROM product_info WHERE category IS NOT NULL
+ON CONFLICT (name) DO NOTHING;
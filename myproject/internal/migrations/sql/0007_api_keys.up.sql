@@ -0,0 +1,13 @@
+-- API key ของ partner เก็บเฉพาะ SHA-256 ของ key ตัว key จริงแสดงให้เห็นครั้งเดียวตอนออก key
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    partner VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,  -- ส่วนต้นของ key ใช้ระบุว่าเป็น key ไหนโดยไม่ต้องเปิดเผย key
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_partner ON api_keys (partner);
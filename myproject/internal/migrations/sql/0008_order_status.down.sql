@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_orders_store;
+ALTER TABLE orders DROP COLUMN IF EXISTS status_changed_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ALTER COLUMN status DROP NOT NULL;
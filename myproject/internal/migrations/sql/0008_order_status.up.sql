@@ -0,0 +1,9 @@
+-- สถานะคำสั่งซื้อที่ผู้ดูแลเปลี่ยนได้ (ยกเลิก คืนเงิน) พร้อมเหตุผลและเวลาที่เปลี่ยนล่าสุด
+UPDATE orders SET status = 'placed' WHERE status IS NULL;
+ALTER TABLE orders ALTER COLUMN status SET NOT NULL;
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('placed', 'cancelled', 'refunded'));
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status_reason TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_orders_store ON orders (store_id, created_at);
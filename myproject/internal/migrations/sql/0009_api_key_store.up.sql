@@ -0,0 +1,5 @@
+-- ผูก API key กับร้าน key ที่มี scope orders:read หรือ inventory:write ใช้ได้เฉพาะกับร้านของตัวเอง
+-- NULL คือไม่ผูกกับร้าน ใช้ได้เฉพาะ catalog:read (ข้อมูลสาธารณะ) key เดิมที่มี scope อื่นต้องออกใหม่พร้อม -store
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS store_id INT REFERENCES store_info(id);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_store ON api_keys (store_id);
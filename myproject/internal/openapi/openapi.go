@@ -0,0 +1,172 @@
+// openapi.go
+
+// Package openapi โครงสร้างของเอกสาร OpenAPI 3.0 และการสร้าง schema จาก type ของ Go
+// มีเฉพาะส่วนที่ API ของเราใช้ ไม่ได้ครอบคลุมทั้ง specification
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Version เวอร์ชันของ OpenAPI ที่เอกสารใช้
+const Version = "3.0.3"
+
+// Document เอกสาร OpenAPI ทั้งฉบับ
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	// types type ของ Go ที่สร้างแต่ละ schema ใน components ใช้ตรวจว่าชื่อไม่ชนกัน
+	types map[string]reflect.Type
+}
+
+// Info ข้อมูลทั่วไปของ API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server URL ที่ใช้เรียก API
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag กลุ่มของ operation ที่ใช้จัดหมวดในหน้าเอกสาร
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem operation ของเส้นทางหนึ่ง แยกตาม HTTP method (ตัวพิมพ์เล็ก เช่น "get")
+type PathItem map[string]*Operation
+
+// Operation การเรียก API 1 แบบ (method + path)
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	// RequiredScope scope ของ API key ที่ต้องมี (ส่วนขยายของเรา)
+	RequiredScope string `json:"x-required-scope,omitempty"`
+	// RateLimitGroup กลุ่มของการจำกัดจำนวน request ที่ operation นี้อยู่ (ส่วนขยายของเรา)
+	RateLimitGroup string `json:"x-rate-limit-group,omitempty"`
+}
+
+// Parameter พารามิเตอร์ใน path, query หรือ header
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody เนื้อหาที่ client ส่งมา แยกตาม content type
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response ผลลัพธ์ของ status code หนึ่ง ถ้ามี Ref จะอ้างถึง response ใน components
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header header ของ response
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType schema ของเนื้อหาใน content type หนึ่ง
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components ส่วนที่ใช้ซ้ำได้และถูกอ้างถึงด้วย $ref
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme วิธียืนยันตัวตน
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+}
+
+// SecurityRequirement วิธียืนยันตัวตนที่ operation ยอมรับ map ว่างหมายถึงไม่ต้องยืนยันตัวตน
+type SecurityRequirement map[string][]string
+
+// New สร้างเอกสารเปล่า
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			Responses:       make(map[string]*Response),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+	}
+}
+
+// Add เพิ่ม operation ที่ method และ path (รูปแบบ OpenAPI เช่น /store/{store_id})
+// panic ถ้าเพิ่มซ้ำ เพราะเป็นความผิดพลาดของโปรแกรม
+func (d *Document) Add(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	method = strings.ToLower(method)
+	if _, dup := (*item)[method]; dup {
+		panic(fmt.Sprintf("openapi: duplicate operation %s %s", strings.ToUpper(method), path))
+	}
+	(*item)[method] = op
+}
+
+// Operations คืน "METHOD path" ของทุก operation เรียงตามตัวอักษร
+func (d *Document) Operations() []string {
+	var ops []string
+	for path, item := range d.Paths {
+		for method := range *item {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// ResponseRef อ้างถึง response ใน components
+func ResponseRef(name string) *Response {
+	return &Response{Ref: "#/components/responses/" + name}
+}
+
+// JSON เนื้อหาแบบ application/json ที่มี schema นี้
+func JSON(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
@@ -0,0 +1,276 @@
+// schema.go
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema JSON schema ตามแบบของ OpenAPI 3.0 ถ้ามี Ref จะอ้างถึง schema ใน components
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// String schema ของข้อความ
+func String(description string) *Schema {
+	return &Schema{Type: "string", Description: description}
+}
+
+// Integer schema ของจำนวนเต็ม
+func Integer(description string) *Schema {
+	return &Schema{Type: "integer", Description: description}
+}
+
+// Number schema ของตัวเลขทศนิยม
+func Number(description string) *Schema {
+	return &Schema{Type: "number", Description: description}
+}
+
+// Boolean schema ของค่าจริงหรือเท็จ
+func Boolean(description string) *Schema {
+	return &Schema{Type: "boolean", Description: description}
+}
+
+// ArrayOf schema ของ array ที่มีสมาชิกเป็น items
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// Object schema ของ object ที่มีฟิลด์ตาม properties ทุกฟิลด์ถือว่ามีเสมอ ยกเว้นที่อยู่ใน optional
+func Object(properties map[string]*Schema, optional ...string) *Schema {
+	s := &Schema{Type: "object", Properties: properties}
+	for name := range properties {
+		if !contains(optional, name) {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// WithEnum กำหนดค่าที่เป็นไปได้ของ schema
+func (s *Schema) WithEnum(values ...interface{}) *Schema {
+	s.Enum = values
+	return s
+}
+
+// WithDefault กำหนดค่าเริ่มต้นของ schema
+func (s *Schema) WithDefault(value interface{}) *Schema {
+	s.Default = value
+	return s
+}
+
+// WithMinimum กำหนดค่าต่ำสุดของตัวเลข
+func (s *Schema) WithMinimum(min float64) *Schema {
+	s.Minimum = &min
+	return s
+}
+
+// WithMaximum กำหนดค่าสูงสุดของตัวเลข
+func (s *Schema) WithMaximum(max float64) *Schema {
+	s.Maximum = &max
+	return s
+}
+
+// WithFormat กำหนด format ของ schema เช่น date-time
+func (s *Schema) WithFormat(format string) *Schema {
+	s.Format = format
+	return s
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf สร้าง schema จาก type ของ v ตาม json tag แบบเดียวกับ encoding/json
+// struct ที่มีชื่อจะถูกเก็บไว้ใน components ครั้งเดียวแล้วอ้างถึงด้วย $ref
+// ฟิลด์ที่มี omitempty ถือว่าอาจไม่มีในผลลัพธ์ ฟิลด์ที่เป็น pointer อาจเป็น null
+// ฟิลด์ของ request ที่มี tag binding ใช้กฎใน tag แทน: มีเฉพาะ required ที่บังคับส่ง และ min, max, email, oneof
+// กลายเป็นขอบเขตของค่าใน schema ส่วน default= ใน tag form กลายเป็นค่าเริ่มต้น
+func (d *Document) SchemaOf(v interface{}) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == nil || t.Kind() == reflect.Interface:
+		return &Schema{}
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := d.schemaOf(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		return d.namedSchema(t)
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+// namedSchema เก็บ struct ที่มีชื่อไว้ใน components ชื่อของ schema คือชื่อ type ที่ขึ้นต้นด้วยตัวพิมพ์ใหญ่
+// panic ถ้ามีสอง type ที่ได้ชื่อเดียวกัน
+func (d *Document) namedSchema(t reflect.Type) *Schema {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := d.Components.Schemas[name]; ok {
+		if d.types[name] != t {
+			panic(fmt.Sprintf("openapi: schema name %s used by both %s and %s", name, d.types[name], t))
+		}
+		return ref
+	}
+	if d.types == nil {
+		d.types = make(map[string]reflect.Type)
+	}
+	d.types[name] = t
+	// จองชื่อไว้ก่อนเพื่อรองรับ type ที่อ้างถึงตัวเอง
+	d.Components.Schemas[name] = &Schema{}
+	*d.Components.Schemas[name] = *d.structSchema(t)
+	return ref
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			d.addFields(s, f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = d.schemaOf(f.Type)
+		required := !contains(strings.Split(opts, ","), "omitempty")
+		if rules, ok := f.Tag.Lookup("binding"); ok {
+			required = applyBinding(s.Properties[name], rules)
+		}
+		// ค่าเริ่มต้นแบบเดียวกับที่ gin ใช้ตอนอ่าน form เช่น form:"quantity,default=1"
+		if _, def, ok := strings.Cut(f.Tag.Get("form"), ",default="); ok {
+			s.Properties[name].Default = defaultValue(s.Properties[name], def)
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// defaultValue แปลงค่าเริ่มต้นที่เป็นข้อความให้เป็นชนิดเดียวกับ schema
+func defaultValue(s *Schema, value string) interface{} {
+	switch s.Type {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// applyBinding ใส่กฎการตรวจสอบของ validator (tag binding ของ gin) ลงใน schema คืน true ถ้าฟิลด์นี้บังคับส่ง
+// กฎที่ไม่รู้จักจะถูกข้ามไป schema ที่เป็น $ref ไม่ถูกแก้
+func applyBinding(s *Schema, rules string) bool {
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+			s.Nullable = false
+		case "email":
+			s.Format = "email"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, v)
+			}
+		case "min", "max":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil || s.Ref != "" {
+				continue
+			}
+			switch {
+			case s.Type == "string" && name == "min":
+				length := int(n)
+				s.MinLength = &length
+			case s.Type == "string":
+				length := int(n)
+				s.MaxLength = &length
+			case name == "min":
+				s.WithMinimum(n)
+			default:
+				s.WithMaximum(n)
+			}
+		}
+	}
+	return required
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+// memory.go
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval รอบเวลาที่ทิ้ง bucket ที่เต็มแล้ว (ไม่มี request มานานพอ) เพื่อไม่ให้หน่วยความจำโตไม่จำกัด
+const sweepInterval = time.Minute
+
+// Memory เก็บ bucket ในหน่วยความจำ แต่ละ instance นับแยกกัน ถ้ารันหลาย instance ควรใช้ Redis
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// NewMemory สร้าง Memory ที่ยังไม่มี bucket
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]*bucket{}, lastSweep: time.Now(), now: time.Now}
+}
+
+func (m *Memory) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		m.buckets[key] = b
+	}
+	b.limit = limit
+	b.refill(now)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(allowed, b.tokens, limit), nil
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	b.last = now
+}
+
+// sweep ทิ้ง bucket ที่เติมจนเต็มแล้ว ซึ่งมีสถานะเหมือน bucket ใหม่
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
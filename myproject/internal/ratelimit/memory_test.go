@@ -0,0 +1,111 @@
+// memory_test.go
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryAllow(t *testing.T) {
+	// 3 request ติดกัน แล้วเติม 1 token ทุก 20 วินาที
+	limit := Limit{Rate: 3.0 / 60, Burst: 3}
+
+	type step struct {
+		// after เวลาที่ผ่านไปก่อนขอ token
+		after         time.Duration
+		key           string
+		wantAllowed   bool
+		wantRemaining int
+		wantReset     time.Duration
+		wantRetry     time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "burst then limited",
+			steps: []step{
+				{key: "a", wantAllowed: true, wantRemaining: 2, wantReset: 20 * time.Second},
+				{key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 40 * time.Second},
+				{key: "a", wantAllowed: true, wantRemaining: 0, wantReset: time.Minute},
+				{key: "a", wantAllowed: false, wantRemaining: 0, wantReset: time.Minute, wantRetry: 20 * time.Second},
+			},
+		},
+		{
+			name: "refills over time",
+			steps: []step{
+				{key: "a", wantAllowed: true, wantRemaining: 2, wantReset: 20 * time.Second},
+				{key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 40 * time.Second},
+				{key: "a", wantAllowed: true, wantRemaining: 0, wantReset: time.Minute},
+				{after: 10 * time.Second, key: "a", wantAllowed: false, wantRemaining: 0, wantReset: 50 * time.Second, wantRetry: 10 * time.Second},
+				{after: 10 * time.Second, key: "a", wantAllowed: true, wantRemaining: 0, wantReset: time.Minute},
+				{after: time.Hour, key: "a", wantAllowed: true, wantRemaining: 2, wantReset: 20 * time.Second},
+			},
+		},
+		{
+			name: "keys have separate buckets",
+			steps: []step{
+				{key: "a", wantAllowed: true, wantRemaining: 2, wantReset: 20 * time.Second},
+				{key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 40 * time.Second},
+				{key: "a", wantAllowed: true, wantRemaining: 0, wantReset: time.Minute},
+				{key: "b", wantAllowed: true, wantRemaining: 2, wantReset: 20 * time.Second},
+				{key: "a", wantAllowed: false, wantRemaining: 0, wantReset: time.Minute, wantRetry: 20 * time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			m := NewMemory()
+			m.now, m.lastSweep = func() time.Time { return now }, now
+
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				res, err := m.Allow(context.Background(), s.key, limit)
+				if err != nil {
+					t.Fatalf("step %d: Allow() error = %v", i, err)
+				}
+				want := Result{Allowed: s.wantAllowed, Remaining: s.wantRemaining, Reset: s.wantReset, RetryAfter: s.wantRetry}
+				if !closeResult(res, want) {
+					t.Errorf("step %d: Allow(%q) = %+v, want %+v", i, s.key, res, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemorySweepDropsFullBuckets(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now, m.lastSweep = func() time.Time { return now }, now
+	limit := Limit{Rate: 1, Burst: 5}
+
+	for _, key := range []string{"idle", "busy"} {
+		if _, err := m.Allow(context.Background(), key, limit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// ผ่านไปนานกว่า sweepInterval ทุก bucket เติมจนเต็ม เหลือเฉพาะ bucket ที่เพิ่งถูกใช้
+	now = now.Add(sweepInterval + time.Second)
+	if _, err := m.Allow(context.Background(), "busy", limit); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.buckets["idle"]; ok {
+		t.Error("idle bucket was not swept")
+	}
+	if _, ok := m.buckets["busy"]; !ok {
+		t.Error("busy bucket was swept")
+	}
+}
+
+// closeResult เทียบ Result โดยยอมให้เวลาคลาดเคลื่อนจากการคำนวณทศนิยมได้เล็กน้อย
+func closeResult(got, want Result) bool {
+	near := func(a, b time.Duration) bool {
+		d := a - b
+		return d > -time.Millisecond && d < time.Millisecond
+	}
+	return got.Allowed == want.Allowed && got.Remaining == want.Remaining &&
+		near(got.Reset, want.Reset) && near(got.RetryAfter, want.RetryAfter)
+}
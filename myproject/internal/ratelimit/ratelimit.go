@@ -0,0 +1,143 @@
+// ratelimit.go
+
+// Package ratelimit จำกัดจำนวน request ด้วย token bucket มีที่เก็บสถานะในหน่วยความจำและใน Redis
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit อัตราที่อนุญาต: เติม token ได้ Rate ตัวต่อวินาที และสะสมได้ไม่เกิน Burst ตัว
+// Limit ที่ Burst เป็น 0 หมายถึงไม่จำกัด
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited ไม่จำกัดจำนวน request
+var Unlimited = Limit{}
+
+// IsUnlimited คืนค่า true ถ้าไม่จำกัดจำนวน request
+func (l Limit) IsUnlimited() bool {
+	return l.Burst <= 0 || l.Rate <= 0
+}
+
+// Window ช่วงเวลาที่ใช้เติม token จนเต็ม bucket
+func (l Limit) Window() time.Duration {
+	if l.IsUnlimited() {
+		return 0
+	}
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+func (l Limit) String() string {
+	if l.IsUnlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Burst, l.Window())
+}
+
+// ParseLimit แปลงข้อความแบบ "<จำนวน>/<ช่วงเวลา>" เช่น "60/m", "10/s", "1000/h" หรือ "100/30s"
+// เป็น Limit ที่รับได้ <จำนวน> request ติดกัน แล้วเติมกลับเต็มภายใน <ช่วงเวลา> ค่า "off" หรือ "0" คือไม่จำกัด
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "off" || s == "0" || s == "" {
+		return Unlimited, nil
+	}
+
+	countStr, periodStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q (expected <count>/<period> such as 60/m)", s)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(countStr))
+	if err != nil || count < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: count must be a whole number", s)
+	}
+
+	var period time.Duration
+	switch periodStr = strings.TrimSpace(periodStr); periodStr {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		period, err = time.ParseDuration(periodStr)
+		if err != nil || period <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: period must be s, m, h or a duration such as 30s", s)
+		}
+	}
+
+	if count == 0 {
+		return Unlimited, nil
+	}
+	return Limit{Rate: float64(count) / period.Seconds(), Burst: count}, nil
+}
+
+// Result ผลของการขอใช้ token 1 ตัว
+type Result struct {
+	Allowed bool
+	// Remaining จำนวน request ที่ยังส่งได้ทันที
+	Remaining int
+	// Reset เวลาที่ต้องรอจน bucket เต็ม
+	Reset time.Duration
+	// RetryAfter เวลาที่ต้องรอก่อนส่ง request ถัดไปได้ (มีค่าเมื่อ Allowed เป็น false)
+	RetryAfter time.Duration
+}
+
+// Store ที่เก็บสถานะของ bucket แต่ละ key
+type Store interface {
+	// Allow ขอใช้ token 1 ตัวจาก bucket ของ key
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	Close() error
+}
+
+// result คำนวณ Result จากจำนวน token ที่เหลือหลังการขอ
+func result(allowed bool, tokens float64, limit Limit) Result {
+	r := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
+
+// สำหรับการตั้งค่า Backend
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Options การตั้งค่าของ New
+type Options struct {
+	Backend string
+	// RedisURL เช่น redis://localhost:6379/0 ใช้เมื่อ Backend เป็น redis
+	RedisURL string
+	// KeyPrefix นำหน้าทุก key ใน Redis
+	KeyPrefix string
+}
+
+// New สร้าง Store ตาม Backend
+func New(opts Options) (Store, error) {
+	switch opts.Backend {
+	case "", BackendMemory:
+		return NewMemory(), nil
+	case BackendRedis:
+		return NewRedis(opts.RedisURL, opts.KeyPrefix)
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q (expected memory or redis)", opts.Backend)
+	}
+}
@@ -0,0 +1,54 @@
+// ratelimit_test.go
+package ratelimit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantBurst  int
+		wantWindow time.Duration
+		wantErr    string
+	}{
+		{in: "60/m", wantBurst: 60, wantWindow: time.Minute},
+		{in: "10/s", wantBurst: 10, wantWindow: time.Second},
+		{in: " 1000 / H ", wantBurst: 1000, wantWindow: time.Hour},
+		{in: "100/30s", wantBurst: 100, wantWindow: 30 * time.Second},
+		{in: "off"},
+		{in: "0"},
+		{in: ""},
+		{in: "0/m"},
+		{in: "60", wantErr: "expected <count>/<period>"},
+		{in: "-1/m", wantErr: "count must be a whole number"},
+		{in: "x/m", wantErr: "count must be a whole number"},
+		{in: "60/week", wantErr: "period must be"},
+		{in: "60/-1s", wantErr: "period must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			limit, err := ParseLimit(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseLimit(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLimit(%q) error = %v", tt.in, err)
+			}
+			if tt.wantBurst == 0 {
+				if !limit.IsUnlimited() {
+					t.Errorf("ParseLimit(%q) = %s, want unlimited", tt.in, limit)
+				}
+				return
+			}
+			if limit.Burst != tt.wantBurst || limit.Window() != tt.wantWindow {
+				t.Errorf("ParseLimit(%q) = %d per %s, want %d per %s", tt.in, limit.Burst, limit.Window(), tt.wantBurst, tt.wantWindow)
+			}
+		})
+	}
+}
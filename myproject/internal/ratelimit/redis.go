@@ -0,0 +1,75 @@
+// redis.go
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucket เติมและใช้ token แบบ atomic ใน Redis โดยใช้เวลาของ Redis เพื่อไม่ให้นาฬิกาของแต่ละ instance ที่ไม่ตรงกันมีผล
+// คืน {1 ถ้าอนุญาต, จำนวน token ที่เหลือเป็นข้อความ} (Lua ตัดทศนิยมของตัวเลขที่คืนทิ้ง)
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// Redis เก็บ bucket ใน Redis ทุก instance ใช้ bucket ร่วมกัน
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis เชื่อมต่อ Redis ตาม URL เช่น redis://:password@localhost:6379/0 การเชื่อมต่อจริงเกิดขึ้นเมื่อใช้งานครั้งแรก
+func NewRedis(url, prefix string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	return &Redis{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	values, err := tokenBucket.Run(ctx, r.client, []string{r.prefix + key}, limit.Rate, limit.Burst).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+	return result(allowed == 1, tokens, limit), nil
+}
+
+// Ping ตรวจสอบการเชื่อมต่อกับ Redis ใช้กับ /readyz
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,49 @@
+// command.go
+package seed
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"myproject/internal/bookstore"
+)
+
+// Command รันคำสั่ง seed [-synthetic N] [ไฟล์หรือโฟลเดอร์ fixture ...]
+// ถ้าไม่ระบุไฟล์จะใช้ข้อมูลตัวอย่างที่ฝังอยู่ใน binary
+func Command(ctx context.Context, bs *bookstore.BookStore, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(out)
+	synthetic := fs.Int("synthetic", 0, "number of synthetic products to generate per store")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *synthetic < 0 {
+		return fmt.Errorf("-synthetic must not be negative")
+	}
+
+	var (
+		f   Fixture
+		err error
+	)
+	if fs.NArg() == 0 {
+		f, err = LoadDemo()
+	} else {
+		f, err = LoadFiles(fs.Args())
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := Run(ctx, bs, f, *synthetic)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "seeded %d categories, %d stores, %d products", result.Categories, result.Stores, result.Products)
+	if result.Synthetic > 0 {
+		fmt.Fprintf(out, " and %d synthetic products", result.Synthetic)
+	}
+	fmt.Fprintln(out)
+	return nil
+}
@@ -0,0 +1,240 @@
+// seed.go
+package seed
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"myproject/internal/bookstore"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// demo ข้อมูลตัวอย่างที่ใช้เมื่อไม่ได้ระบุไฟล์ fixture
+//
+//go:embed fixtures/*.yaml
+var demo embed.FS
+
+// Fixture ข้อมูลที่โหลดจากไฟล์ YAML หรือ JSON
+type Fixture struct {
+	Categories []CategoryFixture `yaml:"categories" json:"categories"`
+	Stores     []StoreFixture    `yaml:"stores" json:"stores"`
+}
+
+// CategoryFixture หมวดหมู่สินค้า
+type CategoryFixture struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	ImagePath   string `yaml:"image_path" json:"image_path"`
+}
+
+// StoreFixture ร้านพร้อมสินค้าของร้าน
+type StoreFixture struct {
+	StoreName   string           `yaml:"store_name" json:"store_name"`
+	LogoPath    string           `yaml:"logo_path" json:"logo_path"`
+	Description string           `yaml:"description" json:"description"`
+	Address     string           `yaml:"address" json:"address"`
+	PhoneNumber string           `yaml:"phone_number" json:"phone_number"`
+	Email       string           `yaml:"email" json:"email"`
+	Products    []ProductFixture `yaml:"products" json:"products"`
+}
+
+// ProductFixture สินค้า 1 รายการ
+type ProductFixture struct {
+	ProductName   string  `yaml:"product_name" json:"product_name"`
+	Price         float64 `yaml:"price" json:"price"`
+	Quantity      int     `yaml:"quantity" json:"quantity"`
+	Category      string  `yaml:"category" json:"category"`
+	Brand         string  `yaml:"brand" json:"brand"`
+	Model         string  `yaml:"model" json:"model"`
+	IsRecommended bool    `yaml:"is_recommended" json:"is_recommended"`
+	ImagePath     string  `yaml:"image_path" json:"image_path"`
+}
+
+// Result จำนวนข้อมูลที่บันทึกในการ seed แต่ละครั้ง
+type Result struct {
+	Categories int
+	Stores     int
+	Products   int
+	Synthetic  int
+}
+
+// parse แปลงเนื้อหาไฟล์ตามนามสกุล (.yaml, .yml หรือ .json)
+func parse(name string, data []byte) (Fixture, error) {
+	var f Fixture
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &f)
+	case ".json":
+		err = json.Unmarshal(data, &f)
+	default:
+		return f, fmt.Errorf("unsupported fixture file %s (expected .yaml, .yml or .json)", name)
+	}
+	if err != nil {
+		return f, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return f, nil
+}
+
+// merge รวม fixture หลายไฟล์เข้าด้วยกัน
+func (f *Fixture) merge(other Fixture) {
+	f.Categories = append(f.Categories, other.Categories...)
+	f.Stores = append(f.Stores, other.Stores...)
+}
+
+// LoadDemo โหลดข้อมูลตัวอย่างที่ฝังอยู่ใน binary
+func LoadDemo() (Fixture, error) {
+	var all Fixture
+	entries, err := demo.ReadDir("fixtures")
+	if err != nil {
+		return all, fmt.Errorf("failed to read demo fixtures: %w", err)
+	}
+	for _, entry := range entries {
+		data, err := demo.ReadFile("fixtures/" + entry.Name())
+		if err != nil {
+			return all, err
+		}
+		f, err := parse(entry.Name(), data)
+		if err != nil {
+			return all, err
+		}
+		all.merge(f)
+	}
+	return all, nil
+}
+
+// LoadFiles โหลด fixture จากไฟล์ ถ้าระบุเป็นโฟลเดอร์จะโหลดทุกไฟล์ .yaml, .yml และ .json ในโฟลเดอร์นั้น
+func LoadFiles(paths []string) (Fixture, error) {
+	var all Fixture
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return all, err
+		}
+
+		files := []string{p}
+		if info.IsDir() {
+			files = nil
+			for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+				matches, _ := filepath.Glob(filepath.Join(p, pattern))
+				files = append(files, matches...)
+			}
+			sort.Strings(files)
+		}
+
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return all, err
+			}
+			f, err := parse(file, data)
+			if err != nil {
+				return all, err
+			}
+			all.merge(f)
+		}
+	}
+	return all, nil
+}
+
+// Run บันทึก fixture ผ่าน BookStore ทั้งหมดเป็นการ upsert จึงรันซ้ำได้โดยไม่เกิดข้อมูลซ้ำ
+// ถ้า synthetic มากกว่า 0 จะสร้างสินค้าสมมติเพิ่มให้ทุกร้านใน fixture ร้านละ synthetic ชิ้น
+func Run(ctx context.Context, bs *bookstore.BookStore, f Fixture, synthetic int) (Result, error) {
+	var result Result
+
+	// หมวดหมู่ที่สินค้าอ้างถึงแต่ไม่ได้ประกาศไว้ ให้สร้างด้วยชื่ออย่างเดียว
+	known := map[string]bool{}
+	for _, c := range f.Categories {
+		known[c.Name] = true
+	}
+	for _, sf := range f.Stores {
+		for _, pf := range sf.Products {
+			if pf.Category != "" && !known[pf.Category] {
+				known[pf.Category] = true
+				f.Categories = append(f.Categories, CategoryFixture{Name: pf.Category})
+			}
+		}
+	}
+
+	categories := make([]string, 0, len(f.Categories))
+	for _, c := range f.Categories {
+		_, err := bs.UpsertCategory(ctx, bookstore.Category{Name: c.Name, Description: c.Description, ImagePath: c.ImagePath})
+		if err != nil {
+			return result, fmt.Errorf("category %q: %w", c.Name, err)
+		}
+		categories = append(categories, c.Name)
+		result.Categories++
+	}
+
+	for _, sf := range f.Stores {
+		store, err := bs.UpsertStore(ctx, bookstore.StoreInfo{
+			StoreName:   sf.StoreName,
+			LogoPath:    sf.LogoPath,
+			Description: sf.Description,
+			Address:     sf.Address,
+			PhoneNumber: sf.PhoneNumber,
+			Email:       sf.Email,
+		})
+		if err != nil {
+			return result, fmt.Errorf("store %q: %w", sf.StoreName, err)
+		}
+		result.Stores++
+
+		for _, pf := range sf.Products {
+			_, err := bs.UpsertProduct(ctx, bookstore.Product{
+				ProductName:   pf.ProductName,
+				Price:         pf.Price,
+				Quantity:      pf.Quantity,
+				Category:      pf.Category,
+				Brand:         pf.Brand,
+				Model:         pf.Model,
+				StoreID:       store.ID,
+				IsRecommended: pf.IsRecommended,
+				ImagePath:     pf.ImagePath,
+			})
+			if err != nil {
+				return result, fmt.Errorf("store %q product %q: %w", sf.StoreName, pf.ProductName, err)
+			}
+			result.Products++
+		}
+
+		for i := 1; i <= synthetic; i++ {
+			if _, err := bs.UpsertProduct(ctx, syntheticProduct(store.ID, i, categories)); err != nil {
+				return result, fmt.Errorf("store %q synthetic product %d: %w", sf.StoreName, i, err)
+			}
+			result.Synthetic++
+		}
+	}
+
+	return result, nil
+}
+
+// syntheticProduct สร้างสินค้าสมมติสำหรับทดสอบโหลด ข้อมูลขึ้นกับร้านและลำดับเท่านั้น
+// การรันซ้ำจึงได้สินค้าเดิม (model เดิม) ไม่ใช่สินค้าใหม่
+func syntheticProduct(storeID, n int, categories []string) bookstore.Product {
+	rng := rand.New(rand.NewSource(int64(storeID)*1_000_003 + int64(n)))
+
+	category := "ทั่วไป"
+	if len(categories) > 0 {
+		category = categories[rng.Intn(len(categories))]
+	}
+
+	return bookstore.Product{
+		ProductName:   fmt.Sprintf("Synthetic Product %d-%05d", storeID, n),
+		Price:         float64(100 + rng.Intn(99900)),
+		Quantity:      rng.Intn(50),
+		Category:      category,
+		Brand:         "Synthetic",
+		Model:         fmt.Sprintf("SYN-%d-%05d", storeID, n),
+		StoreID:       storeID,
+		IsRecommended: rng.Intn(10) == 0,
+		ImagePath:     "/images/products/placeholder.png",
+	}
+}
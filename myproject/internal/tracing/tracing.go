@@ -0,0 +1,80 @@
+// tracing.go
+
+// Package tracing ตั้งค่า OpenTelemetry tracer และ exporter ของทั้งระบบ
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// exporter ที่รองรับ
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Options การตั้งค่า tracing
+type Options struct {
+	ServiceName string
+	// Exporter ปลายทางของ span: none, stdout หรือ otlp
+	Exporter string
+	// OTLPEndpoint host:port ของ collector ที่รับ OTLP ผ่าน HTTP เช่น localhost:4318
+	OTLPEndpoint string
+	// OTLPInsecure ส่งไปยัง collector ด้วย HTTP ธรรมดาแทน HTTPS
+	OTLPInsecure bool
+	// SampleRatio สัดส่วนของ trace ที่เก็บ (0 ถึง 1) ถ้า request มี trace ต้นทางจะใช้การตัดสินใจของต้นทาง
+	SampleRatio float64
+}
+
+// Setup ตั้งค่า tracer provider และ propagator แบบ W3C (traceparent, baggage) ให้ทั้งโปรแกรม
+// คืนฟังก์ชันที่ต้องเรียกตอนปิดโปรแกรมเพื่อส่ง span ที่ค้างอยู่ ถ้า Exporter เป็น none จะไม่ส่ง span ไปไหน
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.OTLPEndpoint)}
+		if opts.OTLPInsecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter %q (use none, stdout or otlp)", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}